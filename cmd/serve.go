@@ -7,7 +7,7 @@ import (
 
 	"github.com/gaesemo/blog-server/config"
 	"github.com/gaesemo/blog-server/server"
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -50,16 +50,14 @@ func serve(cmd *cobra.Command, args []string) error {
 	port := viper.GetUint16("port")
 
 	connstr := pgConnStr()
-	pg, err := pgx.Connect(ctx, connstr)
+	// Background jobs query the database alongside requests, so connections
+	// come from a pool.
+	pg, err := pgxpool.New(ctx, connstr)
 	if err != nil {
 		slog.ErrorContext(ctx, "connecting db: %v", slog.Any("error", err))
 		return err
 	}
-	defer func() {
-		if err := pg.Close(ctx); err != nil {
-			slog.ErrorContext(ctx, "closing db: %v", slog.Any("error", err))
-		}
-	}()
+	defer pg.Close()
 
 	srv := server.New(slog.Default(), port, pg)
 	if err := srv.Serve(ctx); err != nil {
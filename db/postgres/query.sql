@@ -24,6 +24,12 @@ WHERE deleted_at IS NULL
 AND email = $1
 AND identity_provider = $2;
 
+-- name: ListUsersByIds :many
+SELECT *
+FROM users
+WHERE deleted_at IS NULL
+AND id = ANY(@ids::bigint[]);
+
 -- name: CreatePost :one
 INSERT INTO posts (
     likes,
@@ -31,24 +37,795 @@ INSERT INTO posts (
     title,
     body,
     user_id,
+    status,
+    published_at,
+    slug,
+    body_html,
+    toc,
+    excerpt,
+    word_count,
+    reading_minutes,
     created_at,
     updated_at
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15
 )
 RETURNING *;
 
 -- name: ListRecentPosts :many
+-- Pages through posts from newest to oldest, starting after the
+-- (updated_at, id) keyset of the cursor, or from the top without one.
+-- author_id optionally limits the page to one author's posts.
 SELECT *
 FROM posts
 WHERE deleted_at IS NULL
-AND id > @cursor
-ORDER BY updated_at DESC
-LIMIT $1;
+AND (status = 'published' OR user_id = @viewer_id)
+AND (
+    coalesce(cardinality(@tags::text[]), 0) = 0
+    OR (
+        SELECT COUNT(*)
+        FROM post_tags
+        JOIN tags ON tags.id = post_tags.tag_id
+        WHERE post_tags.post_id = posts.id
+        AND tags.name = ANY(@tags::text[])
+    ) >= CASE WHEN @match_all::boolean THEN cardinality(@tags::text[]) ELSE 1 END
+)
+AND (sqlc.narg('author_id')::bigint IS NULL OR user_id = sqlc.narg('author_id')::bigint)
+AND (
+    @cursor_time::timestamptz IS NULL
+    OR (updated_at, id) < (@cursor_time::timestamptz, @cursor_id::bigint)
+)
+ORDER BY updated_at DESC, id DESC
+LIMIT @page_size;
+
+-- name: ListNewerPosts :many
+-- Pages back towards newer posts than the cursor, nearest first.
+SELECT *
+FROM posts
+WHERE deleted_at IS NULL
+AND (status = 'published' OR user_id = @viewer_id)
+AND (
+    coalesce(cardinality(@tags::text[]), 0) = 0
+    OR (
+        SELECT COUNT(*)
+        FROM post_tags
+        JOIN tags ON tags.id = post_tags.tag_id
+        WHERE post_tags.post_id = posts.id
+        AND tags.name = ANY(@tags::text[])
+    ) >= CASE WHEN @match_all::boolean THEN cardinality(@tags::text[]) ELSE 1 END
+)
+AND (updated_at, id) > (@cursor_time::timestamptz, @cursor_id::bigint)
+ORDER BY updated_at ASC, id ASC
+LIMIT @page_size;
 
 -- name: GetPostById :one
 SELECT *
 FROM posts
 WHERE deleted_at IS NULL
-AND id = $1;
+AND id = @id
+AND (status = 'published' OR user_id = @viewer_id);
+
+-- name: GetPostBySlug :one
+-- Finds a post by its current or any of its former slugs.
+SELECT posts.*
+FROM post_slugs
+JOIN posts ON posts.id = post_slugs.post_id
+WHERE post_slugs.slug = @slug
+AND posts.deleted_at IS NULL
+AND (posts.status = 'published' OR posts.user_id = @viewer_id);
+
+-- name: GetPostByIdForUpdate :one
+SELECT *
+FROM posts
+WHERE deleted_at IS NULL
+AND id = $1
+FOR UPDATE;
+
+-- name: UpdatePost :one
+UPDATE posts
+SET title = @title,
+    body = @body,
+    slug = @slug,
+    body_html = @body_html,
+    toc = @toc,
+    excerpt = @excerpt,
+    word_count = @word_count,
+    reading_minutes = @reading_minutes,
+    updated_at = @updated_at,
+    version = version + 1
+WHERE deleted_at IS NULL
+AND id = @id
+AND version = @version
+RETURNING *;
+
+-- name: AddPostViews :exec
+-- Adds buffered view counts in one statement. updated_at is left alone, as a
+-- view does not change the post.
+UPDATE posts
+SET views = posts.views + v.views
+FROM unnest(@post_ids::bigint[], @views::bigint[]) AS v (post_id, views)
+WHERE posts.id = v.post_id;
+
+-- name: SoftDeletePost :exec
+UPDATE posts
+SET deleted_at = @deleted_at
+WHERE deleted_at IS NULL
+AND id = @id;
+
+-- name: ListDeletedPostsByUser :many
+-- Pages through a user's trash from the most recently deleted post,
+-- starting after the (deleted_at, id) keyset of the cursor, or from the top
+-- without one.
+SELECT *
+FROM posts
+WHERE deleted_at IS NOT NULL
+AND user_id = @user_id
+AND (
+    @cursor_time::timestamptz IS NULL
+    OR (deleted_at, id) < (@cursor_time::timestamptz, @cursor_id::bigint)
+)
+ORDER BY deleted_at DESC, id DESC
+LIMIT @page_size;
+
+-- name: GetDeletedPostByIdForUpdate :one
+SELECT *
+FROM posts
+WHERE deleted_at IS NOT NULL
+AND id = $1
+FOR UPDATE;
+
+-- name: RestorePost :one
+UPDATE posts
+SET deleted_at = NULL,
+    updated_at = @updated_at,
+    version = version + 1
+WHERE deleted_at IS NOT NULL
+AND id = @id
+RETURNING *;
+
+-- name: PurgeDeletedPosts :execrows
+DELETE FROM posts
+WHERE deleted_at IS NOT NULL
+AND deleted_at < @deleted_before;
+
+-- name: CreatePostRevision :one
+INSERT INTO post_revisions (
+    post_id,
+    title,
+    body,
+    editor_id,
+    created_at
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: ListPostRevisions :many
+SELECT *
+FROM post_revisions
+WHERE post_id = @post_id
+AND (@cursor::bigint = 0 OR id < @cursor)
+ORDER BY id DESC
+LIMIT @page_size;
+
+-- name: GetPostRevision :one
+SELECT *
+FROM post_revisions
+WHERE post_id = @post_id
+AND id = @id;
+
+-- name: SetPostStatus :one
+UPDATE posts
+SET status = @status,
+    published_at = @published_at,
+    updated_at = @updated_at,
+    version = version + 1
+WHERE deleted_at IS NULL
+AND id = @id
+RETURNING *;
+
+-- name: TouchPost :one
+-- Marks a post as changed by something other than its content, such as its
+-- tags, so its version and modification time move on.
+UPDATE posts
+SET updated_at = @updated_at,
+    version = version + 1
+WHERE deleted_at IS NULL
+AND id = @id
+RETURNING *;
+
+-- name: PublishDuePosts :many
+UPDATE posts
+SET status = 'published',
+    updated_at = published_at,
+    version = version + 1
+WHERE deleted_at IS NULL
+AND status = 'scheduled'
+AND published_at <= @now
+RETURNING id;
+
+-- name: CreateTags :exec
+INSERT INTO tags (
+    name,
+    created_at
+)
+SELECT unnest(@names::text[]), @created_at::timestamptz
+ON CONFLICT (name) DO NOTHING;
+
+-- name: ListTagsByName :many
+SELECT *
+FROM tags
+WHERE name = ANY(@names::text[]);
+
+-- name: DeletePostTags :exec
+DELETE FROM post_tags
+WHERE post_id = $1;
+
+-- name: AddPostTags :exec
+INSERT INTO post_tags (
+    post_id,
+    tag_id
+)
+SELECT @post_id::bigint, unnest(@tag_ids::bigint[])
+ON CONFLICT DO NOTHING;
+
+-- name: ListTagsByPostIds :many
+SELECT post_tags.post_id, tags.name
+FROM post_tags
+JOIN tags ON tags.id = post_tags.tag_id
+WHERE post_tags.post_id = ANY(@post_ids::bigint[])
+ORDER BY tags.name;
+
+-- name: ListTagCounts :many
+SELECT tags.name, COUNT(*) AS post_count
+FROM tags
+JOIN post_tags ON post_tags.tag_id = tags.id
+JOIN posts ON posts.id = post_tags.post_id
+WHERE posts.deleted_at IS NULL
+AND posts.status = 'published'
+GROUP BY tags.name
+ORDER BY post_count DESC, tags.name
+LIMIT @page_size;
+
+-- name: SearchPosts :many
+SELECT sqlc.embed(posts),
+    (
+        ts_rank(
+            setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', body), 'B'),
+            plainto_tsquery('simple', @query::text)
+        )
+        + similarity(title, @query::text)
+        + word_similarity(@query::text, title || ' ' || body)
+    )::double precision AS rank
+FROM posts
+WHERE deleted_at IS NULL
+AND status = 'published'
+AND (
+    setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', body), 'B')
+        @@ plainto_tsquery('simple', @query::text)
+    OR (title || ' ' || body) ILIKE ALL (@patterns::text[])
+)
+ORDER BY rank DESC, id DESC
+LIMIT @page_size
+OFFSET @page_offset;
+
+-- name: AddPostSlug :exec
+INSERT INTO post_slugs (
+    slug,
+    post_id,
+    created_at
+) VALUES (
+    $1, $2, $3
+);
+
+-- name: ListSlugsByBase :many
+-- Lists the slugs derived from base: base itself and base-2, base-3, ….
+SELECT slug, post_id
+FROM post_slugs
+WHERE slug = @base::text
+OR slug LIKE @base::text || '-%';
+
+-- name: LikePost :execrows
+INSERT INTO post_likes (
+    user_id,
+    post_id,
+    created_at
+) VALUES (
+    $1, $2, $3
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnlikePost :execrows
+DELETE FROM post_likes
+WHERE user_id = $1
+AND post_id = $2;
+
+-- name: AddPostLikes :one
+UPDATE posts
+SET likes = likes + @delta::bigint
+WHERE id = @id
+RETURNING likes;
+
+-- name: ListLikedPostIds :many
+SELECT post_id
+FROM post_likes
+WHERE user_id = @user_id
+AND post_id = ANY(@post_ids::bigint[]);
+
+-- name: CreateComment :one
+INSERT INTO comments (
+    post_id,
+    parent_id,
+    root_id,
+    depth,
+    user_id,
+    body,
+    created_at,
+    updated_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8
+)
+RETURNING *;
+
+-- name: GetCommentByIdForUpdate :one
+SELECT *
+FROM comments
+WHERE id = $1
+FOR UPDATE;
+
+-- name: GetCommentById :one
+SELECT *
+FROM comments
+WHERE id = $1;
+
+-- name: ListRootComments :many
+-- Pages through the top-level comments of a post, oldest first.
+SELECT *
+FROM comments
+WHERE post_id = @post_id
+AND parent_id IS NULL
+AND id > @cursor
+ORDER BY id
+LIMIT @page_size;
+
+-- name: ListCommentReplies :many
+-- Lists the first replies, at most thread_size each, in the threads started
+-- by the given top-level comments.
+SELECT c.*
+FROM unnest(@root_ids::bigint[]) AS r (id)
+CROSS JOIN LATERAL (
+    SELECT *
+    FROM comments
+    WHERE root_id = r.id
+    ORDER BY id
+    LIMIT @thread_size
+) AS c
+ORDER BY c.id;
+
+-- name: ListThreadReplies :many
+-- Pages through the replies in the thread started by a top-level comment,
+-- oldest first.
+SELECT *
+FROM comments
+WHERE root_id = @root_id
+AND id > @cursor
+ORDER BY id
+LIMIT @page_size;
+
+-- name: UpdateCommentBody :one
+UPDATE comments
+SET body = @body,
+    edited_at = @edited_at,
+    updated_at = @edited_at
+WHERE id = @id
+RETURNING *;
+
+-- name: SoftDeleteComment :one
+UPDATE comments
+SET deleted_at = @deleted_at,
+    updated_at = @deleted_at
+WHERE id = @id
+RETURNING *;
+
+-- name: SetCommentHidden :one
+UPDATE comments
+SET hidden_at = @hidden_at,
+    hidden_by = @hidden_by,
+    updated_at = @updated_at
+WHERE id = @id
+RETURNING *;
+
+-- name: CreateSeries :one
+INSERT INTO series (
+    user_id,
+    title,
+    description,
+    created_at,
+    updated_at
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: GetSeriesById :one
+SELECT *
+FROM series
+WHERE id = $1;
+
+-- name: GetSeriesByIdForUpdate :one
+SELECT *
+FROM series
+WHERE id = $1
+FOR UPDATE;
+
+-- name: GetSeriesByPostId :one
+SELECT series.*
+FROM series_posts
+JOIN series ON series.id = series_posts.series_id
+WHERE series_posts.post_id = $1;
+
+-- name: ListSeriesPosts :many
+-- Lists the posts of a series the viewer can read, in series order.
+SELECT posts.*
+FROM series_posts
+JOIN posts ON posts.id = series_posts.post_id
+WHERE series_posts.series_id = @series_id
+AND posts.deleted_at IS NULL
+AND (posts.status = 'published' OR posts.user_id = @viewer_id)
+ORDER BY series_posts.position;
+
+-- name: ListSeriesPostIds :many
+SELECT post_id
+FROM series_posts
+WHERE series_id = $1
+ORDER BY position;
+
+-- name: AddSeriesPost :one
+-- Appends a post to the end of a series.
+INSERT INTO series_posts (
+    series_id,
+    post_id,
+    position
+)
+SELECT @series_id::bigint, @post_id::bigint, (coalesce(max(position), 0) + 1)::integer
+FROM series_posts
+WHERE series_id = @series_id::bigint
+RETURNING *;
+
+-- name: RemoveSeriesPost :one
+DELETE FROM series_posts
+WHERE series_id = $1
+AND post_id = $2
+RETURNING *;
+
+-- name: CloseSeriesGap :exec
+-- Moves the posts after a removed one up by one position.
+UPDATE series_posts
+SET position = position - 1
+WHERE series_id = @series_id
+AND position > @position;
+
+-- name: ReorderSeriesPosts :exec
+-- Numbers the posts of a series in the order of post_ids.
+UPDATE series_posts
+SET position = array_position(@post_ids::bigint[], post_id)
+WHERE series_id = @series_id;
+
+-- name: TouchSeries :exec
+UPDATE series
+SET updated_at = $1
+WHERE id = $2;
+
+-- name: ListSitemapPosts :many
+-- Lists every published post for the sitemap.
+SELECT id, slug, updated_at
+FROM posts
+WHERE deleted_at IS NULL
+AND status = 'published'
+ORDER BY id;
+
+-- name: ListSitemapAuthors :many
+-- Lists every author with a published post, with the time their latest
+-- published post changed.
+SELECT posts.user_id, max(posts.updated_at)::timestamptz AS updated_at
+FROM posts
+JOIN users ON users.id = posts.user_id
+WHERE posts.deleted_at IS NULL
+AND posts.status = 'published'
+AND users.deleted_at IS NULL
+GROUP BY posts.user_id
+ORDER BY posts.user_id;
+
+-- name: ClearRelatedPosts :exec
+DELETE FROM related_posts;
+
+-- name: SetSimilarityThreshold :exec
+-- Sets the similarity the % operator requires for the rest of the
+-- transaction.
+SELECT set_config('pg_trgm.similarity_threshold', @threshold::text, true);
+
+-- name: ListPublishedPostIds :many
+SELECT id
+FROM posts
+WHERE deleted_at IS NULL
+AND status = 'published'
+ORDER BY id;
+
+-- name: ListRelatedPostsAffectedBy :many
+-- Lists the posts whose related posts may change when the given posts
+-- changed: the posts themselves, the posts listing them, and the posts that
+-- share a tag or the author with them or whose text is similar to theirs.
+SELECT changed.id
+FROM unnest(@post_ids::bigint[]) AS changed (id)
+UNION
+SELECT related_posts.post_id
+FROM related_posts
+WHERE related_posts.related_id = ANY(@post_ids::bigint[])
+UNION
+SELECT b.post_id
+FROM post_tags a
+JOIN post_tags b ON b.tag_id = a.tag_id
+WHERE a.post_id = ANY(@post_ids::bigint[])
+UNION
+SELECT c.id
+FROM posts p
+JOIN posts c ON c.user_id = p.user_id
+WHERE p.id = ANY(@post_ids::bigint[])
+UNION
+SELECT c.id
+FROM posts p
+JOIN posts c ON (c.title || ' ' || c.body) % (p.title || ' ' || p.body)
+WHERE p.id = ANY(@post_ids::bigint[]);
+
+-- name: DeleteRelatedPosts :exec
+DELETE FROM related_posts
+WHERE post_id = ANY(@post_ids::bigint[]);
+
+-- name: ComputeRelatedPosts :execrows
+-- Scores the candidates of each of the given published posts by the tags
+-- they share, the trigram similarity of their titles and bodies, and whether
+-- they have the same author, and keeps the best per_post scoring at least
+-- min_score. Candidates share a tag or the author, or match the post with %
+-- on the trigram index; the others cannot score more than the similarity
+-- threshold allows.
+INSERT INTO related_posts (
+    post_id,
+    related_id,
+    score,
+    computed_at
+)
+SELECT post_id, related_id, score, @computed_at
+FROM (
+    SELECT post_id, related_id, score,
+        row_number() OVER (PARTITION BY post_id ORDER BY score DESC, related_id DESC) AS rank
+    FROM (
+        SELECT p.id AS post_id, c.id AS related_id,
+            (
+                (
+                    SELECT count(*)
+                    FROM post_tags a
+                    JOIN post_tags b ON b.tag_id = a.tag_id
+                    WHERE a.post_id = p.id
+                    AND b.post_id = c.id
+                )
+                + 2 * similarity(p.title || ' ' || p.body, c.title || ' ' || c.body)
+                + CASE WHEN p.user_id = c.user_id THEN 0.5 ELSE 0 END
+            )::double precision AS score
+        FROM posts p
+        CROSS JOIN LATERAL (
+            SELECT b.post_id AS id
+            FROM post_tags a
+            JOIN post_tags b ON b.tag_id = a.tag_id
+            WHERE a.post_id = p.id
+            UNION
+            SELECT o.id
+            FROM posts o
+            WHERE o.user_id = p.user_id
+            UNION
+            SELECT o.id
+            FROM posts o
+            WHERE (o.title || ' ' || o.body) % (p.title || ' ' || p.body)
+        ) AS candidate
+        JOIN posts c ON c.id = candidate.id
+        WHERE p.id = ANY(@post_ids::bigint[])
+        AND p.deleted_at IS NULL
+        AND p.status = 'published'
+        AND c.id <> p.id
+        AND c.deleted_at IS NULL
+        AND c.status = 'published'
+    ) AS scored
+    WHERE score >= @min_score::double precision
+) AS ranked
+WHERE rank <= @per_post::integer;
+
+-- name: ListRelatedPosts :many
+SELECT posts.id, posts.slug, posts.title, posts.excerpt
+FROM related_posts
+JOIN posts ON posts.id = related_posts.related_id
+WHERE related_posts.post_id = @post_id
+AND posts.deleted_at IS NULL
+AND posts.status = 'published'
+ORDER BY related_posts.score DESC, related_posts.related_id DESC
+LIMIT @page_size;
+
+-- name: AddDailyPostViews :exec
+-- Adds buffered view counts to the tallies of the day. Views of posts purged
+-- in the meantime are dropped.
+INSERT INTO post_daily_views (
+    post_id,
+    day,
+    views
+)
+SELECT v.post_id, @day::date, v.views
+FROM unnest(@post_ids::bigint[], @views::bigint[]) AS v (post_id, views)
+JOIN posts ON posts.id = v.post_id
+ON CONFLICT (post_id, day) DO UPDATE
+SET views = post_daily_views.views + excluded.views;
+
+-- name: PurgeDailyPostViews :exec
+DELETE FROM post_daily_views
+WHERE day < @before::date;
+
+-- name: ClearPostRankings :exec
+DELETE FROM post_rankings;
+
+-- name: ComputePostRankings :execrows
+-- Scores every published post in each ranking. Views and weighted likes of
+-- the last 30 days count towards trending, halving in weight every half
+-- life, and in full towards the top posts of the week and month; top-all
+-- uses the lifetime counters.
+WITH activity AS (
+    SELECT post_id, day::timestamptz AS at, views::double precision AS points
+    FROM post_daily_views
+    WHERE day >= (@now::timestamptz - interval '30 days')::date
+    UNION ALL
+    SELECT post_id, created_at AS at, @like_weight::double precision AS points
+    FROM post_likes
+    WHERE created_at >= @now::timestamptz - interval '30 days'
+),
+scores AS (
+    SELECT post_id,
+        sum(points * power(0.5, extract(epoch FROM @now::timestamptz - at) / @half_life_seconds::double precision)) AS trending,
+        coalesce(sum(points) FILTER (WHERE at >= @now::timestamptz - interval '7 days'), 0) AS week,
+        sum(points) AS month
+    FROM activity
+    GROUP BY post_id
+)
+INSERT INTO post_rankings (
+    kind,
+    post_id,
+    score
+)
+SELECT r.kind, posts.id, r.score
+FROM posts
+LEFT JOIN scores ON scores.post_id = posts.id
+CROSS JOIN LATERAL (
+    VALUES
+        ('trending', coalesce(scores.trending, 0)),
+        ('top-week', coalesce(scores.week, 0)),
+        ('top-month', coalesce(scores.month, 0)),
+        ('top-all', posts.views + @like_weight::double precision * posts.likes)
+) AS r (kind, score)
+WHERE posts.deleted_at IS NULL
+AND posts.status = 'published';
+
+-- name: ListRankedPosts :many
+SELECT posts.*
+FROM post_rankings
+JOIN posts ON posts.id = post_rankings.post_id
+WHERE post_rankings.kind = @kind
+AND posts.deleted_at IS NULL
+AND posts.status = 'published'
+ORDER BY post_rankings.score DESC, post_rankings.post_id DESC
+LIMIT @page_size
+OFFSET @page_offset;
+
+-- name: CreateOAuthState :exec
+INSERT INTO oauth_states (
+    state,
+    identity_provider,
+    code_verifier,
+    browser_hash,
+    created_at,
+    expires_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+);
+
+-- name: ConsumeOAuthState :one
+-- Deletes a state, so it cannot be used again, and returns it unless it
+-- expired.
+DELETE FROM oauth_states
+WHERE state = @state
+AND expires_at > @now
+RETURNING *;
+
+-- name: DeleteExpiredOAuthStates :exec
+DELETE FROM oauth_states
+WHERE expires_at <= @now;
+
+-- name: CreateSession :one
+INSERT INTO sessions (
+    user_id,
+    user_agent,
+    ip_address,
+    created_at,
+    last_used_at,
+    expires_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
+
+-- name: GetSession :one
+SELECT * FROM sessions
+WHERE id = $1;
+
+-- name: ListActiveSessions :many
+SELECT * FROM sessions
+WHERE user_id = @user_id
+AND revoked_at IS NULL
+AND expires_at > @now
+ORDER BY last_used_at DESC, id DESC;
+
+-- name: TouchSession :exec
+-- Records a use of a session and extends it.
+UPDATE sessions
+SET last_used_at = @last_used_at,
+    expires_at = @expires_at,
+    user_agent = @user_agent,
+    ip_address = @ip_address
+WHERE id = @id;
+
+-- name: RevokeSession :execrows
+UPDATE sessions
+SET revoked_at = @revoked_at
+WHERE id = @id
+AND user_id = @user_id
+AND revoked_at IS NULL;
+
+-- name: PurgeSessions :execrows
+-- Deletes expired sessions along with their tokens. Revoked sessions are kept
+-- until they would have expired, so their rotated tokens are still known.
+DELETE FROM sessions
+WHERE expires_at <= @now;
+
+-- name: CreateSessionToken :exec
+INSERT INTO session_tokens (
+    token_hash,
+    session_id,
+    created_at
+) VALUES (
+    $1, $2, $3
+);
+
+-- name: GetSessionToken :one
+SELECT * FROM session_tokens
+WHERE token_hash = $1;
+
+-- name: GetSessionTokenForUpdate :one
+SELECT * FROM session_tokens
+WHERE token_hash = $1
+FOR UPDATE;
+
+-- name: RotateSessionToken :exec
+UPDATE session_tokens
+SET rotated_at = @rotated_at
+WHERE token_hash = @token_hash;
+
+-- name: RevokeToken :exec
+INSERT INTO revoked_tokens (
+    token_id,
+    expires_at
+) VALUES (
+    $1, $2
+)
+ON CONFLICT (token_id) DO NOTHING;
+
+-- name: ListRevokedTokens :many
+SELECT * FROM revoked_tokens
+WHERE expires_at > $1;
 
+-- name: DeleteExpiredRevokedTokens :exec
+DELETE FROM revoked_tokens
+WHERE expires_at <= $1;
@@ -11,6 +11,54 @@ CREATE TABLE IF NOT EXISTS users (
     UNIQUE (email, identity_provider)
 );
 
+-- Sign-ins in progress. A state is valid once and only until it expires,
+-- and only for the browser holding the cookie hashed into browser_hash.
+CREATE TABLE IF NOT EXISTS oauth_states (
+    state TEXT PRIMARY KEY,
+    identity_provider TEXT NOT NULL,
+    code_verifier TEXT NOT NULL DEFAULT '',
+    browser_hash TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS oauth_states_expires_at_idx ON oauth_states (expires_at);
+
+-- Signed-in devices. Each session is a family of refresh tokens, of which
+-- only the latest is valid; see session_tokens.
+CREATE TABLE IF NOT EXISTS sessions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip_address TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE DEFAULT NULL
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id, last_used_at DESC);
+
+-- Hashes of every refresh token issued for a session. Rotated tokens are
+-- kept, so presenting one again is taken as reuse.
+CREATE TABLE IF NOT EXISTS session_tokens (
+    token_hash TEXT PRIMARY KEY,
+    session_id BIGINT NOT NULL REFERENCES sessions (id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    rotated_at TIMESTAMP WITH TIME ZONE DEFAULT NULL
+);
+
+CREATE INDEX IF NOT EXISTS session_tokens_session_id_idx ON session_tokens (session_id);
+
+-- IDs of access tokens revoked before they expire, such as by logging out.
+-- A row is of no use once the token has expired.
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    token_id TEXT PRIMARY KEY,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
+
 CREATE TABLE IF NOT EXISTS posts (
     id BIGSERIAL PRIMARY KEY,
     likes BIGINT NOT NULL DEFAULT 0,
@@ -18,8 +66,174 @@ CREATE TABLE IF NOT EXISTS posts (
     title VARCHAR(255) NOT NULL,
     body TEXT NOT NULL DEFAULT '',
     user_id BIGINT NOT NULL, -- author
+    version BIGINT NOT NULL DEFAULT 0, -- optimistic concurrency token
+    status TEXT NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'scheduled', 'published')),
+    published_at TIMESTAMP WITH TIME ZONE DEFAULT NULL, -- when a scheduled or published post goes public
+    slug TEXT NOT NULL UNIQUE, -- current permalink, see post_slugs
+    body_html TEXT NOT NULL DEFAULT '', -- body rendered from Markdown and sanitised
+    toc JSONB NOT NULL DEFAULT '[]', -- headings of the rendered body
+    excerpt TEXT NOT NULL DEFAULT '', -- plain text opening of the body
+    word_count INTEGER NOT NULL DEFAULT 0, -- CJK characters count as words
+    reading_minutes INTEGER NOT NULL DEFAULT 0,
 
     created_at TIMESTAMP WITH TIME ZONE NOT NULL,
     updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
     deleted_at TIMESTAMP WITH TIME ZONE DEFAULT NULL -- soft delete
-);
\ No newline at end of file
+);
+
+-- Databases created before the columns above existed get them here. Their
+-- posts were all public, so they are published as of their creation, and
+-- they get placeholder slugs until their next edit derives one from the
+-- title.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'published' CHECK (status IN ('draft', 'scheduled', 'published'));
+ALTER TABLE posts ALTER COLUMN status SET DEFAULT 'draft';
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS published_at TIMESTAMP WITH TIME ZONE DEFAULT NULL;
+UPDATE posts SET published_at = created_at WHERE status = 'published' AND published_at IS NULL;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS slug TEXT;
+UPDATE posts SET slug = 'post-' || id WHERE slug IS NULL;
+ALTER TABLE posts ALTER COLUMN slug SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS posts_slug_key ON posts (slug);
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS body_html TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS toc JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS excerpt TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS word_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS reading_minutes INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS posts_scheduled_idx ON posts (published_at) WHERE status = 'scheduled';
+
+CREATE INDEX IF NOT EXISTS posts_recent_idx ON posts (updated_at DESC, id DESC) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS posts_trash_idx ON posts (user_id, deleted_at DESC, id DESC) WHERE deleted_at IS NOT NULL;
+
+-- Search combines whole-word matching on a tsvector with trigram matching,
+-- because 'simple' tsvectors cannot split Korean words from their particles.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS posts_search_tsv_idx ON posts USING GIN (
+    (setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', body), 'B'))
+);
+
+CREATE INDEX IF NOT EXISTS posts_search_trgm_idx ON posts USING GIN ((title || ' ' || body) gin_trgm_ops);
+
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    post_id BIGINT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL, -- title before the edit
+    body TEXT NOT NULL DEFAULT '', -- body before the edit
+    editor_id BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL -- when the edit happened
+);
+
+CREATE INDEX IF NOT EXISTS post_revisions_post_id_idx ON post_revisions (post_id, id);
+
+-- Every slug a post has ever had, so links to old slugs keep working after
+-- the title changes. Slugs are never handed to another post: when a post is
+-- purged from the trash its slugs stay behind, with no post, so that old
+-- links find nothing instead of an unrelated post.
+CREATE TABLE IF NOT EXISTS post_slugs (
+    slug TEXT PRIMARY KEY,
+    post_id BIGINT REFERENCES posts (id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS post_slugs_post_id_idx ON post_slugs (post_id);
+
+-- Placeholder slugs of posts from before slugs existed.
+INSERT INTO post_slugs (slug, post_id, created_at)
+SELECT slug, id, created_at FROM posts
+ON CONFLICT (slug) DO NOTHING;
+
+-- Views per post and day, kept for the rankings of recent weeks.
+CREATE TABLE IF NOT EXISTS post_daily_views (
+    post_id BIGINT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    views BIGINT NOT NULL,
+    PRIMARY KEY (post_id, day)
+);
+
+-- Scores of published posts in each ranking ('trending', 'top-week',
+-- 'top-month', 'top-all'), refreshed periodically.
+CREATE TABLE IF NOT EXISTS post_rankings (
+    kind TEXT NOT NULL,
+    post_id BIGINT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    score DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (kind, post_id)
+);
+
+CREATE INDEX IF NOT EXISTS post_rankings_score_idx ON post_rankings (kind, score DESC, post_id DESC);
+
+-- Related posts are precomputed by a background job, best first.
+CREATE TABLE IF NOT EXISTS related_posts (
+    post_id BIGINT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    related_id BIGINT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    score DOUBLE PRECISION NOT NULL,
+    computed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (post_id, related_id)
+);
+
+CREATE INDEX IF NOT EXISTS related_posts_score_idx ON related_posts (post_id, score DESC);
+
+-- A series is an ordered collection of posts by its owner, e.g. a multi-part
+-- article. A post belongs to at most one series.
+CREATE TABLE IF NOT EXISTS series (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL, -- owner
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS series_posts (
+    series_id BIGINT NOT NULL REFERENCES series (id) ON DELETE CASCADE,
+    post_id BIGINT NOT NULL UNIQUE REFERENCES posts (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL, -- 1-based
+    PRIMARY KEY (series_id, post_id),
+    -- Deferred, so reordering can move positions through each other.
+    UNIQUE (series_id, position) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE TABLE IF NOT EXISTS tags (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE, -- normalised: lower case, whitespace collapsed into '-'
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS post_tags (
+    post_id BIGINT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    tag_id BIGINT NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    PRIMARY KEY (post_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS post_tags_tag_id_idx ON post_tags (tag_id);
+
+-- One row per user who likes a post; posts.likes caches the row count.
+CREATE TABLE IF NOT EXISTS post_likes (
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    post_id BIGINT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (user_id, post_id)
+);
+
+CREATE TABLE IF NOT EXISTS comments (
+    id BIGSERIAL PRIMARY KEY,
+    post_id BIGINT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    parent_id BIGINT DEFAULT NULL REFERENCES comments (id), -- NULL for top-level comments
+    root_id BIGINT DEFAULT NULL REFERENCES comments (id), -- top-level comment of the thread, NULL for top-level comments
+    depth INTEGER NOT NULL DEFAULT 0, -- 0 for top-level comments
+    user_id BIGINT NOT NULL, -- author
+    body TEXT NOT NULL,
+    hidden_at TIMESTAMP WITH TIME ZONE DEFAULT NULL, -- hidden by a moderator
+    hidden_by BIGINT DEFAULT NULL,
+    edited_at TIMESTAMP WITH TIME ZONE DEFAULT NULL, -- last change of the body by the author
+
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    deleted_at TIMESTAMP WITH TIME ZONE DEFAULT NULL -- soft delete
+);
+
+CREATE INDEX IF NOT EXISTS comments_post_id_idx ON comments (post_id, id) WHERE parent_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS comments_root_id_idx ON comments (root_id, id);
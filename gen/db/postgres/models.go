@@ -8,18 +8,141 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-type Post struct {
+type Comment struct {
 	ID        int64
-	Likes     int64
-	Views     int64
-	Title     string
-	Body      string
+	PostID    int64
+	ParentID  pgtype.Int8
+	RootID    pgtype.Int8
+	Depth     int32
 	UserID    int64
+	Body      string
+	HiddenAt  pgtype.Timestamptz
+	HiddenBy  pgtype.Int8
+	EditedAt  pgtype.Timestamptz
 	CreatedAt pgtype.Timestamptz
 	UpdatedAt pgtype.Timestamptz
 	DeletedAt pgtype.Timestamptz
 }
 
+type OauthState struct {
+	State            string
+	IdentityProvider string
+	CodeVerifier     string
+	BrowserHash      string
+	CreatedAt        pgtype.Timestamptz
+	ExpiresAt        pgtype.Timestamptz
+}
+
+type Post struct {
+	ID             int64
+	Likes          int64
+	Views          int64
+	Title          string
+	Body           string
+	UserID         int64
+	Version        int64
+	Status         string
+	PublishedAt    pgtype.Timestamptz
+	Slug           string
+	BodyHtml       string
+	Toc            []byte
+	Excerpt        string
+	WordCount      int32
+	ReadingMinutes int32
+	CreatedAt      pgtype.Timestamptz
+	UpdatedAt      pgtype.Timestamptz
+	DeletedAt      pgtype.Timestamptz
+}
+
+type PostDailyView struct {
+	PostID int64
+	Day    pgtype.Date
+	Views  int64
+}
+
+type PostLike struct {
+	UserID    int64
+	PostID    int64
+	CreatedAt pgtype.Timestamptz
+}
+
+type PostRanking struct {
+	Kind   string
+	PostID int64
+	Score  float64
+}
+
+type PostRevision struct {
+	ID        int64
+	PostID    int64
+	Title     string
+	Body      string
+	EditorID  int64
+	CreatedAt pgtype.Timestamptz
+}
+
+type PostSlug struct {
+	Slug      string
+	PostID    pgtype.Int8
+	CreatedAt pgtype.Timestamptz
+}
+
+type PostTag struct {
+	PostID int64
+	TagID  int64
+}
+
+type RelatedPost struct {
+	PostID     int64
+	RelatedID  int64
+	Score      float64
+	ComputedAt pgtype.Timestamptz
+}
+
+type RevokedToken struct {
+	TokenID   string
+	ExpiresAt pgtype.Timestamptz
+}
+
+type Series struct {
+	ID          int64
+	UserID      int64
+	Title       string
+	Description string
+	CreatedAt   pgtype.Timestamptz
+	UpdatedAt   pgtype.Timestamptz
+}
+
+type SeriesPost struct {
+	SeriesID int64
+	PostID   int64
+	Position int32
+}
+
+type Session struct {
+	ID         int64
+	UserID     int64
+	UserAgent  string
+	IpAddress  string
+	CreatedAt  pgtype.Timestamptz
+	LastUsedAt pgtype.Timestamptz
+	ExpiresAt  pgtype.Timestamptz
+	RevokedAt  pgtype.Timestamptz
+}
+
+type SessionToken struct {
+	TokenHash string
+	SessionID int64
+	CreatedAt pgtype.Timestamptz
+	RotatedAt pgtype.Timestamptz
+}
+
+type Tag struct {
+	ID        int64
+	Name      string
+	CreatedAt pgtype.Timestamptz
+}
+
 type User struct {
 	ID               int64
 	IdentityProvider string
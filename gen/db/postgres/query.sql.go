@@ -11,6 +11,427 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const addDailyPostViews = `-- name: AddDailyPostViews :exec
+INSERT INTO post_daily_views (
+    post_id,
+    day,
+    views
+)
+SELECT v.post_id, $1::date, v.views
+FROM unnest($2::bigint[], $3::bigint[]) AS v (post_id, views)
+JOIN posts ON posts.id = v.post_id
+ON CONFLICT (post_id, day) DO UPDATE
+SET views = post_daily_views.views + excluded.views
+`
+
+type AddDailyPostViewsParams struct {
+	Day     pgtype.Date
+	PostIds []int64
+	Views   []int64
+}
+
+// Adds buffered view counts to the tallies of the day. Views of posts purged
+// in the meantime are dropped.
+func (q *Queries) AddDailyPostViews(ctx context.Context, arg AddDailyPostViewsParams) error {
+	_, err := q.db.Exec(ctx, addDailyPostViews, arg.Day, arg.PostIds, arg.Views)
+	return err
+}
+
+const addPostLikes = `-- name: AddPostLikes :one
+UPDATE posts
+SET likes = likes + $1::bigint
+WHERE id = $2
+RETURNING likes
+`
+
+type AddPostLikesParams struct {
+	Delta int64
+	ID    int64
+}
+
+func (q *Queries) AddPostLikes(ctx context.Context, arg AddPostLikesParams) (int64, error) {
+	row := q.db.QueryRow(ctx, addPostLikes, arg.Delta, arg.ID)
+	var likes int64
+	err := row.Scan(&likes)
+	return likes, err
+}
+
+const addPostSlug = `-- name: AddPostSlug :exec
+INSERT INTO post_slugs (
+    slug,
+    post_id,
+    created_at
+) VALUES (
+    $1, $2, $3
+)
+`
+
+type AddPostSlugParams struct {
+	Slug      string
+	PostID    pgtype.Int8
+	CreatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) AddPostSlug(ctx context.Context, arg AddPostSlugParams) error {
+	_, err := q.db.Exec(ctx, addPostSlug, arg.Slug, arg.PostID, arg.CreatedAt)
+	return err
+}
+
+const addPostTags = `-- name: AddPostTags :exec
+INSERT INTO post_tags (
+    post_id,
+    tag_id
+)
+SELECT $1::bigint, unnest($2::bigint[])
+ON CONFLICT DO NOTHING
+`
+
+type AddPostTagsParams struct {
+	PostID int64
+	TagIds []int64
+}
+
+func (q *Queries) AddPostTags(ctx context.Context, arg AddPostTagsParams) error {
+	_, err := q.db.Exec(ctx, addPostTags, arg.PostID, arg.TagIds)
+	return err
+}
+
+const addPostViews = `-- name: AddPostViews :exec
+UPDATE posts
+SET views = posts.views + v.views
+FROM unnest($1::bigint[], $2::bigint[]) AS v (post_id, views)
+WHERE posts.id = v.post_id
+`
+
+type AddPostViewsParams struct {
+	PostIds []int64
+	Views   []int64
+}
+
+// Adds buffered view counts in one statement. updated_at is left alone, as a
+// view does not change the post.
+func (q *Queries) AddPostViews(ctx context.Context, arg AddPostViewsParams) error {
+	_, err := q.db.Exec(ctx, addPostViews, arg.PostIds, arg.Views)
+	return err
+}
+
+const addSeriesPost = `-- name: AddSeriesPost :one
+INSERT INTO series_posts (
+    series_id,
+    post_id,
+    position
+)
+SELECT $1::bigint, $2::bigint, (coalesce(max(position), 0) + 1)::integer
+FROM series_posts
+WHERE series_id = $1::bigint
+RETURNING series_id, post_id, position
+`
+
+type AddSeriesPostParams struct {
+	SeriesID int64
+	PostID   int64
+}
+
+// Appends a post to the end of a series.
+func (q *Queries) AddSeriesPost(ctx context.Context, arg AddSeriesPostParams) (SeriesPost, error) {
+	row := q.db.QueryRow(ctx, addSeriesPost, arg.SeriesID, arg.PostID)
+	var i SeriesPost
+	err := row.Scan(
+		&i.SeriesID,
+		&i.PostID,
+		&i.Position,
+	)
+	return i, err
+}
+
+const clearPostRankings = `-- name: ClearPostRankings :exec
+DELETE FROM post_rankings
+`
+
+func (q *Queries) ClearPostRankings(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, clearPostRankings)
+	return err
+}
+
+const clearRelatedPosts = `-- name: ClearRelatedPosts :exec
+DELETE FROM related_posts
+`
+
+func (q *Queries) ClearRelatedPosts(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, clearRelatedPosts)
+	return err
+}
+
+const closeSeriesGap = `-- name: CloseSeriesGap :exec
+UPDATE series_posts
+SET position = position - 1
+WHERE series_id = $1
+AND position > $2
+`
+
+type CloseSeriesGapParams struct {
+	SeriesID int64
+	Position int32
+}
+
+// Moves the posts after a removed one up by one position.
+func (q *Queries) CloseSeriesGap(ctx context.Context, arg CloseSeriesGapParams) error {
+	_, err := q.db.Exec(ctx, closeSeriesGap, arg.SeriesID, arg.Position)
+	return err
+}
+
+const computePostRankings = `-- name: ComputePostRankings :execrows
+WITH activity AS (
+    SELECT post_id, day::timestamptz AS at, views::double precision AS points
+    FROM post_daily_views
+    WHERE day >= ($1::timestamptz - interval '30 days')::date
+    UNION ALL
+    SELECT post_id, created_at AS at, $2::double precision AS points
+    FROM post_likes
+    WHERE created_at >= $1::timestamptz - interval '30 days'
+),
+scores AS (
+    SELECT post_id,
+        sum(points * power(0.5, extract(epoch FROM $1::timestamptz - at) / $3::double precision)) AS trending,
+        coalesce(sum(points) FILTER (WHERE at >= $1::timestamptz - interval '7 days'), 0) AS week,
+        sum(points) AS month
+    FROM activity
+    GROUP BY post_id
+)
+INSERT INTO post_rankings (
+    kind,
+    post_id,
+    score
+)
+SELECT r.kind, posts.id, r.score
+FROM posts
+LEFT JOIN scores ON scores.post_id = posts.id
+CROSS JOIN LATERAL (
+    VALUES
+        ('trending', coalesce(scores.trending, 0)),
+        ('top-week', coalesce(scores.week, 0)),
+        ('top-month', coalesce(scores.month, 0)),
+        ('top-all', posts.views + $2::double precision * posts.likes)
+) AS r (kind, score)
+WHERE posts.deleted_at IS NULL
+AND posts.status = 'published'
+`
+
+type ComputePostRankingsParams struct {
+	Now             pgtype.Timestamptz
+	LikeWeight      float64
+	HalfLifeSeconds float64
+}
+
+// Scores every published post in each ranking. Views and weighted likes of
+// the last 30 days count towards trending, halving in weight every half
+// life, and in full towards the top posts of the week and month; top-all
+// uses the lifetime counters.
+func (q *Queries) ComputePostRankings(ctx context.Context, arg ComputePostRankingsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, computePostRankings, arg.Now, arg.LikeWeight, arg.HalfLifeSeconds)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const computeRelatedPosts = `-- name: ComputeRelatedPosts :execrows
+INSERT INTO related_posts (
+    post_id,
+    related_id,
+    score,
+    computed_at
+)
+SELECT post_id, related_id, score, $1
+FROM (
+    SELECT post_id, related_id, score,
+        row_number() OVER (PARTITION BY post_id ORDER BY score DESC, related_id DESC) AS rank
+    FROM (
+        SELECT p.id AS post_id, c.id AS related_id,
+            (
+                (
+                    SELECT count(*)
+                    FROM post_tags a
+                    JOIN post_tags b ON b.tag_id = a.tag_id
+                    WHERE a.post_id = p.id
+                    AND b.post_id = c.id
+                )
+                + 2 * similarity(p.title || ' ' || p.body, c.title || ' ' || c.body)
+                + CASE WHEN p.user_id = c.user_id THEN 0.5 ELSE 0 END
+            )::double precision AS score
+        FROM posts p
+        CROSS JOIN LATERAL (
+            SELECT b.post_id AS id
+            FROM post_tags a
+            JOIN post_tags b ON b.tag_id = a.tag_id
+            WHERE a.post_id = p.id
+            UNION
+            SELECT o.id
+            FROM posts o
+            WHERE o.user_id = p.user_id
+            UNION
+            SELECT o.id
+            FROM posts o
+            WHERE (o.title || ' ' || o.body) % (p.title || ' ' || p.body)
+        ) AS candidate
+        JOIN posts c ON c.id = candidate.id
+        WHERE p.id = ANY($2::bigint[])
+        AND p.deleted_at IS NULL
+        AND p.status = 'published'
+        AND c.id <> p.id
+        AND c.deleted_at IS NULL
+        AND c.status = 'published'
+    ) AS scored
+    WHERE score >= $3::double precision
+) AS ranked
+WHERE rank <= $4::integer
+`
+
+type ComputeRelatedPostsParams struct {
+	ComputedAt pgtype.Timestamptz
+	PostIds    []int64
+	MinScore   float64
+	PerPost    int32
+}
+
+// Scores the candidates of each of the given published posts by the tags
+// they share, the trigram similarity of their titles and bodies, and whether
+// they have the same author, and keeps the best per_post scoring at least
+// min_score. Candidates share a tag or the author, or match the post with %
+// on the trigram index; the others cannot score more than the similarity
+// threshold allows.
+func (q *Queries) ComputeRelatedPosts(ctx context.Context, arg ComputeRelatedPostsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, computeRelatedPosts,
+		arg.ComputedAt,
+		arg.PostIds,
+		arg.MinScore,
+		arg.PerPost,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const consumeOAuthState = `-- name: ConsumeOAuthState :one
+DELETE FROM oauth_states
+WHERE state = $1
+AND expires_at > $2
+RETURNING state, identity_provider, code_verifier, browser_hash, created_at, expires_at
+`
+
+type ConsumeOAuthStateParams struct {
+	State string
+	Now   pgtype.Timestamptz
+}
+
+// Deletes a state, so it cannot be used again, and returns it unless it
+// expired.
+func (q *Queries) ConsumeOAuthState(ctx context.Context, arg ConsumeOAuthStateParams) (OauthState, error) {
+	row := q.db.QueryRow(ctx, consumeOAuthState, arg.State, arg.Now)
+	var i OauthState
+	err := row.Scan(
+		&i.State,
+		&i.IdentityProvider,
+		&i.CodeVerifier,
+		&i.BrowserHash,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const createComment = `-- name: CreateComment :one
+INSERT INTO comments (
+    post_id,
+    parent_id,
+    root_id,
+    depth,
+    user_id,
+    body,
+    created_at,
+    updated_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8
+)
+RETURNING id, post_id, parent_id, root_id, depth, user_id, body, hidden_at, hidden_by, edited_at, created_at, updated_at, deleted_at
+`
+
+type CreateCommentParams struct {
+	PostID    int64
+	ParentID  pgtype.Int8
+	RootID    pgtype.Int8
+	Depth     int32
+	UserID    int64
+	Body      string
+	CreatedAt pgtype.Timestamptz
+	UpdatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) CreateComment(ctx context.Context, arg CreateCommentParams) (Comment, error) {
+	row := q.db.QueryRow(ctx, createComment,
+		arg.PostID,
+		arg.ParentID,
+		arg.RootID,
+		arg.Depth,
+		arg.UserID,
+		arg.Body,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+	)
+	var i Comment
+	err := row.Scan(
+		&i.ID,
+		&i.PostID,
+		&i.ParentID,
+		&i.RootID,
+		&i.Depth,
+		&i.UserID,
+		&i.Body,
+		&i.HiddenAt,
+		&i.HiddenBy,
+		&i.EditedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const createOAuthState = `-- name: CreateOAuthState :exec
+INSERT INTO oauth_states (
+    state,
+    identity_provider,
+    code_verifier,
+    browser_hash,
+    created_at,
+    expires_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+`
+
+type CreateOAuthStateParams struct {
+	State            string
+	IdentityProvider string
+	CodeVerifier     string
+	BrowserHash      string
+	CreatedAt        pgtype.Timestamptz
+	ExpiresAt        pgtype.Timestamptz
+}
+
+func (q *Queries) CreateOAuthState(ctx context.Context, arg CreateOAuthStateParams) error {
+	_, err := q.db.Exec(ctx, createOAuthState,
+		arg.State,
+		arg.IdentityProvider,
+		arg.CodeVerifier,
+		arg.BrowserHash,
+		arg.CreatedAt,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
 const createPost = `-- name: CreatePost :one
 INSERT INTO posts (
     likes,
@@ -18,22 +439,38 @@ INSERT INTO posts (
     title,
     body,
     user_id,
+    status,
+    published_at,
+    slug,
+    body_html,
+    toc,
+    excerpt,
+    word_count,
+    reading_minutes,
     created_at,
     updated_at
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15
 )
-RETURNING id, likes, views, title, body, user_id, created_at, updated_at, deleted_at
+RETURNING id, likes, views, title, body, user_id, version, status, published_at, slug, body_html, toc, excerpt, word_count, reading_minutes, created_at, updated_at, deleted_at
 `
 
 type CreatePostParams struct {
-	Likes     int64
-	Views     int64
-	Title     string
-	Body      string
-	UserID    int64
-	CreatedAt pgtype.Timestamptz
-	UpdatedAt pgtype.Timestamptz
+	Likes          int64
+	Views          int64
+	Title          string
+	Body           string
+	UserID         int64
+	Status         string
+	PublishedAt    pgtype.Timestamptz
+	Slug           string
+	BodyHtml       string
+	Toc            []byte
+	Excerpt        string
+	WordCount      int32
+	ReadingMinutes int32
+	CreatedAt      pgtype.Timestamptz
+	UpdatedAt      pgtype.Timestamptz
 }
 
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
@@ -43,6 +480,14 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.Title,
 		arg.Body,
 		arg.UserID,
+		arg.Status,
+		arg.PublishedAt,
+		arg.Slug,
+		arg.BodyHtml,
+		arg.Toc,
+		arg.Excerpt,
+		arg.WordCount,
+		arg.ReadingMinutes,
 		arg.CreatedAt,
 		arg.UpdatedAt,
 	)
@@ -54,6 +499,15 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Title,
 		&i.Body,
 		&i.UserID,
+		&i.Version,
+		&i.Status,
+		&i.PublishedAt,
+		&i.Slug,
+		&i.BodyHtml,
+		&i.Toc,
+		&i.Excerpt,
+		&i.WordCount,
+		&i.ReadingMinutes,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
@@ -61,6 +515,174 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 	return i, err
 }
 
+const createPostRevision = `-- name: CreatePostRevision :one
+INSERT INTO post_revisions (
+    post_id,
+    title,
+    body,
+    editor_id,
+    created_at
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+RETURNING id, post_id, title, body, editor_id, created_at
+`
+
+type CreatePostRevisionParams struct {
+	PostID    int64
+	Title     string
+	Body      string
+	EditorID  int64
+	CreatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) CreatePostRevision(ctx context.Context, arg CreatePostRevisionParams) (PostRevision, error) {
+	row := q.db.QueryRow(ctx, createPostRevision,
+		arg.PostID,
+		arg.Title,
+		arg.Body,
+		arg.EditorID,
+		arg.CreatedAt,
+	)
+	var i PostRevision
+	err := row.Scan(
+		&i.ID,
+		&i.PostID,
+		&i.Title,
+		&i.Body,
+		&i.EditorID,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createSeries = `-- name: CreateSeries :one
+INSERT INTO series (
+    user_id,
+    title,
+    description,
+    created_at,
+    updated_at
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+RETURNING id, user_id, title, description, created_at, updated_at
+`
+
+type CreateSeriesParams struct {
+	UserID      int64
+	Title       string
+	Description string
+	CreatedAt   pgtype.Timestamptz
+	UpdatedAt   pgtype.Timestamptz
+}
+
+func (q *Queries) CreateSeries(ctx context.Context, arg CreateSeriesParams) (Series, error) {
+	row := q.db.QueryRow(ctx, createSeries,
+		arg.UserID,
+		arg.Title,
+		arg.Description,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+	)
+	var i Series
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Title,
+		&i.Description,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createSession = `-- name: CreateSession :one
+INSERT INTO sessions (
+    user_id,
+    user_agent,
+    ip_address,
+    created_at,
+    last_used_at,
+    expires_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+RETURNING id, user_id, user_agent, ip_address, created_at, last_used_at, expires_at, revoked_at
+`
+
+type CreateSessionParams struct {
+	UserID     int64
+	UserAgent  string
+	IpAddress  string
+	CreatedAt  pgtype.Timestamptz
+	LastUsedAt pgtype.Timestamptz
+	ExpiresAt  pgtype.Timestamptz
+}
+
+func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error) {
+	row := q.db.QueryRow(ctx, createSession,
+		arg.UserID,
+		arg.UserAgent,
+		arg.IpAddress,
+		arg.CreatedAt,
+		arg.LastUsedAt,
+		arg.ExpiresAt,
+	)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.CreatedAt,
+		&i.LastUsedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const createSessionToken = `-- name: CreateSessionToken :exec
+INSERT INTO session_tokens (
+    token_hash,
+    session_id,
+    created_at
+) VALUES (
+    $1, $2, $3
+)
+`
+
+type CreateSessionTokenParams struct {
+	TokenHash string
+	SessionID int64
+	CreatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) CreateSessionToken(ctx context.Context, arg CreateSessionTokenParams) error {
+	_, err := q.db.Exec(ctx, createSessionToken, arg.TokenHash, arg.SessionID, arg.CreatedAt)
+	return err
+}
+
+const createTags = `-- name: CreateTags :exec
+INSERT INTO tags (
+    name,
+    created_at
+)
+SELECT unnest($1::text[]), $2::timestamptz
+ON CONFLICT (name) DO NOTHING
+`
+
+type CreateTagsParams struct {
+	Names     []string
+	CreatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) CreateTags(ctx context.Context, arg CreateTagsParams) error {
+	_, err := q.db.Exec(ctx, createTags, arg.Names, arg.CreatedAt)
+	return err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (
     identity_provider,
@@ -111,23 +733,66 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 	return i, err
 }
 
-const getPostById = `-- name: GetPostById :one
-SELECT id, likes, views, title, body, user_id, created_at, updated_at, deleted_at
-FROM posts
-WHERE deleted_at IS NULL
-AND id = $1
+const deleteExpiredOAuthStates = `-- name: DeleteExpiredOAuthStates :exec
+DELETE FROM oauth_states
+WHERE expires_at <= $1
 `
 
-func (q *Queries) GetPostById(ctx context.Context, id int64) (Post, error) {
-	row := q.db.QueryRow(ctx, getPostById, id)
-	var i Post
+func (q *Queries) DeleteExpiredOAuthStates(ctx context.Context, now pgtype.Timestamptz) error {
+	_, err := q.db.Exec(ctx, deleteExpiredOAuthStates, now)
+	return err
+}
+
+const deleteExpiredRevokedTokens = `-- name: DeleteExpiredRevokedTokens :exec
+DELETE FROM revoked_tokens
+WHERE expires_at <= $1
+`
+
+func (q *Queries) DeleteExpiredRevokedTokens(ctx context.Context, expiresAt pgtype.Timestamptz) error {
+	_, err := q.db.Exec(ctx, deleteExpiredRevokedTokens, expiresAt)
+	return err
+}
+
+const deletePostTags = `-- name: DeletePostTags :exec
+DELETE FROM post_tags
+WHERE post_id = $1
+`
+
+func (q *Queries) DeletePostTags(ctx context.Context, postID int64) error {
+	_, err := q.db.Exec(ctx, deletePostTags, postID)
+	return err
+}
+
+const deleteRelatedPosts = `-- name: DeleteRelatedPosts :exec
+DELETE FROM related_posts
+WHERE post_id = ANY($1::bigint[])
+`
+
+func (q *Queries) DeleteRelatedPosts(ctx context.Context, postIds []int64) error {
+	_, err := q.db.Exec(ctx, deleteRelatedPosts, postIds)
+	return err
+}
+
+const getCommentById = `-- name: GetCommentById :one
+SELECT id, post_id, parent_id, root_id, depth, user_id, body, hidden_at, hidden_by, edited_at, created_at, updated_at, deleted_at
+FROM comments
+WHERE id = $1
+`
+
+func (q *Queries) GetCommentById(ctx context.Context, id int64) (Comment, error) {
+	row := q.db.QueryRow(ctx, getCommentById, id)
+	var i Comment
 	err := row.Scan(
 		&i.ID,
-		&i.Likes,
-		&i.Views,
-		&i.Title,
-		&i.Body,
+		&i.PostID,
+		&i.ParentID,
+		&i.RootID,
+		&i.Depth,
 		&i.UserID,
+		&i.Body,
+		&i.HiddenAt,
+		&i.HiddenBy,
+		&i.EditedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
@@ -135,10 +800,330 @@ func (q *Queries) GetPostById(ctx context.Context, id int64) (Post, error) {
 	return i, err
 }
 
-const getUserByEmailAndIDP = `-- name: GetUserByEmailAndIDP :one
-SELECT id, identity_provider, email, username, avatar_url, about_me, created_at, updated_at, deleted_at
-FROM users
-WHERE deleted_at IS NULL
+const getCommentByIdForUpdate = `-- name: GetCommentByIdForUpdate :one
+SELECT id, post_id, parent_id, root_id, depth, user_id, body, hidden_at, hidden_by, edited_at, created_at, updated_at, deleted_at
+FROM comments
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetCommentByIdForUpdate(ctx context.Context, id int64) (Comment, error) {
+	row := q.db.QueryRow(ctx, getCommentByIdForUpdate, id)
+	var i Comment
+	err := row.Scan(
+		&i.ID,
+		&i.PostID,
+		&i.ParentID,
+		&i.RootID,
+		&i.Depth,
+		&i.UserID,
+		&i.Body,
+		&i.HiddenAt,
+		&i.HiddenBy,
+		&i.EditedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getDeletedPostByIdForUpdate = `-- name: GetDeletedPostByIdForUpdate :one
+SELECT id, likes, views, title, body, user_id, version, status, published_at, slug, body_html, toc, excerpt, word_count, reading_minutes, created_at, updated_at, deleted_at
+FROM posts
+WHERE deleted_at IS NOT NULL
+AND id = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetDeletedPostByIdForUpdate(ctx context.Context, id int64) (Post, error) {
+	row := q.db.QueryRow(ctx, getDeletedPostByIdForUpdate, id)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.Likes,
+		&i.Views,
+		&i.Title,
+		&i.Body,
+		&i.UserID,
+		&i.Version,
+		&i.Status,
+		&i.PublishedAt,
+		&i.Slug,
+		&i.BodyHtml,
+		&i.Toc,
+		&i.Excerpt,
+		&i.WordCount,
+		&i.ReadingMinutes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getPostById = `-- name: GetPostById :one
+SELECT id, likes, views, title, body, user_id, version, status, published_at, slug, body_html, toc, excerpt, word_count, reading_minutes, created_at, updated_at, deleted_at
+FROM posts
+WHERE deleted_at IS NULL
+AND id = $1
+AND (status = 'published' OR user_id = $2)
+`
+
+type GetPostByIdParams struct {
+	ID       int64
+	ViewerID int64
+}
+
+func (q *Queries) GetPostById(ctx context.Context, arg GetPostByIdParams) (Post, error) {
+	row := q.db.QueryRow(ctx, getPostById, arg.ID, arg.ViewerID)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.Likes,
+		&i.Views,
+		&i.Title,
+		&i.Body,
+		&i.UserID,
+		&i.Version,
+		&i.Status,
+		&i.PublishedAt,
+		&i.Slug,
+		&i.BodyHtml,
+		&i.Toc,
+		&i.Excerpt,
+		&i.WordCount,
+		&i.ReadingMinutes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getPostByIdForUpdate = `-- name: GetPostByIdForUpdate :one
+SELECT id, likes, views, title, body, user_id, version, status, published_at, slug, body_html, toc, excerpt, word_count, reading_minutes, created_at, updated_at, deleted_at
+FROM posts
+WHERE deleted_at IS NULL
+AND id = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetPostByIdForUpdate(ctx context.Context, id int64) (Post, error) {
+	row := q.db.QueryRow(ctx, getPostByIdForUpdate, id)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.Likes,
+		&i.Views,
+		&i.Title,
+		&i.Body,
+		&i.UserID,
+		&i.Version,
+		&i.Status,
+		&i.PublishedAt,
+		&i.Slug,
+		&i.BodyHtml,
+		&i.Toc,
+		&i.Excerpt,
+		&i.WordCount,
+		&i.ReadingMinutes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getPostBySlug = `-- name: GetPostBySlug :one
+SELECT posts.id, posts.likes, posts.views, posts.title, posts.body, posts.user_id, posts.version, posts.status, posts.published_at, posts.slug, posts.body_html, posts.toc, posts.excerpt, posts.word_count, posts.reading_minutes, posts.created_at, posts.updated_at, posts.deleted_at
+FROM post_slugs
+JOIN posts ON posts.id = post_slugs.post_id
+WHERE post_slugs.slug = $1
+AND posts.deleted_at IS NULL
+AND (posts.status = 'published' OR posts.user_id = $2)
+`
+
+type GetPostBySlugParams struct {
+	Slug     string
+	ViewerID int64
+}
+
+// Finds a post by its current or any of its former slugs.
+func (q *Queries) GetPostBySlug(ctx context.Context, arg GetPostBySlugParams) (Post, error) {
+	row := q.db.QueryRow(ctx, getPostBySlug, arg.Slug, arg.ViewerID)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.Likes,
+		&i.Views,
+		&i.Title,
+		&i.Body,
+		&i.UserID,
+		&i.Version,
+		&i.Status,
+		&i.PublishedAt,
+		&i.Slug,
+		&i.BodyHtml,
+		&i.Toc,
+		&i.Excerpt,
+		&i.WordCount,
+		&i.ReadingMinutes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getPostRevision = `-- name: GetPostRevision :one
+SELECT id, post_id, title, body, editor_id, created_at
+FROM post_revisions
+WHERE post_id = $1
+AND id = $2
+`
+
+type GetPostRevisionParams struct {
+	PostID int64
+	ID     int64
+}
+
+func (q *Queries) GetPostRevision(ctx context.Context, arg GetPostRevisionParams) (PostRevision, error) {
+	row := q.db.QueryRow(ctx, getPostRevision, arg.PostID, arg.ID)
+	var i PostRevision
+	err := row.Scan(
+		&i.ID,
+		&i.PostID,
+		&i.Title,
+		&i.Body,
+		&i.EditorID,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getSeriesById = `-- name: GetSeriesById :one
+SELECT id, user_id, title, description, created_at, updated_at
+FROM series
+WHERE id = $1
+`
+
+func (q *Queries) GetSeriesById(ctx context.Context, id int64) (Series, error) {
+	row := q.db.QueryRow(ctx, getSeriesById, id)
+	var i Series
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Title,
+		&i.Description,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getSeriesByIdForUpdate = `-- name: GetSeriesByIdForUpdate :one
+SELECT id, user_id, title, description, created_at, updated_at
+FROM series
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetSeriesByIdForUpdate(ctx context.Context, id int64) (Series, error) {
+	row := q.db.QueryRow(ctx, getSeriesByIdForUpdate, id)
+	var i Series
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Title,
+		&i.Description,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getSeriesByPostId = `-- name: GetSeriesByPostId :one
+SELECT series.id, series.user_id, series.title, series.description, series.created_at, series.updated_at
+FROM series_posts
+JOIN series ON series.id = series_posts.series_id
+WHERE series_posts.post_id = $1
+`
+
+func (q *Queries) GetSeriesByPostId(ctx context.Context, postID int64) (Series, error) {
+	row := q.db.QueryRow(ctx, getSeriesByPostId, postID)
+	var i Series
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Title,
+		&i.Description,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getSession = `-- name: GetSession :one
+SELECT id, user_id, user_agent, ip_address, created_at, last_used_at, expires_at, revoked_at FROM sessions
+WHERE id = $1
+`
+
+func (q *Queries) GetSession(ctx context.Context, id int64) (Session, error) {
+	row := q.db.QueryRow(ctx, getSession, id)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.CreatedAt,
+		&i.LastUsedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const getSessionToken = `-- name: GetSessionToken :one
+SELECT token_hash, session_id, created_at, rotated_at FROM session_tokens
+WHERE token_hash = $1
+`
+
+func (q *Queries) GetSessionToken(ctx context.Context, tokenHash string) (SessionToken, error) {
+	row := q.db.QueryRow(ctx, getSessionToken, tokenHash)
+	var i SessionToken
+	err := row.Scan(
+		&i.TokenHash,
+		&i.SessionID,
+		&i.CreatedAt,
+		&i.RotatedAt,
+	)
+	return i, err
+}
+
+const getSessionTokenForUpdate = `-- name: GetSessionTokenForUpdate :one
+SELECT token_hash, session_id, created_at, rotated_at FROM session_tokens
+WHERE token_hash = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetSessionTokenForUpdate(ctx context.Context, tokenHash string) (SessionToken, error) {
+	row := q.db.QueryRow(ctx, getSessionTokenForUpdate, tokenHash)
+	var i SessionToken
+	err := row.Scan(
+		&i.TokenHash,
+		&i.SessionID,
+		&i.CreatedAt,
+		&i.RotatedAt,
+	)
+	return i, err
+}
+
+const getUserByEmailAndIDP = `-- name: GetUserByEmailAndIDP :one
+SELECT id, identity_provider, email, username, avatar_url, about_me, created_at, updated_at, deleted_at
+FROM users
+WHERE deleted_at IS NULL
 AND email = $1
 AND identity_provider = $2
 `
@@ -188,22 +1173,157 @@ func (q *Queries) GetUserById(ctx context.Context, id int64) (User, error) {
 	return i, err
 }
 
-const listRecentPosts = `-- name: ListRecentPosts :many
-SELECT id, likes, views, title, body, user_id, created_at, updated_at, deleted_at
+const likePost = `-- name: LikePost :execrows
+INSERT INTO post_likes (
+    user_id,
+    post_id,
+    created_at
+) VALUES (
+    $1, $2, $3
+)
+ON CONFLICT DO NOTHING
+`
+
+type LikePostParams struct {
+	UserID    int64
+	PostID    int64
+	CreatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) LikePost(ctx context.Context, arg LikePostParams) (int64, error) {
+	result, err := q.db.Exec(ctx, likePost, arg.UserID, arg.PostID, arg.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const listActiveSessions = `-- name: ListActiveSessions :many
+SELECT id, user_id, user_agent, ip_address, created_at, last_used_at, expires_at, revoked_at FROM sessions
+WHERE user_id = $1
+AND revoked_at IS NULL
+AND expires_at > $2
+ORDER BY last_used_at DESC, id DESC
+`
+
+type ListActiveSessionsParams struct {
+	UserID int64
+	Now    pgtype.Timestamptz
+}
+
+func (q *Queries) ListActiveSessions(ctx context.Context, arg ListActiveSessionsParams) ([]Session, error) {
+	rows, err := q.db.Query(ctx, listActiveSessions, arg.UserID, arg.Now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Session
+	for rows.Next() {
+		var i Session
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.CreatedAt,
+			&i.LastUsedAt,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCommentReplies = `-- name: ListCommentReplies :many
+SELECT c.id, c.post_id, c.parent_id, c.root_id, c.depth, c.user_id, c.body, c.hidden_at, c.hidden_by, c.edited_at, c.created_at, c.updated_at, c.deleted_at
+FROM unnest($1::bigint[]) AS r (id)
+CROSS JOIN LATERAL (
+    SELECT id, post_id, parent_id, root_id, depth, user_id, body, hidden_at, hidden_by, edited_at, created_at, updated_at, deleted_at
+    FROM comments
+    WHERE root_id = r.id
+    ORDER BY id
+    LIMIT $2
+) AS c
+ORDER BY c.id
+`
+
+type ListCommentRepliesParams struct {
+	RootIds    []int64
+	ThreadSize int32
+}
+
+// Lists the first replies, at most thread_size each, in the threads started
+// by the given top-level comments.
+func (q *Queries) ListCommentReplies(ctx context.Context, arg ListCommentRepliesParams) ([]Comment, error) {
+	rows, err := q.db.Query(ctx, listCommentReplies, arg.RootIds, arg.ThreadSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Comment
+	for rows.Next() {
+		var i Comment
+		if err := rows.Scan(
+			&i.ID,
+			&i.PostID,
+			&i.ParentID,
+			&i.RootID,
+			&i.Depth,
+			&i.UserID,
+			&i.Body,
+			&i.HiddenAt,
+			&i.HiddenBy,
+			&i.EditedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDeletedPostsByUser = `-- name: ListDeletedPostsByUser :many
+SELECT id, likes, views, title, body, user_id, version, status, published_at, slug, body_html, toc, excerpt, word_count, reading_minutes, created_at, updated_at, deleted_at
 FROM posts
-WHERE deleted_at IS NULL
-AND id > $2
-ORDER BY updated_at DESC
-LIMIT $1
+WHERE deleted_at IS NOT NULL
+AND user_id = $1
+AND (
+    $2::timestamptz IS NULL
+    OR (deleted_at, id) < ($2::timestamptz, $3::bigint)
+)
+ORDER BY deleted_at DESC, id DESC
+LIMIT $4
 `
 
-type ListRecentPostsParams struct {
-	Limit  int32
-	Cursor int64
+type ListDeletedPostsByUserParams struct {
+	UserID     int64
+	CursorTime pgtype.Timestamptz
+	CursorID   int64
+	PageSize   int32
 }
 
-func (q *Queries) ListRecentPosts(ctx context.Context, arg ListRecentPostsParams) ([]Post, error) {
-	rows, err := q.db.Query(ctx, listRecentPosts, arg.Limit, arg.Cursor)
+// Pages through a user's trash from the most recently deleted post,
+// starting after the (deleted_at, id) keyset of the cursor, or from the top
+// without one.
+func (q *Queries) ListDeletedPostsByUser(ctx context.Context, arg ListDeletedPostsByUserParams) ([]Post, error) {
+	rows, err := q.db.Query(ctx, listDeletedPostsByUser,
+		arg.UserID,
+		arg.CursorTime,
+		arg.CursorID,
+		arg.PageSize,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -218,6 +1338,15 @@ func (q *Queries) ListRecentPosts(ctx context.Context, arg ListRecentPostsParams
 			&i.Title,
 			&i.Body,
 			&i.UserID,
+			&i.Version,
+			&i.Status,
+			&i.PublishedAt,
+			&i.Slug,
+			&i.BodyHtml,
+			&i.Toc,
+			&i.Excerpt,
+			&i.WordCount,
+			&i.ReadingMinutes,
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.DeletedAt,
@@ -231,3 +1360,1542 @@ func (q *Queries) ListRecentPosts(ctx context.Context, arg ListRecentPostsParams
 	}
 	return items, nil
 }
+
+const listLikedPostIds = `-- name: ListLikedPostIds :many
+SELECT post_id
+FROM post_likes
+WHERE user_id = $1
+AND post_id = ANY($2::bigint[])
+`
+
+type ListLikedPostIdsParams struct {
+	UserID  int64
+	PostIds []int64
+}
+
+func (q *Queries) ListLikedPostIds(ctx context.Context, arg ListLikedPostIdsParams) ([]int64, error) {
+	rows, err := q.db.Query(ctx, listLikedPostIds, arg.UserID, arg.PostIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var post_id int64
+		if err := rows.Scan(&post_id); err != nil {
+			return nil, err
+		}
+		items = append(items, post_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listNewerPosts = `-- name: ListNewerPosts :many
+SELECT id, likes, views, title, body, user_id, version, status, published_at, slug, body_html, toc, excerpt, word_count, reading_minutes, created_at, updated_at, deleted_at
+FROM posts
+WHERE deleted_at IS NULL
+AND (status = 'published' OR user_id = $1)
+AND (
+    coalesce(cardinality($2::text[]), 0) = 0
+    OR (
+        SELECT COUNT(*)
+        FROM post_tags
+        JOIN tags ON tags.id = post_tags.tag_id
+        WHERE post_tags.post_id = posts.id
+        AND tags.name = ANY($2::text[])
+    ) >= CASE WHEN $3::boolean THEN cardinality($2::text[]) ELSE 1 END
+)
+AND (updated_at, id) > ($4::timestamptz, $5::bigint)
+ORDER BY updated_at ASC, id ASC
+LIMIT $6
+`
+
+type ListNewerPostsParams struct {
+	ViewerID   int64
+	Tags       []string
+	MatchAll   bool
+	CursorTime pgtype.Timestamptz
+	CursorID   int64
+	PageSize   int32
+}
+
+// Pages back towards newer posts than the cursor, nearest first.
+func (q *Queries) ListNewerPosts(ctx context.Context, arg ListNewerPostsParams) ([]Post, error) {
+	rows, err := q.db.Query(ctx, listNewerPosts,
+		arg.ViewerID,
+		arg.Tags,
+		arg.MatchAll,
+		arg.CursorTime,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.Likes,
+			&i.Views,
+			&i.Title,
+			&i.Body,
+			&i.UserID,
+			&i.Version,
+			&i.Status,
+			&i.PublishedAt,
+			&i.Slug,
+			&i.BodyHtml,
+			&i.Toc,
+			&i.Excerpt,
+			&i.WordCount,
+			&i.ReadingMinutes,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPostRevisions = `-- name: ListPostRevisions :many
+SELECT id, post_id, title, body, editor_id, created_at
+FROM post_revisions
+WHERE post_id = $1
+AND ($2::bigint = 0 OR id < $2)
+ORDER BY id DESC
+LIMIT $3
+`
+
+type ListPostRevisionsParams struct {
+	PostID   int64
+	Cursor   int64
+	PageSize int32
+}
+
+func (q *Queries) ListPostRevisions(ctx context.Context, arg ListPostRevisionsParams) ([]PostRevision, error) {
+	rows, err := q.db.Query(ctx, listPostRevisions, arg.PostID, arg.Cursor, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PostRevision
+	for rows.Next() {
+		var i PostRevision
+		if err := rows.Scan(
+			&i.ID,
+			&i.PostID,
+			&i.Title,
+			&i.Body,
+			&i.EditorID,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPublishedPostIds = `-- name: ListPublishedPostIds :many
+SELECT id
+FROM posts
+WHERE deleted_at IS NULL
+AND status = 'published'
+ORDER BY id
+`
+
+func (q *Queries) ListPublishedPostIds(ctx context.Context) ([]int64, error) {
+	rows, err := q.db.Query(ctx, listPublishedPostIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRankedPosts = `-- name: ListRankedPosts :many
+SELECT posts.id, posts.likes, posts.views, posts.title, posts.body, posts.user_id, posts.version, posts.status, posts.published_at, posts.slug, posts.body_html, posts.toc, posts.excerpt, posts.word_count, posts.reading_minutes, posts.created_at, posts.updated_at, posts.deleted_at
+FROM post_rankings
+JOIN posts ON posts.id = post_rankings.post_id
+WHERE post_rankings.kind = $1
+AND posts.deleted_at IS NULL
+AND posts.status = 'published'
+ORDER BY post_rankings.score DESC, post_rankings.post_id DESC
+LIMIT $2
+OFFSET $3
+`
+
+type ListRankedPostsParams struct {
+	Kind       string
+	PageSize   int32
+	PageOffset int32
+}
+
+func (q *Queries) ListRankedPosts(ctx context.Context, arg ListRankedPostsParams) ([]Post, error) {
+	rows, err := q.db.Query(ctx, listRankedPosts, arg.Kind, arg.PageSize, arg.PageOffset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.Likes,
+			&i.Views,
+			&i.Title,
+			&i.Body,
+			&i.UserID,
+			&i.Version,
+			&i.Status,
+			&i.PublishedAt,
+			&i.Slug,
+			&i.BodyHtml,
+			&i.Toc,
+			&i.Excerpt,
+			&i.WordCount,
+			&i.ReadingMinutes,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRecentPosts = `-- name: ListRecentPosts :many
+SELECT id, likes, views, title, body, user_id, version, status, published_at, slug, body_html, toc, excerpt, word_count, reading_minutes, created_at, updated_at, deleted_at
+FROM posts
+WHERE deleted_at IS NULL
+AND (status = 'published' OR user_id = $1)
+AND (
+    coalesce(cardinality($2::text[]), 0) = 0
+    OR (
+        SELECT COUNT(*)
+        FROM post_tags
+        JOIN tags ON tags.id = post_tags.tag_id
+        WHERE post_tags.post_id = posts.id
+        AND tags.name = ANY($2::text[])
+    ) >= CASE WHEN $3::boolean THEN cardinality($2::text[]) ELSE 1 END
+)
+AND ($4::bigint IS NULL OR user_id = $4::bigint)
+AND (
+    $5::timestamptz IS NULL
+    OR (updated_at, id) < ($5::timestamptz, $6::bigint)
+)
+ORDER BY updated_at DESC, id DESC
+LIMIT $7
+`
+
+type ListRecentPostsParams struct {
+	ViewerID   int64
+	Tags       []string
+	MatchAll   bool
+	AuthorID   pgtype.Int8
+	CursorTime pgtype.Timestamptz
+	CursorID   int64
+	PageSize   int32
+}
+
+// Pages through posts from newest to oldest, starting after the
+// (updated_at, id) keyset of the cursor, or from the top without one.
+// author_id optionally limits the page to one author's posts.
+func (q *Queries) ListRecentPosts(ctx context.Context, arg ListRecentPostsParams) ([]Post, error) {
+	rows, err := q.db.Query(ctx, listRecentPosts,
+		arg.ViewerID,
+		arg.Tags,
+		arg.MatchAll,
+		arg.AuthorID,
+		arg.CursorTime,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.Likes,
+			&i.Views,
+			&i.Title,
+			&i.Body,
+			&i.UserID,
+			&i.Version,
+			&i.Status,
+			&i.PublishedAt,
+			&i.Slug,
+			&i.BodyHtml,
+			&i.Toc,
+			&i.Excerpt,
+			&i.WordCount,
+			&i.ReadingMinutes,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRelatedPosts = `-- name: ListRelatedPosts :many
+SELECT posts.id, posts.slug, posts.title, posts.excerpt
+FROM related_posts
+JOIN posts ON posts.id = related_posts.related_id
+WHERE related_posts.post_id = $1
+AND posts.deleted_at IS NULL
+AND posts.status = 'published'
+ORDER BY related_posts.score DESC, related_posts.related_id DESC
+LIMIT $2
+`
+
+type ListRelatedPostsParams struct {
+	PostID   int64
+	PageSize int32
+}
+
+type ListRelatedPostsRow struct {
+	ID      int64
+	Slug    string
+	Title   string
+	Excerpt string
+}
+
+func (q *Queries) ListRelatedPosts(ctx context.Context, arg ListRelatedPostsParams) ([]ListRelatedPostsRow, error) {
+	rows, err := q.db.Query(ctx, listRelatedPosts, arg.PostID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListRelatedPostsRow
+	for rows.Next() {
+		var i ListRelatedPostsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Slug,
+			&i.Title,
+			&i.Excerpt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRelatedPostsAffectedBy = `-- name: ListRelatedPostsAffectedBy :many
+SELECT changed.id
+FROM unnest($1::bigint[]) AS changed (id)
+UNION
+SELECT related_posts.post_id
+FROM related_posts
+WHERE related_posts.related_id = ANY($1::bigint[])
+UNION
+SELECT b.post_id
+FROM post_tags a
+JOIN post_tags b ON b.tag_id = a.tag_id
+WHERE a.post_id = ANY($1::bigint[])
+UNION
+SELECT c.id
+FROM posts p
+JOIN posts c ON c.user_id = p.user_id
+WHERE p.id = ANY($1::bigint[])
+UNION
+SELECT c.id
+FROM posts p
+JOIN posts c ON (c.title || ' ' || c.body) % (p.title || ' ' || p.body)
+WHERE p.id = ANY($1::bigint[])
+`
+
+// Lists the posts whose related posts may change when the given posts
+// changed: the posts themselves, the posts listing them, and the posts that
+// share a tag or the author with them or whose text is similar to theirs.
+func (q *Queries) ListRelatedPostsAffectedBy(ctx context.Context, postIds []int64) ([]int64, error) {
+	rows, err := q.db.Query(ctx, listRelatedPostsAffectedBy, postIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRevokedTokens = `-- name: ListRevokedTokens :many
+SELECT token_id, expires_at FROM revoked_tokens
+WHERE expires_at > $1
+`
+
+func (q *Queries) ListRevokedTokens(ctx context.Context, expiresAt pgtype.Timestamptz) ([]RevokedToken, error) {
+	rows, err := q.db.Query(ctx, listRevokedTokens, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RevokedToken
+	for rows.Next() {
+		var i RevokedToken
+		if err := rows.Scan(
+			&i.TokenID,
+			&i.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRootComments = `-- name: ListRootComments :many
+SELECT id, post_id, parent_id, root_id, depth, user_id, body, hidden_at, hidden_by, edited_at, created_at, updated_at, deleted_at
+FROM comments
+WHERE post_id = $1
+AND parent_id IS NULL
+AND id > $2
+ORDER BY id
+LIMIT $3
+`
+
+type ListRootCommentsParams struct {
+	PostID   int64
+	Cursor   int64
+	PageSize int32
+}
+
+// Pages through the top-level comments of a post, oldest first.
+func (q *Queries) ListRootComments(ctx context.Context, arg ListRootCommentsParams) ([]Comment, error) {
+	rows, err := q.db.Query(ctx, listRootComments, arg.PostID, arg.Cursor, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Comment
+	for rows.Next() {
+		var i Comment
+		if err := rows.Scan(
+			&i.ID,
+			&i.PostID,
+			&i.ParentID,
+			&i.RootID,
+			&i.Depth,
+			&i.UserID,
+			&i.Body,
+			&i.HiddenAt,
+			&i.HiddenBy,
+			&i.EditedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSeriesPostIds = `-- name: ListSeriesPostIds :many
+SELECT post_id
+FROM series_posts
+WHERE series_id = $1
+ORDER BY position
+`
+
+func (q *Queries) ListSeriesPostIds(ctx context.Context, seriesID int64) ([]int64, error) {
+	rows, err := q.db.Query(ctx, listSeriesPostIds, seriesID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var post_id int64
+		if err := rows.Scan(&post_id); err != nil {
+			return nil, err
+		}
+		items = append(items, post_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSeriesPosts = `-- name: ListSeriesPosts :many
+SELECT posts.id, posts.likes, posts.views, posts.title, posts.body, posts.user_id, posts.version, posts.status, posts.published_at, posts.slug, posts.body_html, posts.toc, posts.excerpt, posts.word_count, posts.reading_minutes, posts.created_at, posts.updated_at, posts.deleted_at
+FROM series_posts
+JOIN posts ON posts.id = series_posts.post_id
+WHERE series_posts.series_id = $1
+AND posts.deleted_at IS NULL
+AND (posts.status = 'published' OR posts.user_id = $2)
+ORDER BY series_posts.position
+`
+
+type ListSeriesPostsParams struct {
+	SeriesID int64
+	ViewerID int64
+}
+
+// Lists the posts of a series the viewer can read, in series order.
+func (q *Queries) ListSeriesPosts(ctx context.Context, arg ListSeriesPostsParams) ([]Post, error) {
+	rows, err := q.db.Query(ctx, listSeriesPosts, arg.SeriesID, arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.Likes,
+			&i.Views,
+			&i.Title,
+			&i.Body,
+			&i.UserID,
+			&i.Version,
+			&i.Status,
+			&i.PublishedAt,
+			&i.Slug,
+			&i.BodyHtml,
+			&i.Toc,
+			&i.Excerpt,
+			&i.WordCount,
+			&i.ReadingMinutes,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSitemapAuthors = `-- name: ListSitemapAuthors :many
+SELECT posts.user_id, max(posts.updated_at)::timestamptz AS updated_at
+FROM posts
+JOIN users ON users.id = posts.user_id
+WHERE posts.deleted_at IS NULL
+AND posts.status = 'published'
+AND users.deleted_at IS NULL
+GROUP BY posts.user_id
+ORDER BY posts.user_id
+`
+
+type ListSitemapAuthorsRow struct {
+	UserID    int64
+	UpdatedAt pgtype.Timestamptz
+}
+
+// Lists every author with a published post, with the time their latest
+// published post changed.
+func (q *Queries) ListSitemapAuthors(ctx context.Context) ([]ListSitemapAuthorsRow, error) {
+	rows, err := q.db.Query(ctx, listSitemapAuthors)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSitemapAuthorsRow
+	for rows.Next() {
+		var i ListSitemapAuthorsRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSitemapPosts = `-- name: ListSitemapPosts :many
+SELECT id, slug, updated_at
+FROM posts
+WHERE deleted_at IS NULL
+AND status = 'published'
+ORDER BY id
+`
+
+type ListSitemapPostsRow struct {
+	ID        int64
+	Slug      string
+	UpdatedAt pgtype.Timestamptz
+}
+
+// Lists every published post for the sitemap.
+func (q *Queries) ListSitemapPosts(ctx context.Context) ([]ListSitemapPostsRow, error) {
+	rows, err := q.db.Query(ctx, listSitemapPosts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSitemapPostsRow
+	for rows.Next() {
+		var i ListSitemapPostsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Slug,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSlugsByBase = `-- name: ListSlugsByBase :many
+SELECT slug, post_id
+FROM post_slugs
+WHERE slug = $1::text
+OR slug LIKE $1::text || '-%'
+`
+
+type ListSlugsByBaseRow struct {
+	Slug   string
+	PostID pgtype.Int8
+}
+
+// Lists the slugs derived from base: base itself and base-2, base-3, ….
+func (q *Queries) ListSlugsByBase(ctx context.Context, base string) ([]ListSlugsByBaseRow, error) {
+	rows, err := q.db.Query(ctx, listSlugsByBase, base)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSlugsByBaseRow
+	for rows.Next() {
+		var i ListSlugsByBaseRow
+		if err := rows.Scan(
+			&i.Slug,
+			&i.PostID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTagCounts = `-- name: ListTagCounts :many
+SELECT tags.name, COUNT(*) AS post_count
+FROM tags
+JOIN post_tags ON post_tags.tag_id = tags.id
+JOIN posts ON posts.id = post_tags.post_id
+WHERE posts.deleted_at IS NULL
+AND posts.status = 'published'
+GROUP BY tags.name
+ORDER BY post_count DESC, tags.name
+LIMIT $1
+`
+
+type ListTagCountsRow struct {
+	Name      string
+	PostCount int64
+}
+
+func (q *Queries) ListTagCounts(ctx context.Context, pageSize int32) ([]ListTagCountsRow, error) {
+	rows, err := q.db.Query(ctx, listTagCounts, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTagCountsRow
+	for rows.Next() {
+		var i ListTagCountsRow
+		if err := rows.Scan(
+			&i.Name,
+			&i.PostCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTagsByName = `-- name: ListTagsByName :many
+SELECT id, name, created_at
+FROM tags
+WHERE name = ANY($1::text[])
+`
+
+func (q *Queries) ListTagsByName(ctx context.Context, names []string) ([]Tag, error) {
+	rows, err := q.db.Query(ctx, listTagsByName, names)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Tag
+	for rows.Next() {
+		var i Tag
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTagsByPostIds = `-- name: ListTagsByPostIds :many
+SELECT post_tags.post_id, tags.name
+FROM post_tags
+JOIN tags ON tags.id = post_tags.tag_id
+WHERE post_tags.post_id = ANY($1::bigint[])
+ORDER BY tags.name
+`
+
+type ListTagsByPostIdsRow struct {
+	PostID int64
+	Name   string
+}
+
+func (q *Queries) ListTagsByPostIds(ctx context.Context, postIds []int64) ([]ListTagsByPostIdsRow, error) {
+	rows, err := q.db.Query(ctx, listTagsByPostIds, postIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTagsByPostIdsRow
+	for rows.Next() {
+		var i ListTagsByPostIdsRow
+		if err := rows.Scan(
+			&i.PostID,
+			&i.Name,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listThreadReplies = `-- name: ListThreadReplies :many
+SELECT id, post_id, parent_id, root_id, depth, user_id, body, hidden_at, hidden_by, edited_at, created_at, updated_at, deleted_at
+FROM comments
+WHERE root_id = $1
+AND id > $2
+ORDER BY id
+LIMIT $3
+`
+
+type ListThreadRepliesParams struct {
+	RootID   int64
+	Cursor   int64
+	PageSize int32
+}
+
+// Pages through the replies in the thread started by a top-level comment,
+// oldest first.
+func (q *Queries) ListThreadReplies(ctx context.Context, arg ListThreadRepliesParams) ([]Comment, error) {
+	rows, err := q.db.Query(ctx, listThreadReplies, arg.RootID, arg.Cursor, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Comment
+	for rows.Next() {
+		var i Comment
+		if err := rows.Scan(
+			&i.ID,
+			&i.PostID,
+			&i.ParentID,
+			&i.RootID,
+			&i.Depth,
+			&i.UserID,
+			&i.Body,
+			&i.HiddenAt,
+			&i.HiddenBy,
+			&i.EditedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersByIds = `-- name: ListUsersByIds :many
+SELECT id, identity_provider, email, username, avatar_url, about_me, created_at, updated_at, deleted_at
+FROM users
+WHERE deleted_at IS NULL
+AND id = ANY($1::bigint[])
+`
+
+func (q *Queries) ListUsersByIds(ctx context.Context, ids []int64) ([]User, error) {
+	rows, err := q.db.Query(ctx, listUsersByIds, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.IdentityProvider,
+			&i.Email,
+			&i.Username,
+			&i.AvatarUrl,
+			&i.AboutMe,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const publishDuePosts = `-- name: PublishDuePosts :many
+UPDATE posts
+SET status = 'published',
+    updated_at = published_at,
+    version = version + 1
+WHERE deleted_at IS NULL
+AND status = 'scheduled'
+AND published_at <= $1
+RETURNING id
+`
+
+func (q *Queries) PublishDuePosts(ctx context.Context, now pgtype.Timestamptz) ([]int64, error) {
+	rows, err := q.db.Query(ctx, publishDuePosts, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeDailyPostViews = `-- name: PurgeDailyPostViews :exec
+DELETE FROM post_daily_views
+WHERE day < $1::date
+`
+
+func (q *Queries) PurgeDailyPostViews(ctx context.Context, before pgtype.Date) error {
+	_, err := q.db.Exec(ctx, purgeDailyPostViews, before)
+	return err
+}
+
+const purgeDeletedPosts = `-- name: PurgeDeletedPosts :execrows
+DELETE FROM posts
+WHERE deleted_at IS NOT NULL
+AND deleted_at < $1
+`
+
+func (q *Queries) PurgeDeletedPosts(ctx context.Context, deletedBefore pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeDeletedPosts, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const purgeSessions = `-- name: PurgeSessions :execrows
+DELETE FROM sessions
+WHERE expires_at <= $1
+`
+
+// Deletes expired sessions along with their tokens. Revoked sessions are kept
+// until they would have expired, so their rotated tokens are still known.
+func (q *Queries) PurgeSessions(ctx context.Context, now pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeSessions, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const removeSeriesPost = `-- name: RemoveSeriesPost :one
+DELETE FROM series_posts
+WHERE series_id = $1
+AND post_id = $2
+RETURNING series_id, post_id, position
+`
+
+type RemoveSeriesPostParams struct {
+	SeriesID int64
+	PostID   int64
+}
+
+func (q *Queries) RemoveSeriesPost(ctx context.Context, arg RemoveSeriesPostParams) (SeriesPost, error) {
+	row := q.db.QueryRow(ctx, removeSeriesPost, arg.SeriesID, arg.PostID)
+	var i SeriesPost
+	err := row.Scan(
+		&i.SeriesID,
+		&i.PostID,
+		&i.Position,
+	)
+	return i, err
+}
+
+const reorderSeriesPosts = `-- name: ReorderSeriesPosts :exec
+UPDATE series_posts
+SET position = array_position($1::bigint[], post_id)
+WHERE series_id = $2
+`
+
+type ReorderSeriesPostsParams struct {
+	PostIds  []int64
+	SeriesID int64
+}
+
+// Numbers the posts of a series in the order of post_ids.
+func (q *Queries) ReorderSeriesPosts(ctx context.Context, arg ReorderSeriesPostsParams) error {
+	_, err := q.db.Exec(ctx, reorderSeriesPosts, arg.PostIds, arg.SeriesID)
+	return err
+}
+
+const restorePost = `-- name: RestorePost :one
+UPDATE posts
+SET deleted_at = NULL,
+    updated_at = $1,
+    version = version + 1
+WHERE deleted_at IS NOT NULL
+AND id = $2
+RETURNING id, likes, views, title, body, user_id, version, status, published_at, slug, body_html, toc, excerpt, word_count, reading_minutes, created_at, updated_at, deleted_at
+`
+
+type RestorePostParams struct {
+	UpdatedAt pgtype.Timestamptz
+	ID        int64
+}
+
+func (q *Queries) RestorePost(ctx context.Context, arg RestorePostParams) (Post, error) {
+	row := q.db.QueryRow(ctx, restorePost, arg.UpdatedAt, arg.ID)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.Likes,
+		&i.Views,
+		&i.Title,
+		&i.Body,
+		&i.UserID,
+		&i.Version,
+		&i.Status,
+		&i.PublishedAt,
+		&i.Slug,
+		&i.BodyHtml,
+		&i.Toc,
+		&i.Excerpt,
+		&i.WordCount,
+		&i.ReadingMinutes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const revokeSession = `-- name: RevokeSession :execrows
+UPDATE sessions
+SET revoked_at = $1
+WHERE id = $2
+AND user_id = $3
+AND revoked_at IS NULL
+`
+
+type RevokeSessionParams struct {
+	RevokedAt pgtype.Timestamptz
+	ID        int64
+	UserID    int64
+}
+
+func (q *Queries) RevokeSession(ctx context.Context, arg RevokeSessionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeSession, arg.RevokedAt, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const revokeToken = `-- name: RevokeToken :exec
+INSERT INTO revoked_tokens (
+    token_id,
+    expires_at
+) VALUES (
+    $1, $2
+)
+ON CONFLICT (token_id) DO NOTHING
+`
+
+type RevokeTokenParams struct {
+	TokenID   string
+	ExpiresAt pgtype.Timestamptz
+}
+
+func (q *Queries) RevokeToken(ctx context.Context, arg RevokeTokenParams) error {
+	_, err := q.db.Exec(ctx, revokeToken, arg.TokenID, arg.ExpiresAt)
+	return err
+}
+
+const rotateSessionToken = `-- name: RotateSessionToken :exec
+UPDATE session_tokens
+SET rotated_at = $1
+WHERE token_hash = $2
+`
+
+type RotateSessionTokenParams struct {
+	RotatedAt pgtype.Timestamptz
+	TokenHash string
+}
+
+func (q *Queries) RotateSessionToken(ctx context.Context, arg RotateSessionTokenParams) error {
+	_, err := q.db.Exec(ctx, rotateSessionToken, arg.RotatedAt, arg.TokenHash)
+	return err
+}
+
+const searchPosts = `-- name: SearchPosts :many
+SELECT posts.id, posts.likes, posts.views, posts.title, posts.body, posts.user_id, posts.version, posts.status, posts.published_at, posts.slug, posts.body_html, posts.toc, posts.excerpt, posts.word_count, posts.reading_minutes, posts.created_at, posts.updated_at, posts.deleted_at,
+    (
+        ts_rank(
+            setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', body), 'B'),
+            plainto_tsquery('simple', $1::text)
+        )
+        + similarity(title, $1::text)
+        + word_similarity($1::text, title || ' ' || body)
+    )::double precision AS rank
+FROM posts
+WHERE deleted_at IS NULL
+AND status = 'published'
+AND (
+    setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', body), 'B')
+        @@ plainto_tsquery('simple', $1::text)
+    OR (title || ' ' || body) ILIKE ALL ($2::text[])
+)
+ORDER BY rank DESC, id DESC
+LIMIT $3
+OFFSET $4
+`
+
+type SearchPostsParams struct {
+	Query      string
+	Patterns   []string
+	PageSize   int32
+	PageOffset int32
+}
+
+type SearchPostsRow struct {
+	Post Post
+	Rank float64
+}
+
+func (q *Queries) SearchPosts(ctx context.Context, arg SearchPostsParams) ([]SearchPostsRow, error) {
+	rows, err := q.db.Query(ctx, searchPosts,
+		arg.Query,
+		arg.Patterns,
+		arg.PageSize,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchPostsRow
+	for rows.Next() {
+		var i SearchPostsRow
+		if err := rows.Scan(
+			&i.Post.ID,
+			&i.Post.Likes,
+			&i.Post.Views,
+			&i.Post.Title,
+			&i.Post.Body,
+			&i.Post.UserID,
+			&i.Post.Version,
+			&i.Post.Status,
+			&i.Post.PublishedAt,
+			&i.Post.Slug,
+			&i.Post.BodyHtml,
+			&i.Post.Toc,
+			&i.Post.Excerpt,
+			&i.Post.WordCount,
+			&i.Post.ReadingMinutes,
+			&i.Post.CreatedAt,
+			&i.Post.UpdatedAt,
+			&i.Post.DeletedAt,
+			&i.Rank,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setCommentHidden = `-- name: SetCommentHidden :one
+UPDATE comments
+SET hidden_at = $1,
+    hidden_by = $2,
+    updated_at = $3
+WHERE id = $4
+RETURNING id, post_id, parent_id, root_id, depth, user_id, body, hidden_at, hidden_by, edited_at, created_at, updated_at, deleted_at
+`
+
+type SetCommentHiddenParams struct {
+	HiddenAt  pgtype.Timestamptz
+	HiddenBy  pgtype.Int8
+	UpdatedAt pgtype.Timestamptz
+	ID        int64
+}
+
+func (q *Queries) SetCommentHidden(ctx context.Context, arg SetCommentHiddenParams) (Comment, error) {
+	row := q.db.QueryRow(ctx, setCommentHidden,
+		arg.HiddenAt,
+		arg.HiddenBy,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	var i Comment
+	err := row.Scan(
+		&i.ID,
+		&i.PostID,
+		&i.ParentID,
+		&i.RootID,
+		&i.Depth,
+		&i.UserID,
+		&i.Body,
+		&i.HiddenAt,
+		&i.HiddenBy,
+		&i.EditedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const setPostStatus = `-- name: SetPostStatus :one
+UPDATE posts
+SET status = $1,
+    published_at = $2,
+    updated_at = $3,
+    version = version + 1
+WHERE deleted_at IS NULL
+AND id = $4
+RETURNING id, likes, views, title, body, user_id, version, status, published_at, slug, body_html, toc, excerpt, word_count, reading_minutes, created_at, updated_at, deleted_at
+`
+
+type SetPostStatusParams struct {
+	Status      string
+	PublishedAt pgtype.Timestamptz
+	UpdatedAt   pgtype.Timestamptz
+	ID          int64
+}
+
+func (q *Queries) SetPostStatus(ctx context.Context, arg SetPostStatusParams) (Post, error) {
+	row := q.db.QueryRow(ctx, setPostStatus,
+		arg.Status,
+		arg.PublishedAt,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.Likes,
+		&i.Views,
+		&i.Title,
+		&i.Body,
+		&i.UserID,
+		&i.Version,
+		&i.Status,
+		&i.PublishedAt,
+		&i.Slug,
+		&i.BodyHtml,
+		&i.Toc,
+		&i.Excerpt,
+		&i.WordCount,
+		&i.ReadingMinutes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const setSimilarityThreshold = `-- name: SetSimilarityThreshold :exec
+SELECT set_config('pg_trgm.similarity_threshold', $1::text, true)
+`
+
+// Sets the similarity the % operator requires for the rest of the
+// transaction.
+func (q *Queries) SetSimilarityThreshold(ctx context.Context, threshold string) error {
+	_, err := q.db.Exec(ctx, setSimilarityThreshold, threshold)
+	return err
+}
+
+const softDeleteComment = `-- name: SoftDeleteComment :one
+UPDATE comments
+SET deleted_at = $1,
+    updated_at = $1
+WHERE id = $2
+RETURNING id, post_id, parent_id, root_id, depth, user_id, body, hidden_at, hidden_by, edited_at, created_at, updated_at, deleted_at
+`
+
+type SoftDeleteCommentParams struct {
+	DeletedAt pgtype.Timestamptz
+	ID        int64
+}
+
+func (q *Queries) SoftDeleteComment(ctx context.Context, arg SoftDeleteCommentParams) (Comment, error) {
+	row := q.db.QueryRow(ctx, softDeleteComment, arg.DeletedAt, arg.ID)
+	var i Comment
+	err := row.Scan(
+		&i.ID,
+		&i.PostID,
+		&i.ParentID,
+		&i.RootID,
+		&i.Depth,
+		&i.UserID,
+		&i.Body,
+		&i.HiddenAt,
+		&i.HiddenBy,
+		&i.EditedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const softDeletePost = `-- name: SoftDeletePost :exec
+UPDATE posts
+SET deleted_at = $1
+WHERE deleted_at IS NULL
+AND id = $2
+`
+
+type SoftDeletePostParams struct {
+	DeletedAt pgtype.Timestamptz
+	ID        int64
+}
+
+func (q *Queries) SoftDeletePost(ctx context.Context, arg SoftDeletePostParams) error {
+	_, err := q.db.Exec(ctx, softDeletePost, arg.DeletedAt, arg.ID)
+	return err
+}
+
+const touchPost = `-- name: TouchPost :one
+UPDATE posts
+SET updated_at = $1,
+    version = version + 1
+WHERE deleted_at IS NULL
+AND id = $2
+RETURNING id, likes, views, title, body, user_id, version, status, published_at, slug, body_html, toc, excerpt, word_count, reading_minutes, created_at, updated_at, deleted_at
+`
+
+type TouchPostParams struct {
+	UpdatedAt pgtype.Timestamptz
+	ID        int64
+}
+
+// Marks a post as changed by something other than its content, such as its
+// tags, so its version and modification time move on.
+func (q *Queries) TouchPost(ctx context.Context, arg TouchPostParams) (Post, error) {
+	row := q.db.QueryRow(ctx, touchPost, arg.UpdatedAt, arg.ID)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.Likes,
+		&i.Views,
+		&i.Title,
+		&i.Body,
+		&i.UserID,
+		&i.Version,
+		&i.Status,
+		&i.PublishedAt,
+		&i.Slug,
+		&i.BodyHtml,
+		&i.Toc,
+		&i.Excerpt,
+		&i.WordCount,
+		&i.ReadingMinutes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const touchSeries = `-- name: TouchSeries :exec
+UPDATE series
+SET updated_at = $1
+WHERE id = $2
+`
+
+type TouchSeriesParams struct {
+	UpdatedAt pgtype.Timestamptz
+	ID        int64
+}
+
+func (q *Queries) TouchSeries(ctx context.Context, arg TouchSeriesParams) error {
+	_, err := q.db.Exec(ctx, touchSeries, arg.UpdatedAt, arg.ID)
+	return err
+}
+
+const touchSession = `-- name: TouchSession :exec
+UPDATE sessions
+SET last_used_at = $1,
+    expires_at = $2,
+    user_agent = $3,
+    ip_address = $4
+WHERE id = $5
+`
+
+type TouchSessionParams struct {
+	LastUsedAt pgtype.Timestamptz
+	ExpiresAt  pgtype.Timestamptz
+	UserAgent  string
+	IpAddress  string
+	ID         int64
+}
+
+// Records a use of a session and extends it.
+func (q *Queries) TouchSession(ctx context.Context, arg TouchSessionParams) error {
+	_, err := q.db.Exec(ctx, touchSession,
+		arg.LastUsedAt,
+		arg.ExpiresAt,
+		arg.UserAgent,
+		arg.IpAddress,
+		arg.ID,
+	)
+	return err
+}
+
+const unlikePost = `-- name: UnlikePost :execrows
+DELETE FROM post_likes
+WHERE user_id = $1
+AND post_id = $2
+`
+
+type UnlikePostParams struct {
+	UserID int64
+	PostID int64
+}
+
+func (q *Queries) UnlikePost(ctx context.Context, arg UnlikePostParams) (int64, error) {
+	result, err := q.db.Exec(ctx, unlikePost, arg.UserID, arg.PostID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateCommentBody = `-- name: UpdateCommentBody :one
+UPDATE comments
+SET body = $1,
+    edited_at = $2,
+    updated_at = $2
+WHERE id = $3
+RETURNING id, post_id, parent_id, root_id, depth, user_id, body, hidden_at, hidden_by, edited_at, created_at, updated_at, deleted_at
+`
+
+type UpdateCommentBodyParams struct {
+	Body     string
+	EditedAt pgtype.Timestamptz
+	ID       int64
+}
+
+func (q *Queries) UpdateCommentBody(ctx context.Context, arg UpdateCommentBodyParams) (Comment, error) {
+	row := q.db.QueryRow(ctx, updateCommentBody, arg.Body, arg.EditedAt, arg.ID)
+	var i Comment
+	err := row.Scan(
+		&i.ID,
+		&i.PostID,
+		&i.ParentID,
+		&i.RootID,
+		&i.Depth,
+		&i.UserID,
+		&i.Body,
+		&i.HiddenAt,
+		&i.HiddenBy,
+		&i.EditedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const updatePost = `-- name: UpdatePost :one
+UPDATE posts
+SET title = $1,
+    body = $2,
+    slug = $3,
+    body_html = $4,
+    toc = $5,
+    excerpt = $6,
+    word_count = $7,
+    reading_minutes = $8,
+    updated_at = $9,
+    version = version + 1
+WHERE deleted_at IS NULL
+AND id = $10
+AND version = $11
+RETURNING id, likes, views, title, body, user_id, version, status, published_at, slug, body_html, toc, excerpt, word_count, reading_minutes, created_at, updated_at, deleted_at
+`
+
+type UpdatePostParams struct {
+	Title          string
+	Body           string
+	Slug           string
+	BodyHtml       string
+	Toc            []byte
+	Excerpt        string
+	WordCount      int32
+	ReadingMinutes int32
+	UpdatedAt      pgtype.Timestamptz
+	ID             int64
+	Version        int64
+}
+
+func (q *Queries) UpdatePost(ctx context.Context, arg UpdatePostParams) (Post, error) {
+	row := q.db.QueryRow(ctx, updatePost,
+		arg.Title,
+		arg.Body,
+		arg.Slug,
+		arg.BodyHtml,
+		arg.Toc,
+		arg.Excerpt,
+		arg.WordCount,
+		arg.ReadingMinutes,
+		arg.UpdatedAt,
+		arg.ID,
+		arg.Version,
+	)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.Likes,
+		&i.Views,
+		&i.Title,
+		&i.Body,
+		&i.UserID,
+		&i.Version,
+		&i.Status,
+		&i.PublishedAt,
+		&i.Slug,
+		&i.BodyHtml,
+		&i.Toc,
+		&i.Excerpt,
+		&i.WordCount,
+		&i.ReadingMinutes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
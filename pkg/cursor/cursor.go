@@ -2,33 +2,95 @@ package cursor
 
 import (
 	"encoding/base32"
+	"errors"
 	"strconv"
+	"strings"
+	"time"
 
 	typesv1 "github.com/gaesemo/blog-api/go/types/v1"
 )
 
+// Keyset marks a position in a list sorted by (Time, ID) in descending order.
+type Keyset struct {
+	Time time.Time
+	ID   int64
+	// Backward pages towards newer items, i.e. back to the previous page.
+	Backward bool
+}
+
 func FromInt64(i64 int64) *typesv1.Cursor {
 	raw := strconv.AppendInt(nil, i64, 36)
-	cursor := make([]byte, base32.HexEncoding.EncodedLen(len(raw)))
-	base32.HexEncoding.Encode(cursor, raw)
-	return &typesv1.Cursor{Opaque: cursor}
+	return &typesv1.Cursor{Opaque: encode(raw)}
 }
 
 func MustParseInt64(cursor *typesv1.Cursor) int64 {
-	if cursor == nil {
+	raw, ok := decode(cursor)
+	if !ok {
 		return 0
 	}
-	if len(cursor.Opaque) == 0 {
+	i64, err := strconv.ParseInt(string(raw), 36, 64)
+	if err != nil {
 		return 0
 	}
+	return i64
+}
 
-	raw, err := base32.HexEncoding.DecodeString(string(cursor.Opaque))
+// FromKeyset encodes a keyset position. Time is kept to the microsecond, the
+// precision Postgres stores timestamps with.
+func FromKeyset(k Keyset) *typesv1.Cursor {
+	direction := "f"
+	if k.Backward {
+		direction = "b"
+	}
+	raw := strconv.FormatInt(k.Time.UnixMicro(), 36) + "." + strconv.FormatInt(k.ID, 36) + "." + direction
+	return &typesv1.Cursor{Opaque: encode([]byte(raw))}
+}
+
+// ParseKeyset decodes a cursor made by FromKeyset. An empty cursor yields the
+// zero Keyset, which stands for the first page.
+func ParseKeyset(cursor *typesv1.Cursor) (Keyset, error) {
+	if cursor == nil || len(cursor.Opaque) == 0 {
+		return Keyset{}, nil
+	}
+	raw, ok := decode(cursor)
+	if !ok {
+		return Keyset{}, errors.New("malformed cursor")
+	}
+	parts := strings.Split(string(raw), ".")
+	if len(parts) != 3 || (parts[2] != "f" && parts[2] != "b") {
+		return Keyset{}, errors.New("malformed cursor")
+	}
+	micros, err := strconv.ParseInt(parts[0], 36, 64)
 	if err != nil {
-		return 0
+		return Keyset{}, errors.New("malformed cursor")
 	}
-	i64, err := strconv.ParseInt(string(raw), 36, 64)
+	id, err := strconv.ParseInt(parts[1], 36, 64)
 	if err != nil {
-		return 0
+		return Keyset{}, errors.New("malformed cursor")
 	}
-	return i64
+	return Keyset{
+		Time:     time.UnixMicro(micros).UTC(),
+		ID:       id,
+		Backward: parts[2] == "b",
+	}, nil
+}
+
+func encode(raw []byte) []byte {
+	cursor := make([]byte, base32.HexEncoding.EncodedLen(len(raw)))
+	base32.HexEncoding.Encode(cursor, raw)
+	return cursor
+}
+
+func decode(cursor *typesv1.Cursor) ([]byte, bool) {
+	if cursor == nil {
+		return nil, false
+	}
+	if len(cursor.Opaque) == 0 {
+		return nil, false
+	}
+	raw, err := base32.HexEncoding.DecodeString(string(cursor.Opaque))
+	if err != nil {
+		return nil, false
+	}
+	return raw, true
 }
@@ -0,0 +1,46 @@
+package cursor
+
+import (
+	"testing"
+	"time"
+
+	typesv1 "github.com/gaesemo/blog-api/go/types/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInt64RoundTrip(t *testing.T) {
+	require.Equal(t, int64(12345), MustParseInt64(FromInt64(12345)))
+	require.Equal(t, int64(0), MustParseInt64(nil))
+	require.Equal(t, int64(0), MustParseInt64(&typesv1.Cursor{Opaque: []byte("not base32!")}))
+}
+
+func TestKeysetRoundTrip(t *testing.T) {
+	k := Keyset{
+		Time:     time.Date(2025, 6, 28, 19, 25, 43, 123456789, time.UTC),
+		ID:       42,
+		Backward: true,
+	}
+	got, err := ParseKeyset(FromKeyset(k))
+	require.NoError(t, err)
+	require.Equal(t, k.Time.Truncate(time.Microsecond), got.Time)
+	require.Equal(t, k.ID, got.ID)
+	require.True(t, got.Backward)
+}
+
+func TestParseKeysetEmpty(t *testing.T) {
+	got, err := ParseKeyset(nil)
+	require.NoError(t, err)
+	require.True(t, got.Time.IsZero())
+
+	got, err = ParseKeyset(&typesv1.Cursor{})
+	require.NoError(t, err)
+	require.True(t, got.Time.IsZero())
+}
+
+func TestParseKeysetMalformed(t *testing.T) {
+	_, err := ParseKeyset(FromInt64(7))
+	require.Error(t, err)
+
+	_, err = ParseKeyset(&typesv1.Cursor{Opaque: []byte("%%%")})
+	require.Error(t, err)
+}
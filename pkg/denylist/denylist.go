@@ -0,0 +1,57 @@
+package denylist
+
+import (
+	"sync"
+	"time"
+)
+
+// List holds the IDs of revoked tokens in memory, each until the token would
+// have expired anyway, after which it is harmless and can be forgotten.
+type List struct {
+	mu      sync.RWMutex
+	entries map[string]time.Time // when a revoked token expires
+}
+
+func New() *List {
+	return &List{entries: map[string]time.Time{}}
+}
+
+// Add revokes the token id until it expires at until. Adding an id again
+// keeps the later expiry.
+func (l *List) Add(id string, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if prev, ok := l.entries[id]; !ok || until.After(prev) {
+		l.entries[id] = until
+	}
+}
+
+// Contains reports whether the token id is revoked at now.
+func (l *List) Contains(id string, now time.Time) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	until, ok := l.entries[id]
+	return ok && now.Before(until)
+}
+
+// Prune forgets tokens that have expired by now.
+func (l *List) Prune(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for id, until := range l.entries {
+		if !now.Before(until) {
+			delete(l.entries, id)
+		}
+	}
+}
+
+// Len returns the number of tokens held.
+func (l *List) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return len(l.entries)
+}
@@ -0,0 +1,40 @@
+package denylist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainsUntilExpiry(t *testing.T) {
+	l := New()
+	t0 := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	require.False(t, l.Contains("a", t0))
+	l.Add("a", t0.Add(time.Hour))
+	require.True(t, l.Contains("a", t0))
+	require.True(t, l.Contains("a", t0.Add(59*time.Minute)))
+	require.False(t, l.Contains("a", t0.Add(time.Hour)))
+	require.False(t, l.Contains("b", t0), "other tokens are not revoked")
+}
+
+func TestAddKeepsLaterExpiry(t *testing.T) {
+	l := New()
+	t0 := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	l.Add("a", t0.Add(time.Hour))
+	l.Add("a", t0.Add(time.Minute))
+	require.True(t, l.Contains("a", t0.Add(30*time.Minute)))
+}
+
+func TestPruneForgetsExpired(t *testing.T) {
+	l := New()
+	t0 := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	l.Add("a", t0.Add(time.Minute))
+	l.Add("b", t0.Add(time.Hour))
+	l.Prune(t0.Add(time.Minute))
+	require.Equal(t, 1, l.Len())
+	require.True(t, l.Contains("b", t0.Add(time.Minute)))
+}
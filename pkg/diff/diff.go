@@ -0,0 +1,153 @@
+package diff
+
+import (
+	"slices"
+	"strings"
+)
+
+type Op string
+
+const (
+	Equal  Op = "="
+	Insert Op = "+"
+	Delete Op = "-"
+)
+
+type Line struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxEdits bounds the edit distance Lines searches for. The search keeps
+// O(D²) state for an edit distance of D, so rewrites beyond it fall back to
+// replacing the changed lines as a whole.
+const maxEdits = 1000
+
+// Lines returns the shortest line-based edit script that turns a into b,
+// computed with Myers' O(ND) algorithm. When more than maxEdits lines
+// changed, the script is still correct but no longer the shortest: the lines
+// between the common prefix and suffix are deleted and inserted as a block.
+func Lines(a, b string) []Line {
+	x, y := split(a), split(b)
+
+	pre := 0
+	for pre < len(x) && pre < len(y) && x[pre] == y[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(x)-pre && suf < len(y)-pre && x[len(x)-1-suf] == y[len(y)-1-suf] {
+		suf++
+	}
+
+	lines := []Line{}
+	for _, l := range x[:pre] {
+		lines = append(lines, Line{Op: Equal, Text: l})
+	}
+	xm, ym := x[pre:len(x)-suf], y[pre:len(y)-suf]
+	if mid, ok := myers(xm, ym, maxEdits); ok {
+		lines = append(lines, mid...)
+	} else {
+		for _, l := range xm {
+			lines = append(lines, Line{Op: Delete, Text: l})
+		}
+		for _, l := range ym {
+			lines = append(lines, Line{Op: Insert, Text: l})
+		}
+	}
+	for _, l := range x[len(x)-suf:] {
+		lines = append(lines, Line{Op: Equal, Text: l})
+	}
+	return lines
+}
+
+// myers returns the shortest edit script from x to y, or false if it takes
+// more than limit edits.
+func myers(x, y []string, limit int) ([]Line, bool) {
+	n, m := len(x), len(y)
+	max := min(n+m, limit)
+	off := max + 1
+
+	v := make([]int, 2*max+3)
+	// trace[d] holds the diagonals -(d+1)..d+1 of v as they were before
+	// round d, the only ones backtracking through that round reads.
+	trace := [][]int{}
+	for d := 0; d <= max; d++ {
+		trace = append(trace, slices.Clone(v[off-d-1:off+d+2]))
+		for k := -d; k <= d; k += 2 {
+			var i int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				i = v[off+k+1]
+			} else {
+				i = v[off+k-1] + 1
+			}
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i++
+				j++
+			}
+			v[off+k] = i
+			if i >= n && j >= m {
+				return backtrack(trace, x, y), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// String renders the edit script in the style of a unified diff body.
+func String(lines []Line) string {
+	var sb strings.Builder
+	for _, l := range lines {
+		switch l.Op {
+		case Insert:
+			sb.WriteString("+")
+		case Delete:
+			sb.WriteString("-")
+		default:
+			sb.WriteString(" ")
+		}
+		sb.WriteString(l.Text)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func backtrack(trace [][]int, x, y []string) []Line {
+	lines := []Line{}
+	i, j := len(x), len(y)
+	for d := len(trace) - 1; d >= 0; d-- {
+		v, off := trace[d], d+1
+		k := i - j
+		var prevK int
+		if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevI := v[off+prevK]
+		prevJ := prevI - prevK
+		for i > prevI && j > prevJ {
+			lines = append(lines, Line{Op: Equal, Text: x[i-1]})
+			i--
+			j--
+		}
+		if d > 0 {
+			if i == prevI {
+				lines = append(lines, Line{Op: Insert, Text: y[j-1]})
+			} else {
+				lines = append(lines, Line{Op: Delete, Text: x[i-1]})
+			}
+		}
+		i, j = prevI, prevJ
+	}
+	slices.Reverse(lines)
+	return lines
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
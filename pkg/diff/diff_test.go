@@ -0,0 +1,104 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{
+			name: "identical",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: " a\n b\n",
+		},
+		{
+			name: "both empty",
+			a:    "",
+			b:    "",
+			want: "",
+		},
+		{
+			name: "from empty",
+			a:    "",
+			b:    "a\nb",
+			want: "+a\n+b\n",
+		},
+		{
+			name: "to empty",
+			a:    "a\nb",
+			b:    "",
+			want: "-a\n-b\n",
+		},
+		{
+			name: "insert in the middle",
+			a:    "a\nc",
+			b:    "a\nb\nc",
+			want: " a\n+b\n c\n",
+		},
+		{
+			name: "replace a line",
+			a:    "제목\n본문\n끝",
+			b:    "제목\n고친 본문\n끝",
+			want: " 제목\n-본문\n+고친 본문\n 끝\n",
+		},
+		{
+			name: "crlf line endings",
+			a:    "a\r\nb\r\n",
+			b:    "a\nb\n",
+			want: " a\n b\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, String(Lines(tt.a, tt.b)))
+		})
+	}
+}
+
+func TestLinesIsMinimal(t *testing.T) {
+	lines := Lines("a\nb\nc\na\nb\nb\na", "c\nb\na\nb\na\nc")
+	edits := 0
+	for _, l := range lines {
+		if l.Op != Equal {
+			edits++
+		}
+	}
+	require.Equal(t, 5, edits)
+}
+
+func TestLinesBeyondMaxEdits(t *testing.T) {
+	var a, b strings.Builder
+	a.WriteString("head\n")
+	b.WriteString("head\n")
+	for i := range 2 * maxEdits {
+		fmt.Fprintf(&a, "old %d\n", i)
+		fmt.Fprintf(&b, "new %d\n", i)
+	}
+	a.WriteString("tail\n")
+	b.WriteString("tail\n")
+
+	lines := Lines(a.String(), b.String())
+	var from, to strings.Builder
+	for _, l := range lines {
+		if l.Op != Insert {
+			from.WriteString(l.Text + "\n")
+		}
+		if l.Op != Delete {
+			to.WriteString(l.Text + "\n")
+		}
+	}
+	require.Equal(t, a.String(), from.String())
+	require.Equal(t, b.String(), to.String())
+	require.Equal(t, Line{Op: Equal, Text: "head"}, lines[0])
+	require.Equal(t, Line{Op: Equal, Text: "tail"}, lines[len(lines)-1])
+}
@@ -0,0 +1,286 @@
+// Package feed encodes syndication feeds as RSS 2.0, Atom 1.0 and JSON Feed
+// 1.1 from a single format-neutral description.
+package feed
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"time"
+)
+
+const (
+	RSSContentType  = "application/rss+xml; charset=utf-8"
+	AtomContentType = "application/atom+xml; charset=utf-8"
+	JSONContentType = "application/feed+json; charset=utf-8"
+)
+
+// Feed describes a feed independently of its format.
+type Feed struct {
+	Title       string
+	Description string
+	// Link is the page the feed belongs to, e.g. the home page of the blog.
+	Link string
+	// FeedURL is where the feed itself is served.
+	FeedURL string
+	Updated time.Time
+	Items   []Item
+}
+
+// Item is one entry of a feed. An item carries the full content as HTML, a
+// plain text summary, or both.
+type Item struct {
+	// ID identifies the item for good, even when its URL changes.
+	ID          string
+	URL         string
+	Title       string
+	Author      string
+	ContentHTML string
+	Summary     string
+	Tags        []string
+	Published   time.Time
+	Updated     time.Time
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	PubDate     string   `xml:"pubDate,omitempty"`
+	Description string   `xml:"description"`
+}
+
+type rssGUID struct {
+	ID          string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+// RSS encodes f as RSS 2.0. Items carry their content as the description,
+// falling back to the summary.
+func RSS(f *Feed) ([]byte, error) {
+	doc := rss{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   f.Description,
+			Self:          atomLink{Href: f.FeedURL, Rel: "self", Type: "application/rss+xml"},
+			LastBuildDate: rssTime(f.Updated),
+		},
+	}
+	for _, it := range f.Items {
+		description := it.ContentHTML
+		if description == "" {
+			description = it.Summary
+		}
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       it.Title,
+			Link:        it.URL,
+			GUID:        rssGUID{ID: it.ID, IsPermaLink: it.ID == it.URL},
+			Creator:     it.Author,
+			Categories:  it.Tags,
+			PubDate:     rssTime(it.Published),
+			Description: description,
+		})
+	}
+	return encodeXML(doc)
+}
+
+func rssTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC1123Z)
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Link       atomLink       `xml:"link"`
+	Author     *atomPerson    `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Published  string         `xml:"published,omitempty"`
+	Updated    string         `xml:"updated"`
+	Summary    *atomText      `xml:"summary"`
+	Content    *atomText      `xml:"content"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// Atom encodes f as Atom 1.0. The feed URL doubles as the feed's ID.
+func Atom(f *Feed) ([]byte, error) {
+	doc := atomFeed{
+		ID:       f.FeedURL,
+		Title:    f.Title,
+		Subtitle: f.Description,
+		Updated:  atomTime(f.Updated),
+		Links: []atomLink{
+			{Href: f.Link, Rel: "alternate", Type: "text/html"},
+			{Href: f.FeedURL, Rel: "self", Type: "application/atom+xml"},
+		},
+	}
+	for _, it := range f.Items {
+		entry := atomEntry{
+			ID:        it.ID,
+			Title:     it.Title,
+			Link:      atomLink{Href: it.URL, Rel: "alternate", Type: "text/html"},
+			Published: atomTime(it.Published),
+			Updated:   atomTime(it.Updated),
+		}
+		if it.Author != "" {
+			entry.Author = &atomPerson{Name: it.Author}
+		}
+		for _, t := range it.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: t})
+		}
+		if it.Summary != "" {
+			entry.Summary = &atomText{Type: "text", Body: it.Summary}
+		}
+		if it.ContentHTML != "" {
+			entry.Content = &atomText{Type: "html", Body: it.ContentHTML}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return encodeXML(doc)
+}
+
+func atomTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func encodeXML(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+type jsonFeed struct {
+	Version     string     `json:"version"`
+	Title       string     `json:"title"`
+	HomePageURL string     `json:"home_page_url,omitempty"`
+	FeedURL     string     `json:"feed_url,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Items       []jsonItem `json:"items"`
+}
+
+type jsonItem struct {
+	ID            string       `json:"id"`
+	URL           string       `json:"url,omitempty"`
+	Title         string       `json:"title,omitempty"`
+	ContentHTML   string       `json:"content_html,omitempty"`
+	ContentText   string       `json:"content_text,omitempty"`
+	Summary       string       `json:"summary,omitempty"`
+	DatePublished *time.Time   `json:"date_published,omitempty"`
+	DateModified  *time.Time   `json:"date_modified,omitempty"`
+	Authors       []jsonAuthor `json:"authors,omitempty"`
+	Tags          []string     `json:"tags,omitempty"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+// JSON encodes f as JSON Feed 1.1. Items without HTML content carry their
+// summary as the text content, since every item needs one or the other.
+func JSON(f *Feed) ([]byte, error) {
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.Link,
+		FeedURL:     f.FeedURL,
+		Description: f.Description,
+		Items:       []jsonItem{},
+	}
+	for _, it := range f.Items {
+		item := jsonItem{
+			ID:            it.ID,
+			URL:           it.URL,
+			Title:         it.Title,
+			ContentHTML:   it.ContentHTML,
+			Summary:       it.Summary,
+			DatePublished: jsonTime(it.Published),
+			DateModified:  jsonTime(it.Updated),
+			Tags:          it.Tags,
+		}
+		if item.ContentHTML == "" {
+			item.ContentText = it.Summary
+		}
+		if it.Author != "" {
+			item.Authors = []jsonAuthor{{Name: it.Author}}
+		}
+		doc.Items = append(doc.Items, item)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func jsonTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
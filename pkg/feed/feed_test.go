@@ -0,0 +1,120 @@
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testFeed() *Feed {
+	published := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	return &Feed{
+		Title:       "gaesemo",
+		Description: "Posts from gaesemo",
+		Link:        "https://blog.example.com",
+		FeedURL:     "https://blog.example.com/feed.xml",
+		Updated:     published.Add(time.Hour),
+		Items: []Item{
+			{
+				ID:          "https://blog.example.com/posts/1",
+				URL:         "https://blog.example.com/posts/hello",
+				Title:       "Hello & welcome",
+				Author:      "alice",
+				ContentHTML: "<p>Hello <em>world</em></p>",
+				Summary:     "Hello world",
+				Tags:        []string{"go"},
+				Published:   published,
+				Updated:     published.Add(time.Hour),
+			},
+		},
+	}
+}
+
+func TestRSS(t *testing.T) {
+	out, err := RSS(testFeed())
+	require.NoError(t, err)
+
+	var doc struct {
+		Channel struct {
+			Title string `xml:"title"`
+			Items []struct {
+				Title       string `xml:"title"`
+				Link        string `xml:"link"`
+				GUID        string `xml:"guid"`
+				Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+				Category    string `xml:"category"`
+				PubDate     string `xml:"pubDate"`
+				Description string `xml:"description"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	require.NoError(t, xml.Unmarshal(out, &doc))
+	require.Equal(t, "gaesemo", doc.Channel.Title)
+	require.Len(t, doc.Channel.Items, 1)
+	item := doc.Channel.Items[0]
+	require.Equal(t, "Hello & welcome", item.Title)
+	require.Equal(t, "https://blog.example.com/posts/hello", item.Link)
+	require.Equal(t, "https://blog.example.com/posts/1", item.GUID)
+	require.Equal(t, "alice", item.Creator)
+	require.Equal(t, "go", item.Category)
+	require.Equal(t, "Tue, 01 Jul 2025 12:00:00 +0000", item.PubDate)
+	require.Equal(t, "<p>Hello <em>world</em></p>", item.Description)
+	require.Contains(t, string(out), `isPermaLink="false"`)
+}
+
+func TestAtom(t *testing.T) {
+	out, err := Atom(testFeed())
+	require.NoError(t, err)
+
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		ID      string   `xml:"id"`
+		Updated string   `xml:"updated"`
+		Entries []struct {
+			ID      string `xml:"id"`
+			Author  string `xml:"author>name"`
+			Updated string `xml:"updated"`
+			Summary string `xml:"summary"`
+			Content struct {
+				Type string `xml:"type,attr"`
+				Body string `xml:",chardata"`
+			} `xml:"content"`
+		} `xml:"entry"`
+	}
+	require.NoError(t, xml.Unmarshal(out, &doc))
+	require.Equal(t, "https://blog.example.com/feed.xml", doc.ID)
+	require.Equal(t, "2025-07-01T13:00:00Z", doc.Updated)
+	require.Len(t, doc.Entries, 1)
+	entry := doc.Entries[0]
+	require.Equal(t, "alice", entry.Author)
+	require.Equal(t, "Hello world", entry.Summary)
+	require.Equal(t, "html", entry.Content.Type)
+	require.Equal(t, "<p>Hello <em>world</em></p>", entry.Content.Body)
+}
+
+func TestJSON(t *testing.T) {
+	f := testFeed()
+	f.Items[0].ContentHTML = ""
+	out, err := JSON(f)
+	require.NoError(t, err)
+
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(out, &doc))
+	require.Equal(t, "https://jsonfeed.org/version/1.1", doc["version"])
+	items := doc["items"].([]any)
+	require.Len(t, items, 1)
+	item := items[0].(map[string]any)
+	require.Equal(t, "Hello world", item["content_text"], "summary stands in for missing content")
+	require.NotContains(t, item, "content_html")
+	require.Equal(t, "2025-07-01T12:00:00Z", item["date_published"])
+	require.Equal(t, []any{map[string]any{"name": "alice"}}, item["authors"])
+}
+
+func TestJSONWithoutItems(t *testing.T) {
+	out, err := JSON(&Feed{Title: "empty"})
+	require.NoError(t, err)
+	require.Contains(t, string(out), `"items": []`)
+}
@@ -0,0 +1,109 @@
+// Package httpapi holds what the plain HTTP endpoints served next to the RPCs
+// have in common: JSON bodies, errors in the Connect format, the signed-in
+// user and request parameters.
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"connectrpc.com/authn"
+	"connectrpc.com/connect"
+	typesv1 "github.com/gaesemo/blog-api/go/types/v1"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Responder writes responses, logging failures to write them.
+type Responder struct {
+	logger    *slog.Logger
+	errWriter *connect.ErrorWriter
+}
+
+func NewResponder(logger *slog.Logger) *Responder {
+	return &Responder{
+		logger:    logger,
+		errWriter: connect.NewErrorWriter(),
+	}
+}
+
+// JSON writes v as the JSON body of the response.
+func (rs *Responder) JSON(w http.ResponseWriter, r *http.Request, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		rs.logger.ErrorContext(r.Context(), "writing response", slog.String("path", r.URL.Path), slog.Any("error", err))
+	}
+}
+
+// Error reports err in the Connect error format, so clients handle failures
+// from these endpoints the same way as failures from the RPCs.
+func (rs *Responder) Error(w http.ResponseWriter, r *http.Request, err error) {
+	if writeErr := rs.errWriter.Write(w, r, err); writeErr != nil {
+		rs.logger.ErrorContext(r.Context(), "writing error response", slog.String("path", r.URL.Path), slog.Any("error", writeErr))
+	}
+}
+
+// ReadJSON decodes the JSON body of r into v.
+func ReadJSON(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("decoding request body: %v", err))
+	}
+	return nil
+}
+
+// ViewerID returns the ID of the signed-in user, or 0 for anonymous readers.
+func ViewerID(ctx context.Context) int64 {
+	uid, _ := authn.GetInfo(ctx).(*int64)
+	if uid == nil {
+		return 0
+	}
+	return *uid
+}
+
+// RequireUserID returns the ID of the signed-in user, failing for anonymous
+// requests.
+func RequireUserID(r *http.Request) (int64, error) {
+	uid, _ := authn.GetInfo(r.Context()).(*int64)
+	if uid == nil {
+		return 0, connect.NewError(connect.CodeUnauthenticated, fmt.Errorf("user not found"))
+	}
+	return *uid, nil
+}
+
+// PathInt64 reads the integer path parameter name.
+func PathInt64(r *http.Request, name string) (int64, error) {
+	v, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+	if err != nil {
+		return 0, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid %s %q", name, r.PathValue(name)))
+	}
+	return v, nil
+}
+
+// QueryCursor reads the opaque pagination cursor from the query string.
+func QueryCursor(r *http.Request) *typesv1.Cursor {
+	return &typesv1.Cursor{Opaque: []byte(r.URL.Query().Get("cursor"))}
+}
+
+// TimePtr returns the time of t, or nil if it is NULL, for optional times in
+// JSON bodies.
+func TimePtr(t pgtype.Timestamptz) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// AsConnectError passes connect errors raised inside a transaction through
+// as is and reports anything else as an internal error.
+func AsConnectError(err error, doing string) error {
+	var connectErr *connect.Error
+	if errors.As(err, &connectErr) {
+		return connectErr
+	}
+	return connect.NewError(connect.CodeInternal, fmt.Errorf("%s: %v", doing, err))
+}
@@ -0,0 +1,60 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const defaultTitle = "gaesemo"
+
+// Site is the public address and title of the blog, which absolute links in
+// feeds, sitemaps and link previews are built on. It is configured rather
+// than taken from requests, whose Host header anyone can set, since those
+// responses are cached by shared caches.
+type Site struct {
+	// URL is where the blog is read, without a trailing slash. Posts are
+	// linked as {URL}/posts/{slug}.
+	URL string
+	// APIURL is where this server is reached, for links to what it serves
+	// itself, without a trailing slash.
+	APIURL string
+	Title  string
+}
+
+// NewSite checks the configured addresses of the blog. siteURL is required;
+// apiURL defaults to it, for when the API is served on the same origin, and
+// title to the name of the blog.
+func NewSite(siteURL, apiURL, title string) (Site, error) {
+	if err := checkURL(siteURL); err != nil {
+		return Site{}, fmt.Errorf("site URL: %v", err)
+	}
+	if apiURL == "" {
+		apiURL = siteURL
+	}
+	if err := checkURL(apiURL); err != nil {
+		return Site{}, fmt.Errorf("API URL: %v", err)
+	}
+	if title == "" {
+		title = defaultTitle
+	}
+	return Site{
+		URL:    strings.TrimSuffix(siteURL, "/"),
+		APIURL: strings.TrimSuffix(apiURL, "/"),
+		Title:  title,
+	}, nil
+}
+
+func checkURL(raw string) error {
+	if raw == "" {
+		return fmt.Errorf("required")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an absolute http(s) URL", raw)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package httpapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSite(t *testing.T) {
+	site, err := NewSite("https://blog.example.com/", "", "")
+	require.NoError(t, err)
+	require.Equal(t, Site{
+		URL:    "https://blog.example.com",
+		APIURL: "https://blog.example.com",
+		Title:  "gaesemo",
+	}, site)
+
+	site, err = NewSite("https://blog.example.com", "https://api.example.com/", "Blog")
+	require.NoError(t, err)
+	require.Equal(t, "https://api.example.com", site.APIURL)
+	require.Equal(t, "Blog", site.Title)
+}
+
+func TestNewSiteRejects(t *testing.T) {
+	for _, tc := range []struct{ site, api string }{
+		{"", ""},
+		{"blog.example.com", ""},
+		{"/posts", ""},
+		{"ftp://blog.example.com", ""},
+		{"https://blog.example.com", "api.example.com"},
+	} {
+		_, err := NewSite(tc.site, tc.api, "")
+		require.Error(t, err, "%q, %q", tc.site, tc.api)
+	}
+}
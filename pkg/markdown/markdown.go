@@ -0,0 +1,140 @@
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/gaesemo/blog-server/pkg/slug"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// Heading is an entry of the table of contents. ID is the anchor of the
+// heading in the rendered HTML.
+type Heading struct {
+	Level int    `json:"level"`
+	ID    string `json:"id"`
+	Text  string `json:"text"`
+}
+
+// Document is a rendered Markdown document.
+type Document struct {
+	HTML string
+	TOC  []Heading
+}
+
+var md = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM,
+		highlighting.NewHighlighting(
+			// Classes rather than inline styles, so the sanitizer can drop
+			// every style attribute and clients pick the colour scheme.
+			highlighting.WithFormatOptions(chromahtml.WithClasses(true)),
+		),
+	),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+)
+
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("id").Matching(regexp.MustCompile(`^[a-z0-9-]+$`)).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[a-zA-Z0-9 _-]+$`)).OnElements("pre", "code", "span")
+	// GFM task lists.
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	return p
+}
+
+// Render converts GitHub Flavored Markdown to HTML that is safe to embed in a
+// page. Code blocks are highlighted with chroma CSS classes and headings get
+// anchors made from their text, which are also listed in the table of
+// contents.
+func Render(source []byte) (*Document, error) {
+	ctx := parser.NewContext(parser.WithIDs(&headingIDs{seen: map[string]bool{}}))
+	root := md.Parser().Parse(text.NewReader(source), parser.WithContext(ctx))
+
+	toc := []Heading{}
+	err := ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		h, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		id, _ := h.AttributeString("id")
+		idBytes, _ := id.([]byte)
+		toc = append(toc, Heading{
+			Level: h.Level,
+			ID:    string(idBytes),
+			Text:  plainText(h, source),
+		})
+		return ast.WalkSkipChildren, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := md.Renderer().Render(&buf, source, root); err != nil {
+		return nil, err
+	}
+	return &Document{
+		HTML: policy.Sanitize(buf.String()),
+		TOC:  toc,
+	}, nil
+}
+
+// headingIDs makes heading anchors with the same rules as post slugs, so
+// Korean headings get readable anchors too.
+type headingIDs struct {
+	seen map[string]bool
+}
+
+func (h *headingIDs) Generate(value []byte, _ ast.NodeKind) []byte {
+	base := slug.Text(string(value))
+	if base == "" {
+		base = "section"
+	}
+	id := base
+	for n := 1; h.seen[id]; n++ {
+		id = base + "-" + strconv.Itoa(n)
+	}
+	h.seen[id] = true
+	return []byte(id)
+}
+
+func (h *headingIDs) Put(value []byte) {
+	h.seen[string(value)] = true
+}
+
+// plainText returns the text of n without any markup.
+func plainText(n ast.Node, source []byte) string {
+	var sb strings.Builder
+	var walk func(n ast.Node)
+	walk = func(n ast.Node) {
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			switch c := c.(type) {
+			case *ast.Text:
+				sb.Write(c.Segment.Value(source))
+				if c.SoftLineBreak() {
+					sb.WriteByte(' ')
+				}
+			case *ast.String:
+				sb.Write(c.Value)
+			case *ast.RawHTML:
+			default:
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	return strings.TrimSpace(sb.String())
+}
@@ -0,0 +1,44 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderTOC(t *testing.T) {
+	doc, err := Render([]byte("# Getting started\n\nintro\n\n## 설치 방법\n\n## Getting **started**\n\n### ???\n"))
+	require.NoError(t, err)
+	require.Equal(t, []Heading{
+		{Level: 1, ID: "getting-started", Text: "Getting started"},
+		{Level: 2, ID: "seolchi-bangbeop", Text: "설치 방법"},
+		{Level: 2, ID: "getting-started-1", Text: "Getting started"},
+		{Level: 3, ID: "section", Text: "???"},
+	}, doc.TOC)
+	require.Contains(t, doc.HTML, `<h2 id="seolchi-bangbeop">설치 방법</h2>`)
+}
+
+func TestRenderGFM(t *testing.T) {
+	doc, err := Render([]byte("| a | b |\n|---|---|\n| 1 | 2 |\n\n- [x] done\n\n~~gone~~\n"))
+	require.NoError(t, err)
+	require.Contains(t, doc.HTML, "<table>")
+	require.Contains(t, doc.HTML, "<td>1</td>")
+	require.Contains(t, doc.HTML, `<input checked="" disabled="" type="checkbox"`)
+	require.Contains(t, doc.HTML, "<del>gone</del>")
+}
+
+func TestRenderHighlightsCode(t *testing.T) {
+	doc, err := Render([]byte("```go\nfunc main() {}\n```\n"))
+	require.NoError(t, err)
+	require.Contains(t, doc.HTML, `<pre class="chroma">`)
+	require.Contains(t, doc.HTML, `<span class="kd">func</span>`)
+	require.NotContains(t, doc.HTML, "style=")
+}
+
+func TestRenderSanitizes(t *testing.T) {
+	doc, err := Render([]byte("<script>alert(1)</script>\n\n[click](javascript:alert(1))\n\n<img src=x onerror=alert(1)>\n"))
+	require.NoError(t, err)
+	require.NotContains(t, doc.HTML, "<script")
+	require.NotContains(t, doc.HTML, "javascript:")
+	require.NotContains(t, doc.HTML, "onerror")
+}
@@ -8,12 +8,26 @@ import (
 	"net/http"
 	"time"
 
+	"connectrpc.com/authn"
 	"connectrpc.com/connect"
 	"github.com/gaesemo/blog-server/pkg/token"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func Authorize(ctx context.Context, req *http.Request) (any, error) {
+// Denylist holds the IDs of tokens revoked before they expire.
+type Denylist interface {
+	Contains(id string, now time.Time) bool
+}
+
+// Authorize returns an authn.AuthFunc reading the user from the token cookie.
+// Tokens whose ID is in denylist are refused.
+func Authorize(denylist Denylist) authn.AuthFunc {
+	return func(ctx context.Context, req *http.Request) (any, error) {
+		return authorize(ctx, req, denylist)
+	}
+}
+
+func authorize(ctx context.Context, req *http.Request, denylist Denylist) (any, error) {
 	cookie, err := req.Cookie("token")
 	if err != nil {
 		slog.InfoContext(ctx, "author not found")
@@ -28,6 +42,9 @@ func Authorize(ctx context.Context, req *http.Request) (any, error) {
 	if err != nil && errors.Is(err, jwt.ErrTokenSignatureInvalid) {
 		return nil, connect.NewError(connect.CodeUnauthenticated, fmt.Errorf("invalid signature"))
 	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeUnauthenticated, fmt.Errorf("invalid token: %v", err))
+	}
 	if !tok.Valid {
 		return nil, connect.NewError(connect.CodeUnauthenticated, fmt.Errorf("invalid token"))
 	}
@@ -40,5 +57,8 @@ func Authorize(ctx context.Context, req *http.Request) (any, error) {
 	if now.After(exp.Time) {
 		return nil, connect.NewError(connect.CodeUnauthenticated, fmt.Errorf("token expired"))
 	}
+	if claims.ID != "" && denylist.Contains(claims.ID, now) {
+		return nil, connect.NewError(connect.CodeUnauthenticated, fmt.Errorf("token revoked"))
+	}
 	return &claims.UserID, nil
 }
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// RealIP sets the remote address of requests relayed by trusted proxies, e.g.
+// a load balancer, to the client address they forwarded in X-Forwarded-For,
+// so handlers see the client rather than the proxy.
+//
+// Clients can send X-Forwarded-For themselves, so the header is read from
+// the right, where the proxies append, and the first address that is not a
+// trusted proxy is taken. Requests from anywhere else keep their address.
+func RealIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	isTrusted := func(addr netip.Addr) bool {
+		addr = addr.Unmap()
+		for _, p := range trusted {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(next http.Handler) http.Handler {
+		if len(trusted) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			peer, err := parseAddr(r.RemoteAddr)
+			if err != nil || !isTrusted(peer) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			var hops []string
+			for _, v := range r.Header.Values("X-Forwarded-For") {
+				hops = append(hops, strings.Split(v, ",")...)
+			}
+			for i := len(hops) - 1; i >= 0; i-- {
+				addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+				if err != nil {
+					// Whatever is left of a malformed hop cannot be trusted.
+					break
+				}
+				if !isTrusted(addr) {
+					r = r.Clone(r.Context())
+					r.RemoteAddr = net.JoinHostPort(addr.Unmap().String(), "0")
+					break
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ParsePrefixes reads a comma-separated list of CIDR prefixes. Plain
+// addresses stand for themselves.
+func ParsePrefixes(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if !strings.Contains(f, "/") {
+			addr, err := netip.ParseAddr(f)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q", f)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prefix %q", f)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
+
+func parseAddr(remoteAddr string) (netip.Addr, error) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return netip.ParseAddr(host)
+}
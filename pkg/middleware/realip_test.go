@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRealIP(t *testing.T) {
+	trusted, err := ParsePrefixes("10.0.0.0/8, 192.0.2.1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		forwardedFor  []string
+		wantClientIP  string
+		wantUnchanged bool
+	}{
+		{
+			name:          "direct client",
+			remoteAddr:    "203.0.113.7:51234",
+			forwardedFor:  []string{"198.51.100.1"},
+			wantUnchanged: true,
+		},
+		{
+			name:         "through a trusted proxy",
+			remoteAddr:   "10.1.2.3:51234",
+			forwardedFor: []string{"203.0.113.7"},
+			wantClientIP: "203.0.113.7",
+		},
+		{
+			name:         "spoofed hops before the proxy are ignored",
+			remoteAddr:   "10.1.2.3:51234",
+			forwardedFor: []string{"198.51.100.1, 203.0.113.7"},
+			wantClientIP: "203.0.113.7",
+		},
+		{
+			name:         "chain of trusted proxies",
+			remoteAddr:   "10.1.2.3:51234",
+			forwardedFor: []string{"203.0.113.7, 192.0.2.1", "10.9.9.9"},
+			wantClientIP: "203.0.113.7",
+		},
+		{
+			name:          "no header",
+			remoteAddr:    "10.1.2.3:51234",
+			wantUnchanged: true,
+		},
+		{
+			name:          "malformed hop",
+			remoteAddr:    "10.1.2.3:51234",
+			forwardedFor:  []string{"203.0.113.7, unknown"},
+			wantUnchanged: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+			if tt.wantUnchanged {
+				require.Equal(t, tt.remoteAddr, got)
+			} else {
+				require.Equal(t, tt.wantClientIP+":0", got)
+			}
+		})
+	}
+}
+
+func TestParsePrefixes(t *testing.T) {
+	prefixes, err := ParsePrefixes("")
+	require.NoError(t, err)
+	require.Empty(t, prefixes)
+
+	_, err = ParsePrefixes("10.0.0.0/8,not-an-ip")
+	require.Error(t, err)
+}
@@ -1,17 +1,31 @@
 package oauth
 
+import "golang.org/x/oauth2"
+
 type App interface {
-	GetAuthURL() (string, error)
-	ExchangeCode(code string) (string, error)
+	// GetAuthURL returns the URL to send the user to. The provider passes
+	// state back to the redirect URL untouched; the caller must check it
+	// before calling ExchangeCode.
+	GetAuthURL(state string, opt GetAuthURLOption) (string, error)
+	ExchangeCode(code string, opt ExchangeCodeOption) (string, error)
 	GetUserProfile(accessToken string) (*UserProfile, error)
+	// SupportsPKCE reports whether the provider accepts PKCE (RFC 7636), so
+	// a code challenge can be sent with GetAuthURL and its verifier with
+	// ExchangeCode.
+	SupportsPKCE() bool
 }
 
 type GetAuthURLOption struct {
 	RedirectURL *string
+	// CodeChallengeVerifier, if set, is the PKCE code verifier whose S256
+	// challenge is sent along.
+	CodeChallengeVerifier string
 }
 
 type ExchangeCodeOption struct {
 	RedirectURL *string
+	// CodeVerifier is the PKCE code verifier the challenge was made from.
+	CodeVerifier string
 }
 
 type UserProfile struct {
@@ -19,3 +33,25 @@ type UserProfile struct {
 	Email     string
 	AvatarURL string
 }
+
+func authURLOptions(opt GetAuthURLOption) []oauth2.AuthCodeOption {
+	opts := []oauth2.AuthCodeOption{}
+	if opt.RedirectURL != nil {
+		opts = append(opts, oauth2.SetAuthURLParam("redirect_uri", *opt.RedirectURL))
+	}
+	if opt.CodeChallengeVerifier != "" {
+		opts = append(opts, oauth2.S256ChallengeOption(opt.CodeChallengeVerifier))
+	}
+	return opts
+}
+
+func exchangeOptions(opt ExchangeCodeOption) []oauth2.AuthCodeOption {
+	opts := []oauth2.AuthCodeOption{}
+	if opt.RedirectURL != nil {
+		opts = append(opts, oauth2.SetAuthURLParam("redirect_uri", *opt.RedirectURL))
+	}
+	if opt.CodeVerifier != "" {
+		opts = append(opts, oauth2.VerifierOption(opt.CodeVerifier))
+	}
+	return opts
+}
@@ -9,7 +9,6 @@ import (
 	"net/url"
 	"strings"
 
-	"github.com/google/uuid"
 	"github.com/spf13/viper"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/endpoints"
@@ -17,12 +16,7 @@ import (
 
 var _ App = (*github)(nil)
 
-func NewGitHub(httpClient *http.Client, randStrFunc func() string) App {
-	if randStrFunc == nil {
-		randStrFunc = func() string {
-			return uuid.NewString()
-		}
-	}
+func NewGitHub(httpClient *http.Client) App {
 	return &github{
 		config: &oauth2.Config{
 			ClientID:     viper.GetString("OAUTH_GITHUB_CLIENT_ID"),
@@ -31,34 +25,38 @@ func NewGitHub(httpClient *http.Client, randStrFunc func() string) App {
 			RedirectURL:  viper.GetString("OAUTH_GITHUB_REDIRECT_URL"),
 			Scopes:       []string{"user"}, // https://docs.github.com/ko/apps/oauth-apps/building-oauth-apps/scopes-for-oauth-apps#available-scopes
 		},
-		randStrFunc: randStrFunc,
-		httpClient:  httpClient,
+		httpClient: httpClient,
 	}
 }
 
 type github struct {
-	config      *oauth2.Config
-	httpClient  *http.Client
-	randStrFunc func() string
+	config     *oauth2.Config
+	httpClient *http.Client
 }
 
 // https://docs.github.com/en/apps/oauth-apps/building-oauth-apps/authorizing-oauth-apps#1-request-a-users-github-identity
-func (g *github) GetAuthURL() (string, error) {
+func (g *github) GetAuthURL(state string, opt GetAuthURLOption) (string, error) {
 	params := url.Values{}
 	params.Add("client_id", g.config.ClientID)
 	params.Add("scope", strings.Join(g.config.Scopes, " "))
-	params.Add("state", g.randStrFunc())
+	params.Add("state", state)
+	if opt.RedirectURL != nil {
+		params.Add("redirect_uri", *opt.RedirectURL)
+	}
 	authUrl := g.config.Endpoint.AuthURL + "?" + params.Encode()
 	return authUrl, nil
 }
 
-func (g *github) ExchangeCode(code string) (string, error) {
+func (g *github) ExchangeCode(code string, opt ExchangeCodeOption) (string, error) {
 
 	reqBody := map[string]string{
 		"client_id":     g.config.ClientID,
 		"client_secret": g.config.ClientSecret,
 		"code":          code,
 	}
+	if opt.RedirectURL != nil {
+		reqBody["redirect_uri"] = *opt.RedirectURL
+	}
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
@@ -96,6 +94,12 @@ func (g *github) ExchangeCode(code string) (string, error) {
 	return tokenResp.AccessToken, nil
 }
 
+// SupportsPKCE implements App. The GitHub app does not send PKCE parameters;
+// the state is what ties the callback to the sign-in.
+func (g *github) SupportsPKCE() bool {
+	return false
+}
+
 func (g *github) GetUserProfile(accessToken string) (*UserProfile, error) {
 	req, err := http.NewRequest("GET", "https://api.github.com/user", nil)
 	if err != nil {
@@ -0,0 +1,113 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/spf13/viper"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+)
+
+var _ App = (*google)(nil)
+
+const (
+	googleIssuer  = "https://accounts.google.com"
+	googleJWKSURL = "https://www.googleapis.com/oauth2/v3/certs"
+)
+
+// NewGoogle returns the Google app. Google signs users in with OpenID
+// Connect: ExchangeCode returns the ID token rather than an access token, and
+// GetUserProfile verifies its signature against Google's published keys,
+// which are fetched on first use and again whenever Google rotates them.
+//
+// If OAUTH_GOOGLE_HOSTED_DOMAIN is set, only accounts of that Google
+// Workspace domain are let in.
+func NewGoogle(httpClient *http.Client) App {
+	clientID := viper.GetString("OAUTH_GOOGLE_CLIENT_ID")
+	// The key set keeps its context for fetching keys later on.
+	ctx := oidc.ClientContext(context.Background(), httpClient)
+	keySet := oidc.NewRemoteKeySet(ctx, googleJWKSURL)
+	return &google{
+		config: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: viper.GetString("OAUTH_GOOGLE_CLIENT_SECRET"),
+			Endpoint:     endpoints.Google,
+			RedirectURL:  viper.GetString("OAUTH_GOOGLE_REDIRECT_URL"),
+			Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+		},
+		verifier:     oidc.NewVerifier(googleIssuer, keySet, &oidc.Config{ClientID: clientID}),
+		hostedDomain: viper.GetString("OAUTH_GOOGLE_HOSTED_DOMAIN"),
+		httpClient:   httpClient,
+	}
+}
+
+type google struct {
+	config       *oauth2.Config
+	verifier     *oidc.IDTokenVerifier
+	hostedDomain string
+	httpClient   *http.Client
+}
+
+// https://developers.google.com/identity/openid-connect/openid-connect#sendauthrequest
+func (g *google) GetAuthURL(state string, opt GetAuthURLOption) (string, error) {
+	opts := authURLOptions(opt)
+	if g.hostedDomain != "" {
+		// Only a hint for the account chooser; GetUserProfile enforces it.
+		opts = append(opts, oauth2.SetAuthURLParam("hd", g.hostedDomain))
+	}
+	return g.config.AuthCodeURL(state, opts...), nil
+}
+
+// ExchangeCode returns the ID token issued for code.
+func (g *google) ExchangeCode(code string, opt ExchangeCodeOption) (string, error) {
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, g.httpClient)
+	tok, err := g.config.Exchange(ctx, code, exchangeOptions(opt)...)
+	if err != nil {
+		return "", fmt.Errorf("failed to exchange code: %v", err)
+	}
+	idToken, ok := tok.Extra("id_token").(string)
+	if !ok || idToken == "" {
+		return "", fmt.Errorf("no id token in token response")
+	}
+	return idToken, nil
+}
+
+// SupportsPKCE implements App.
+func (g *google) SupportsPKCE() bool {
+	return true
+}
+
+// GetUserProfile verifies an ID token returned by ExchangeCode and reads the
+// profile from its claims. Only verified email addresses are accepted.
+func (g *google) GetUserProfile(idToken string) (*UserProfile, error) {
+	token, err := g.verifier.Verify(context.Background(), idToken)
+	if err != nil {
+		return nil, fmt.Errorf("verifying id token: %v", err)
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+		Picture       string `json:"picture"`
+		HostedDomain  string `json:"hd"`
+	}
+	if err := token.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("reading id token claims: %v", err)
+	}
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, fmt.Errorf("email not verified")
+	}
+	if g.hostedDomain != "" && claims.HostedDomain != g.hostedDomain {
+		return nil, fmt.Errorf("account not in domain %s", g.hostedDomain)
+	}
+
+	return &UserProfile{
+		Name:      claims.Name,
+		Email:     claims.Email,
+		AvatarURL: claims.Picture,
+	}, nil
+}
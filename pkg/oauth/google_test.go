@@ -0,0 +1,85 @@
+package oauth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+const testClientID = "client.apps.googleusercontent.com"
+
+func testGoogle(t *testing.T, hostedDomain string) (*google, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keySet := &oidc.StaticKeySet{PublicKeys: []crypto.PublicKey{key.Public()}}
+	return &google{
+		verifier:     oidc.NewVerifier(googleIssuer, keySet, &oidc.Config{ClientID: testClientID}),
+		hostedDomain: hostedDomain,
+	}, key
+}
+
+func signIDToken(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+	base := jwt.MapClaims{
+		"iss":            googleIssuer,
+		"aud":            testClientID,
+		"sub":            "1234",
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"email":          "gopher@example.com",
+		"email_verified": true,
+		"name":           "Gopher",
+		"picture":        "https://example.com/gopher.png",
+	}
+	for k, v := range claims {
+		base[k] = v
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodRS256, base).SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestGoogleGetUserProfile(t *testing.T) {
+	g, key := testGoogle(t, "")
+	profile, err := g.GetUserProfile(signIDToken(t, key, nil))
+	require.NoError(t, err)
+	require.Equal(t, &UserProfile{
+		Name:      "Gopher",
+		Email:     "gopher@example.com",
+		AvatarURL: "https://example.com/gopher.png",
+	}, profile)
+}
+
+func TestGoogleGetUserProfileRejects(t *testing.T) {
+	g, key := testGoogle(t, "")
+	_, other := testGoogle(t, "")
+	tests := map[string]string{
+		"foreign key":        signIDToken(t, other, nil),
+		"other audience":     signIDToken(t, key, jwt.MapClaims{"aud": "someone-else"}),
+		"other issuer":       signIDToken(t, key, jwt.MapClaims{"iss": "https://evil.example.com"}),
+		"expired":            signIDToken(t, key, jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}),
+		"unverified email":   signIDToken(t, key, jwt.MapClaims{"email_verified": false}),
+		"not a token at all": "garbage",
+	}
+	for name, idToken := range tests {
+		_, err := g.GetUserProfile(idToken)
+		require.Error(t, err, name)
+	}
+}
+
+func TestGoogleHostedDomain(t *testing.T) {
+	g, key := testGoogle(t, "example.com")
+	_, err := g.GetUserProfile(signIDToken(t, key, jwt.MapClaims{"hd": "example.com"}))
+	require.NoError(t, err)
+	_, err = g.GetUserProfile(signIDToken(t, key, nil))
+	require.Error(t, err, "personal accounts carry no hd claim")
+	_, err = g.GetUserProfile(signIDToken(t, key, jwt.MapClaims{"hd": "other.com"}))
+	require.Error(t, err)
+}
@@ -0,0 +1,199 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// testIssuer is a minimal OpenID provider: discovery, keys and a token
+// endpoint that answers any code with an ID token carrying claims.
+type testIssuer struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims
+	// verifier, if set, is the PKCE code verifier the token endpoint
+	// requires.
+	verifier string
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	iss := &testIssuer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                iss.URL,
+			"authorization_endpoint":                iss.URL + "/auth",
+			"token_endpoint":                        iss.URL + "/token",
+			"jwks_uri":                              iss.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+			"code_challenge_methods_supported":      []string{"plain", "S256"},
+		})
+	})
+	mux.HandleFunc("GET /keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": "test",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "good-code" || r.FormValue("code_verifier") != iss.verifier {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     iss.sign(t, iss.claims),
+		})
+	})
+	iss.Server = httptest.NewServer(mux)
+	t.Cleanup(iss.Close)
+	return iss
+}
+
+func (iss *testIssuer) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	base := jwt.MapClaims{
+		"iss": iss.URL,
+		"aud": "blog",
+		"sub": "1234",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		base[k] = v
+	}
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, base)
+	tok.Header["kid"] = "test"
+	signed, err := tok.SignedString(iss.key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestOIDCCodeFlow(t *testing.T) {
+	iss := newTestIssuer(t)
+	iss.claims = jwt.MapClaims{
+		"email":          "gopher@example.com",
+		"email_verified": true,
+		"name":           "Gopher",
+		"picture":        "https://example.com/gopher.png",
+	}
+	app, err := NewOIDC(context.Background(), iss.Client(), OIDCConfig{
+		Issuer:      iss.URL,
+		ClientID:    "blog",
+		RedirectURL: "https://blog.example.com/callback",
+	})
+	require.NoError(t, err)
+	require.True(t, app.SupportsPKCE())
+
+	authURL, err := app.GetAuthURL("state", GetAuthURLOption{})
+	require.NoError(t, err)
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	require.Equal(t, iss.URL+"/auth", u.Scheme+"://"+u.Host+u.Path)
+	require.Equal(t, "blog", u.Query().Get("client_id"))
+	require.Equal(t, "openid email profile", u.Query().Get("scope"))
+	require.Equal(t, "state", u.Query().Get("state"))
+	require.Empty(t, u.Query().Get("code_challenge"))
+
+	_, err = app.ExchangeCode("bad-code", ExchangeCodeOption{})
+	require.Error(t, err)
+	idToken, err := app.ExchangeCode("good-code", ExchangeCodeOption{})
+	require.NoError(t, err)
+	profile, err := app.GetUserProfile(idToken)
+	require.NoError(t, err)
+	require.Equal(t, &UserProfile{
+		Name:      "Gopher",
+		Email:     "gopher@example.com",
+		AvatarURL: "https://example.com/gopher.png",
+	}, profile)
+}
+
+func TestOIDCPKCE(t *testing.T) {
+	iss := newTestIssuer(t)
+	iss.claims = jwt.MapClaims{"email": "gopher@example.com"}
+	iss.verifier = oauth2.GenerateVerifier()
+	app, err := NewOIDC(context.Background(), iss.Client(), OIDCConfig{Issuer: iss.URL, ClientID: "blog"})
+	require.NoError(t, err)
+
+	authURL, err := app.GetAuthURL("state", GetAuthURLOption{CodeChallengeVerifier: iss.verifier})
+	require.NoError(t, err)
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	require.Equal(t, oauth2.S256ChallengeFromVerifier(iss.verifier), u.Query().Get("code_challenge"))
+	require.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+
+	_, err = app.ExchangeCode("good-code", ExchangeCodeOption{})
+	require.Error(t, err, "the verifier is required")
+	_, err = app.ExchangeCode("good-code", ExchangeCodeOption{CodeVerifier: oauth2.GenerateVerifier()})
+	require.Error(t, err)
+	_, err = app.ExchangeCode("good-code", ExchangeCodeOption{CodeVerifier: iss.verifier})
+	require.NoError(t, err)
+}
+
+func TestOIDCClaimMapping(t *testing.T) {
+	iss := newTestIssuer(t)
+	app, err := NewOIDC(context.Background(), iss.Client(), OIDCConfig{
+		Issuer:   iss.URL,
+		ClientID: "blog",
+		Claims:   ClaimMapping{Name: "preferred_username", Email: "upn"},
+	})
+	require.NoError(t, err)
+
+	profile, err := app.GetUserProfile(iss.sign(t, jwt.MapClaims{
+		"preferred_username": "gopher",
+		"upn":                "gopher@corp.example.com",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, &UserProfile{Name: "gopher", Email: "gopher@corp.example.com"}, profile)
+}
+
+func TestOIDCRejects(t *testing.T) {
+	iss := newTestIssuer(t)
+	app, err := NewOIDC(context.Background(), iss.Client(), OIDCConfig{Issuer: iss.URL, ClientID: "blog"})
+	require.NoError(t, err)
+
+	tests := map[string]string{
+		"unverified email": iss.sign(t, jwt.MapClaims{"email": "gopher@example.com", "email_verified": false}),
+		"no email":         iss.sign(t, jwt.MapClaims{"name": "gopher"}),
+		"other audience":   iss.sign(t, jwt.MapClaims{"email": "gopher@example.com", "aud": "other"}),
+	}
+	for name, idToken := range tests {
+		_, err := app.GetUserProfile(idToken)
+		require.Error(t, err, name)
+	}
+}
+
+func TestNewOIDCDiscoveryFails(t *testing.T) {
+	iss := newTestIssuer(t)
+	_, err := NewOIDC(context.Background(), iss.Client(), OIDCConfig{Issuer: iss.URL + "/nowhere", ClientID: "blog"})
+	require.Error(t, err)
+	_, err = NewOIDC(context.Background(), iss.Client(), OIDCConfig{Issuer: iss.URL})
+	require.Error(t, err, "client id required")
+}
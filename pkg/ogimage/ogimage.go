@@ -0,0 +1,219 @@
+// Package ogimage draws the default social preview image of a post: its title
+// and author on a plain card, at the size Open Graph consumers expect.
+package ogimage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"unicode"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Size of the image. 1200x630 is what Facebook, Slack, Discord and Twitter
+// show in full as a large preview.
+const (
+	Width  = 1200
+	Height = 630
+)
+
+const (
+	margin       = 80
+	titleSize    = 64
+	titleLeading = 1.25
+	maxTitleRows = 3
+	authorSize   = 32
+	avatarSize   = 96
+	ellipsis     = "…"
+)
+
+var (
+	background = color.RGBA{R: 0x11, G: 0x18, B: 0x27, A: 0xff}
+	accent     = color.RGBA{R: 0x38, G: 0xbd, B: 0xf8, A: 0xff}
+	foreground = color.RGBA{R: 0xf9, G: 0xfa, B: 0xfb, A: 0xff}
+	muted      = color.RGBA{R: 0x9c, G: 0xa3, B: 0xaf, A: 0xff}
+)
+
+// Card is what goes on an image.
+type Card struct {
+	Title  string
+	Author string
+	// Site is shown in the bottom right corner, if set.
+	Site string
+	// Avatar of the author, drawn as a circle. Without one, the initial of
+	// the author is drawn instead.
+	Avatar image.Image
+}
+
+// Renderer draws cards. It is safe for concurrent use.
+type Renderer struct {
+	title *opentype.Font
+	text  *opentype.Font
+}
+
+// NewRenderer returns a renderer drawing text in the given TrueType or
+// OpenType font. Without one, the Go fonts are used; they cover Latin,
+// Greek and Cyrillic only, so blogs writing in Korean or other scripts should
+// supply a font that covers them.
+func NewRenderer(fontData []byte) (*Renderer, error) {
+	if fontData != nil {
+		f, err := opentype.Parse(fontData)
+		if err != nil {
+			return nil, fmt.Errorf("parsing font: %v", err)
+		}
+		return &Renderer{title: f, text: f}, nil
+	}
+	title, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("parsing font: %v", err)
+	}
+	text, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("parsing font: %v", err)
+	}
+	return &Renderer{title: title, text: text}, nil
+}
+
+// PNG draws c and encodes it as a PNG.
+func (r *Renderer) PNG(c Card) ([]byte, error) {
+	// Faces keep glyph buffers, so every image gets its own.
+	titleFace, err := opentype.NewFace(r.title, &opentype.FaceOptions{Size: titleSize, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("loading title font: %v", err)
+	}
+	defer titleFace.Close()
+	textFace, err := opentype.NewFace(r.text, &opentype.FaceOptions{Size: authorSize, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("loading text font: %v", err)
+	}
+	defer textFace.Close()
+
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, Width, 12), image.NewUniform(accent), image.Point{}, draw.Src)
+
+	rows := Wrap(titleFace, c.Title, Width-2*margin, maxTitleRows)
+	lineHeight := int(titleSize * titleLeading)
+	for i, row := range rows {
+		drawText(img, titleFace, foreground, row, margin, margin+titleSize+i*lineHeight)
+	}
+
+	avatarTop := Height - margin - avatarSize
+	avatarRect := image.Rect(margin, avatarTop, margin+avatarSize, avatarTop+avatarSize)
+	if c.Avatar != nil {
+		scaled := image.NewRGBA(image.Rect(0, 0, avatarSize, avatarSize))
+		draw.CatmullRom.Scale(scaled, scaled.Bounds(), c.Avatar, c.Avatar.Bounds(), draw.Src, nil)
+		draw.DrawMask(img, avatarRect, scaled, image.Point{}, circle(avatarSize), image.Point{}, draw.Over)
+	} else {
+		draw.DrawMask(img, avatarRect, image.NewUniform(accent), image.Point{}, circle(avatarSize), image.Point{}, draw.Over)
+		initial := strings.ToUpper(firstRune(c.Author))
+		w := font.MeasureString(textFace, initial).Round()
+		drawText(img, textFace, background, initial, margin+(avatarSize-w)/2, avatarTop+(avatarSize+authorSize*3/4)/2)
+	}
+	baseline := avatarTop + (avatarSize+authorSize*3/4)/2
+	authorWidth := Width - 2*margin - avatarSize - 24
+	if c.Site != "" {
+		siteWidth := font.MeasureString(textFace, c.Site).Round()
+		drawText(img, textFace, muted, c.Site, Width-margin-siteWidth, baseline)
+		authorWidth -= siteWidth + 24
+	}
+	if author := Wrap(textFace, c.Author, authorWidth, 1); len(author) > 0 {
+		drawText(img, textFace, foreground, author[0], margin+avatarSize+24, baseline)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("encoding image: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Wrap breaks text into at most maxRows rows no wider than width pixels when
+// drawn in face. Rows break at spaces; words too wide for a row of their own,
+// such as long runs of CJK text, break between any two characters. Text that
+// does not fit ends in an ellipsis.
+func Wrap(face font.Face, text string, width, maxRows int) []string {
+	fits := func(s string) bool {
+		return font.MeasureString(face, s).Round() <= width
+	}
+	var rows []string
+	row := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if row != "" {
+			candidate = row + " " + word
+		}
+		if fits(candidate) {
+			row = candidate
+			continue
+		}
+		if row != "" {
+			rows = append(rows, row)
+			row = ""
+		}
+		for _, r := range word {
+			if row != "" && !fits(row+string(r)) {
+				rows = append(rows, row)
+				row = ""
+			}
+			row += string(r)
+		}
+	}
+	if row != "" {
+		rows = append(rows, row)
+	}
+	if len(rows) <= maxRows {
+		return rows
+	}
+	rows = rows[:maxRows]
+	last := []rune(rows[maxRows-1])
+	for len(last) > 0 && !fits(string(last)+ellipsis) {
+		last = last[:len(last)-1]
+	}
+	rows[maxRows-1] = strings.TrimRightFunc(string(last), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	}) + ellipsis
+	return rows
+}
+
+func drawText(dst draw.Image, face font.Face, c color.Color, text string, x, y int) {
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
+
+func firstRune(s string) string {
+	for _, r := range strings.TrimSpace(s) {
+		return string(r)
+	}
+	return ""
+}
+
+// circle is a mask of the circle inscribed in a square of the given size.
+type circle int
+
+func (c circle) ColorModel() color.Model { return color.AlphaModel }
+
+func (c circle) Bounds() image.Rectangle { return image.Rect(0, 0, int(c), int(c)) }
+
+func (c circle) At(x, y int) color.Color {
+	r := float64(c) / 2
+	dx, dy := float64(x)+0.5-r, float64(y)+0.5-r
+	if dx*dx+dy*dy <= r*r {
+		return color.Opaque
+	}
+	return color.Transparent
+}
@@ -0,0 +1,51 @@
+package ogimage
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/basicfont"
+)
+
+func TestPNG(t *testing.T) {
+	r, err := NewRenderer(nil)
+	require.NoError(t, err)
+
+	avatar := image.NewRGBA(image.Rect(0, 0, 40, 40))
+	for _, c := range []Card{
+		{Title: "Hello, world", Author: "gopher", Site: "gaesemo.dev"},
+		{Title: strings.Repeat("A very long title ", 20), Author: "gopher", Avatar: avatar},
+		{},
+	} {
+		body, err := r.PNG(c)
+		require.NoError(t, err)
+		img, err := png.Decode(bytes.NewReader(body))
+		require.NoError(t, err)
+		require.Equal(t, image.Rect(0, 0, Width, Height), img.Bounds())
+	}
+}
+
+func TestNewRendererInvalidFont(t *testing.T) {
+	_, err := NewRenderer([]byte("not a font"))
+	require.Error(t, err)
+}
+
+func TestWrap(t *testing.T) {
+	// Every basicfont glyph is 7 pixels wide.
+	face := basicfont.Face7x13
+	require.Empty(t, Wrap(face, "", 70, 3))
+	require.Equal(t, []string{"one two", "three"}, Wrap(face, "one two three", 70, 3))
+	require.Equal(t, []string{"abcdefghij", "klm"}, Wrap(face, "abcdefghijklm", 70, 3), "long words break anywhere")
+	require.Equal(t, []string{"one two", "three fou…"}, Wrap(face, "one two three four five", 70, 2))
+}
+
+func TestCircle(t *testing.T) {
+	c := circle(10)
+	require.Equal(t, color.Opaque, c.At(5, 5))
+	require.Equal(t, color.Transparent, c.At(0, 0))
+}
@@ -0,0 +1,110 @@
+// Package pgtest gives tests a PostgreSQL database with the schema loaded.
+//
+// All tests of a package share one container; each test gets a fresh
+// database cloned from a template, so tests cannot see each other's rows.
+// Tests are skipped when no container runtime is available.
+package pgtest
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/testcontainers/testcontainers-go"
+	tcpg "github.com/testcontainers/testcontainers-go/modules/postgres"
+)
+
+// templateDB is the database the schema is loaded into. Test databases are
+// copies of it, so nothing may stay connected to it.
+const templateDB = "blog_template"
+
+var (
+	startOnce sync.Once
+	startErr  error
+	// admin is connected to the postgres database of the container, from
+	// where test databases are created.
+	admin *pgxpool.Pool
+	// createMu serializes CREATE DATABASE, which fails when another copy of
+	// the template is being made at the same time.
+	createMu sync.Mutex
+	seq      atomic.Int64
+)
+
+// New returns a pool connected to a new database with the schema of
+// db/postgres/schema.sql. The pool is closed when t ends.
+func New(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	startOnce.Do(func() {
+		admin, startErr = start(context.Background())
+	})
+	if startErr != nil {
+		t.Fatalf("starting postgres: %v", startErr)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	name := fmt.Sprintf("test_%d", seq.Add(1))
+	createMu.Lock()
+	_, err := admin.Exec(ctx, "CREATE DATABASE "+name+" TEMPLATE "+templateDB)
+	createMu.Unlock()
+	if err != nil {
+		t.Fatalf("creating database: %v", err)
+	}
+
+	cfg := admin.Config()
+	cfg.ConnConfig.Database = name
+	db, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatalf("connecting to %s: %v", name, err)
+	}
+	t.Cleanup(db.Close)
+	return db
+}
+
+// start runs the container. It is left for the testcontainers reaper to
+// remove once the test binary exits.
+func start(ctx context.Context) (*pgxpool.Pool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	pgc, err := tcpg.Run(ctx, "postgres:17-alpine",
+		tcpg.WithUsername("blog"),
+		tcpg.WithPassword("blog"),
+		tcpg.WithDatabase(templateDB),
+		tcpg.WithInitScripts(schemaPath()),
+		tcpg.BasicWaitStrategies(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("running pg container: %v", err)
+	}
+	connStr, err := pgc.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		return nil, fmt.Errorf("getting connection string: %v", err)
+	}
+	cfg, err := pgxpool.ParseConfig(connStr)
+	if err != nil {
+		return nil, fmt.Errorf("parsing connection string: %v", err)
+	}
+	cfg.ConnConfig.Database = "postgres"
+	db, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("connecting db: %v", err)
+	}
+	return db, nil
+}
+
+// schemaPath locates the schema relative to this file, so tests find it
+// whatever package they run in.
+func schemaPath() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "db", "postgres", "schema.sql")
+}
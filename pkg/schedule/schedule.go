@@ -0,0 +1,25 @@
+package schedule
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Every runs job once per interval until ctx is done. A failing run is logged
+// and retried on the next tick instead of stopping the loop.
+func Every(ctx context.Context, name string, interval time.Duration, job func(c context.Context) error) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := job(ctx); err != nil {
+				slog.ErrorContext(ctx, "running scheduled job", slog.String("job", name), slog.Any("error", err))
+			}
+		}
+	}
+}
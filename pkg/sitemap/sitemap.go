@@ -0,0 +1,76 @@
+// Package sitemap encodes sitemaps and sitemap indexes following the
+// sitemaps.org protocol.
+package sitemap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"time"
+)
+
+const (
+	// MaxURLs is the most URLs a single sitemap may list.
+	MaxURLs = 50000
+
+	ContentType = "application/xml; charset=utf-8"
+
+	namespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+)
+
+// URL is a page listed in a sitemap, or a sitemap listed in an index.
+type URL struct {
+	Loc     string
+	LastMod time.Time
+}
+
+type urlSet struct {
+	XMLName xml.Name `xml:"urlset"`
+	XMLNS   string   `xml:"xmlns,attr"`
+	URLs    []entry  `xml:"url"`
+}
+
+type index struct {
+	XMLName  xml.Name `xml:"sitemapindex"`
+	XMLNS    string   `xml:"xmlns,attr"`
+	Sitemaps []entry  `xml:"sitemap"`
+}
+
+type entry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// URLSet encodes a sitemap listing urls. Callers split larger sets into
+// sitemaps of at most MaxURLs.
+func URLSet(urls []URL) ([]byte, error) {
+	return encode(urlSet{XMLNS: namespace, URLs: entries(urls)})
+}
+
+// Index encodes a sitemap index listing sitemaps.
+func Index(sitemaps []URL) ([]byte, error) {
+	return encode(index{XMLNS: namespace, Sitemaps: entries(sitemaps)})
+}
+
+func entries(urls []URL) []entry {
+	out := make([]entry, 0, len(urls))
+	for _, u := range urls {
+		e := entry{Loc: u.Loc}
+		if !u.LastMod.IsZero() {
+			e.LastMod = u.LastMod.UTC().Format(time.RFC3339)
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func encode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
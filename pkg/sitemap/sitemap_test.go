@@ -0,0 +1,42 @@
+package sitemap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLSet(t *testing.T) {
+	out, err := URLSet([]URL{
+		{Loc: "https://blog.example.com/posts/a&b", LastMod: time.Date(2025, 7, 1, 21, 0, 0, 0, time.FixedZone("KST", 9*60*60))},
+		{Loc: "https://blog.example.com/authors/1"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>https://blog.example.com/posts/a&amp;b</loc>
+    <lastmod>2025-07-01T12:00:00Z</lastmod>
+  </url>
+  <url>
+    <loc>https://blog.example.com/authors/1</loc>
+  </url>
+</urlset>
+`, string(out))
+}
+
+func TestIndex(t *testing.T) {
+	out, err := Index([]URL{
+		{Loc: "https://blog.example.com/sitemaps/posts-1.xml", LastMod: time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)},
+	})
+	require.NoError(t, err)
+	require.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap>
+    <loc>https://blog.example.com/sitemaps/posts-1.xml</loc>
+    <lastmod>2025-07-01T12:00:00Z</lastmod>
+  </sitemap>
+</sitemapindex>
+`, string(out))
+}
@@ -0,0 +1,172 @@
+package slug
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// MaxLength bounds the length of a slug in bytes, not counting the suffix
+	// Unique may add.
+	MaxLength = 80
+	// Fallback is used for titles with nothing left to put in a URL.
+	Fallback = "post"
+)
+
+// Make turns a title into a URL slug of lower-case ASCII letters and digits
+// separated by single hyphens. Latin letters lose their accents and Hangul is
+// romanized, so "Go 언어 시작하기" becomes "go-eoneo-sijakhagi". Other
+// scripts are dropped; a title without anything usable yields Fallback.
+func Make(title string) string {
+	if s := Text(title); s != "" {
+		return s
+	}
+	return Fallback
+}
+
+// Text is Make without the fallback: it returns "" for text with nothing
+// usable in a URL.
+func Text(text string) string {
+	var sb strings.Builder
+	hyphen := false
+	put := func(s string) {
+		if hyphen && sb.Len() > 0 {
+			sb.WriteByte('-')
+		}
+		hyphen = false
+		sb.WriteString(s)
+	}
+
+	runes := []rune(norm.NFKD.String(text))
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			put(string(unicode.ToLower(r)))
+		case unicode.Is(unicode.Mn, r):
+			// Accents split off by NFKD.
+		case isHangul(r):
+			// NFKD decomposed the syllables into jamo; romanize the whole
+			// word at once so sound changes between syllables carry over.
+			j := i
+			for j < len(runes) && isHangul(runes[j]) {
+				j++
+			}
+			put(romanize(norm.NFC.String(string(runes[i:j]))))
+			i = j - 1
+		case r == '\'' || r == '’':
+			// Keep "what's" as "whats" instead of "what-s".
+		default:
+			hyphen = true
+		}
+	}
+
+	s := sb.String()
+	if len(s) > MaxLength {
+		s = s[:MaxLength]
+		if i := strings.LastIndexByte(s, '-'); i > MaxLength/2 {
+			s = s[:i]
+		}
+		s = strings.TrimRight(s, "-")
+	}
+	return s
+}
+
+// Unique returns base if it is not taken, otherwise the first of base-2,
+// base-3, … that is not.
+func Unique(base string, taken func(slug string) bool) string {
+	if !taken(base) {
+		return base
+	}
+	for n := 2; ; n++ {
+		s := base + "-" + strconv.Itoa(n)
+		if !taken(s) {
+			return s
+		}
+	}
+}
+
+// HasBase reports whether s is base or one of the variants Unique derives
+// from it.
+func HasBase(s, base string) bool {
+	if s == base {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(s, base+"-")
+	if !ok || suffix == "" || suffix[0] == '0' {
+		return false
+	}
+	n, err := strconv.Atoi(suffix)
+	return err == nil && n >= 2
+}
+
+const (
+	syllableFirst = 0xAC00
+	syllableLast  = 0xD7A3
+	medials       = 21
+	finals        = 28
+	initialIeung  = 11 // ㅇ, silent at the start of a syllable
+	initialRieul  = 5  // ㄹ
+	finalRieul    = 8  // ㄹ
+)
+
+// Revised Romanization of Korean, indexed by jamo position in the Unicode
+// syllable block.
+var (
+	initialRoman = [...]string{"g", "kk", "n", "d", "tt", "r", "m", "b", "pp", "s", "ss", "", "j", "jj", "ch", "k", "t", "p", "h"}
+	medialRoman  = [...]string{"a", "ae", "ya", "yae", "eo", "e", "yeo", "ye", "o", "wa", "wae", "oe", "yo", "u", "wo", "we", "wi", "yu", "eu", "ui", "i"}
+	finalRoman   = [...]string{"", "k", "k", "k", "n", "n", "n", "t", "l", "k", "m", "l", "l", "l", "p", "l", "m", "p", "p", "t", "t", "ng", "t", "t", "k", "t", "p", "t"}
+	// A final consonant followed by a silent ㅇ is pronounced at the start
+	// of the next syllable. Clusters keep their first consonant in place.
+	linkedFinal = [...]struct{ stay, move string }{
+		{"", ""}, {"", "g"}, {"", "kk"}, {"k", "s"}, {"", "n"}, {"n", "j"}, {"n", ""}, {"", "d"},
+		{"", "r"}, {"l", "g"}, {"l", "m"}, {"l", "b"}, {"l", "s"}, {"l", "t"}, {"l", "p"}, {"l", ""},
+		{"", "m"}, {"", "b"}, {"p", "s"}, {"", "s"}, {"", "ss"}, {"ng", ""}, {"", "j"}, {"", "ch"},
+		{"", "k"}, {"", "t"}, {"", "p"}, {"", ""},
+	}
+)
+
+func isHangul(r rune) bool {
+	return unicode.Is(unicode.Hangul, r)
+}
+
+// romanize writes a run of Hangul in Latin letters. Only the sound changes
+// that matter most for readable URLs are applied: a final consonant moving
+// over to a following vowel (한국어 → hangugeo) and ㄹㄹ (블로그 → beullogeu).
+func romanize(word string) string {
+	type syllable struct{ initial, medial, final int }
+	var syllables []syllable
+	var sb strings.Builder
+	for _, r := range word {
+		if r < syllableFirst || r > syllableLast {
+			// Lone jamo; there is no syllable to read them in.
+			continue
+		}
+		i := int(r - syllableFirst)
+		syllables = append(syllables, syllable{i / (medials * finals), i / finals % medials, i % finals})
+	}
+	for k, s := range syllables {
+		initial := initialRoman[s.initial]
+		if k > 0 {
+			prev := syllables[k-1]
+			switch {
+			case s.initial == initialIeung && prev.final != 0:
+				initial = linkedFinal[prev.final].move
+			case s.initial == initialRieul && prev.final == finalRieul:
+				initial = "l"
+			}
+		}
+		final := finalRoman[s.final]
+		if k+1 < len(syllables) && syllables[k+1].initial == initialIeung && s.final != 0 {
+			final = linkedFinal[s.final].stay
+		}
+		sb.WriteString(initial)
+		sb.WriteString(medialRoman[s.medial])
+		sb.WriteString(final)
+	}
+	return sb.String()
+}
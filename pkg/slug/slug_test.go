@@ -0,0 +1,59 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{title: "Hello, World!", want: "hello-world"},
+		{title: "  Learning   Go -- with ConnectRPC  ", want: "learning-go-with-connectrpc"},
+		{title: "What's new in Go 1.24", want: "whats-new-in-go-1-24"},
+		{title: "Café crème brûlée", want: "cafe-creme-brulee"},
+		{title: "Go 언어 시작하기", want: "go-eoneo-sijakhagi"},
+		{title: "개발 블로그", want: "gaebal-beullogeu"},
+		{title: "한국어 서울", want: "hangugeo-seoul"},
+		{title: "읽어 볼 코드", want: "ilgeo-bol-kodeu"},
+		{title: "ㅋㅋㅋ", want: Fallback},
+		{title: "日本語", want: Fallback},
+		{title: "", want: Fallback},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			require.Equal(t, tt.want, Make(tt.title))
+		})
+	}
+}
+
+func TestMakeTruncates(t *testing.T) {
+	title := strings.Repeat("word ", 40)
+	got := Make(title)
+	require.LessOrEqual(t, len(got), MaxLength)
+	require.False(t, strings.HasSuffix(got, "-"))
+	require.True(t, strings.HasSuffix(got, "word"))
+}
+
+func TestUnique(t *testing.T) {
+	taken := map[string]bool{"hello": true, "hello-2": true}
+	isTaken := func(s string) bool { return taken[s] }
+
+	require.Equal(t, "hello-3", Unique("hello", isTaken))
+	require.Equal(t, "world", Unique("world", isTaken))
+}
+
+func TestHasBase(t *testing.T) {
+	require.True(t, HasBase("hello", "hello"))
+	require.True(t, HasBase("hello-2", "hello"))
+	require.True(t, HasBase("hello-15", "hello"))
+	require.False(t, HasBase("hello-1", "hello"))
+	require.False(t, HasBase("hello-02", "hello"))
+	require.False(t, HasBase("hello-world", "hello"))
+	require.False(t, HasBase("hello-", "hello"))
+	require.False(t, HasBase("hell", "hello"))
+}
@@ -0,0 +1,98 @@
+package snippet
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+const (
+	markOpen  = "<mark>"
+	markClose = "</mark>"
+	ellipsis  = "…"
+)
+
+// Highlight returns a window of about width runes of text around the first
+// occurrence of any of terms, with every occurrence wrapped in <mark> tags.
+// Matching is case-insensitive substring matching, which also finds Korean
+// words followed by particles (e.g. "블로그" in "블로그를"). The result is
+// HTML-escaped apart from the marks.
+func Highlight(text string, terms []string, width int) string {
+	runes := []rune(collapseSpace(text))
+	lower := []rune(strings.ToLower(string(runes)))
+	if len(lower) != len(runes) {
+		// Lower-casing changed the rune count, so offsets would not line up.
+		lower = runes
+	}
+	needles := make([][]rune, 0, len(terms))
+	for _, t := range terms {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			needles = append(needles, []rune(t))
+		}
+	}
+
+	marks := make([]bool, len(runes))
+	first := -1
+	for _, n := range needles {
+		for i := 0; i+len(n) <= len(lower); i++ {
+			if !hasPrefix(lower[i:], n) {
+				continue
+			}
+			if first == -1 || i < first {
+				first = i
+			}
+			for j := i; j < i+len(n); j++ {
+				marks[j] = true
+			}
+		}
+	}
+
+	start, end := 0, len(runes)
+	if width > 0 && len(runes) > width {
+		if first > width/3 {
+			start = first - width/3
+		}
+		end = min(start+width, len(runes))
+		start = max(end-width, 0)
+	}
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString(ellipsis)
+	}
+	inMark := false
+	for i := start; i < end; i++ {
+		if marks[i] && !inMark {
+			sb.WriteString(markOpen)
+			inMark = true
+		}
+		if !marks[i] && inMark {
+			sb.WriteString(markClose)
+			inMark = false
+		}
+		sb.WriteString(html.EscapeString(string(runes[i])))
+	}
+	if inMark {
+		sb.WriteString(markClose)
+	}
+	if end < len(runes) {
+		sb.WriteString(ellipsis)
+	}
+	return sb.String()
+}
+
+func hasPrefix(s, prefix []rune) bool {
+	if len(s) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if s[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.FieldsFunc(s, unicode.IsSpace), " ")
+}
@@ -0,0 +1,67 @@
+package snippet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		width int
+		want  string
+	}{
+		{
+			name:  "case insensitive",
+			text:  "Learning Go with ConnectRPC",
+			terms: []string{"go", "connect"},
+			want:  "Learning <mark>Go</mark> with <mark>Connect</mark>RPC",
+		},
+		{
+			name:  "korean with particle",
+			text:  "개발 블로그를 시작했습니다",
+			terms: []string{"블로그"},
+			want:  "개발 <mark>블로그</mark>를 시작했습니다",
+		},
+		{
+			name:  "overlapping terms merge",
+			text:  "postgres",
+			terms: []string{"post", "stgr"},
+			want:  "<mark>postgr</mark>es",
+		},
+		{
+			name:  "escapes html",
+			text:  "<script>alert(1)</script> go",
+			terms: []string{"go"},
+			want:  "&lt;script&gt;alert(1)&lt;/script&gt; <mark>go</mark>",
+		},
+		{
+			name:  "window around first match",
+			text:  "aaaaaaaaaa bbbbbbbbbb match cccccccccc dddddddddd",
+			terms: []string{"match"},
+			width: 15,
+			want:  "…bbbb <mark>match</mark> cccc…",
+		},
+		{
+			name:  "no match keeps the start",
+			text:  "one two three four",
+			terms: []string{"five"},
+			width: 7,
+			want:  "one two…",
+		},
+		{
+			name:  "collapses whitespace",
+			text:  "line one\n\n  line two",
+			terms: nil,
+			want:  "line one line two",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, Highlight(tt.text, tt.terms, tt.width))
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package tag
+
+import (
+	"strings"
+)
+
+// Normalize folds case and collapses runs of whitespace into a single '-', so
+// " Go  Lang", "go lang" and "GO-LANG" all become "go-lang".
+func Normalize(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), "-")
+}
+
+// NormalizeAll normalizes names, dropping empty and duplicate tags while
+// keeping the order in which they first appear.
+func NormalizeAll(names []string) []string {
+	seen := map[string]bool{}
+	tags := []string{}
+	for _, name := range names {
+		t := Normalize(name)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		tags = append(tags, t)
+	}
+	return tags
+}
@@ -0,0 +1,28 @@
+package tag
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"go":            "go",
+		"  Go  ":        "go",
+		"Go Lang":       "go-lang",
+		"go\tlang":      "go-lang",
+		"GO-LANG":       "go-lang",
+		"데이터 베이스":       "데이터-베이스",
+		"   ":           "",
+		"PostgreSQL 17": "postgresql-17",
+	}
+	for in, want := range tests {
+		require.Equal(t, want, Normalize(in), "normalizing %q", in)
+	}
+}
+
+func TestNormalizeAll(t *testing.T) {
+	got := NormalizeAll([]string{"Go", "rust", " go ", "", "Rust", "데이터 베이스"})
+	require.Equal(t, []string{"go", "rust", "데이터-베이스"}, got)
+}
@@ -0,0 +1,126 @@
+// Package textstat derives an excerpt, a word count and a reading time from
+// rendered post bodies.
+package textstat
+
+import (
+	"html"
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+const (
+	// ExcerptLength is the length of excerpts in runes.
+	ExcerptLength = 200
+
+	// Reading speeds. Korean, Chinese and Japanese are read character by
+	// character, so they are timed per character rather than per
+	// space-separated word.
+	wordsPerMinute = 230
+	charsPerMinute = 500
+
+	ellipsis = "…"
+)
+
+// Stats describes the text of a post.
+type Stats struct {
+	Excerpt string
+	// Words counts space-separated words, with every CJK character counted
+	// as a word of its own.
+	Words          int
+	ReadingMinutes int
+}
+
+var (
+	stripTags = bluemonday.StrictPolicy()
+	// blockTag matches the tags that separate blocks of text, which must not
+	// run together once the tags are gone.
+	blockTag = regexp.MustCompile(`(?i)</?(?:p|br|div|h[1-6]|li|ul|ol|pre|blockquote|table|tr|td|th|hr)\b`)
+	// codeBlock matches code listings, which make poor excerpts.
+	codeBlock = regexp.MustCompile(`(?is)<pre\b.*?</pre>`)
+)
+
+// FromHTML computes the stats of a body rendered to HTML. The excerpt skips
+// code listings, while the word count and reading time include them.
+func FromHTML(body string) Stats {
+	words, chars := Count(PlainText(body))
+	return Stats{
+		Excerpt:        Excerpt(PlainText(codeBlock.ReplaceAllString(body, " ")), ExcerptLength),
+		Words:          words + chars,
+		ReadingMinutes: ReadingMinutes(words, chars),
+	}
+}
+
+// PlainText returns the text of an HTML fragment with whitespace collapsed.
+func PlainText(fragment string) string {
+	text := blockTag.ReplaceAllString(fragment, " $0")
+	text = html.UnescapeString(stripTags.Sanitize(text))
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// Count returns the number of space-separated words in text, not counting CJK
+// characters, and the number of CJK characters. A word needs at least one
+// letter or digit, so stray punctuation is not counted.
+func Count(text string) (words, chars int) {
+	inWord, hasAlnum := false, false
+	endWord := func() {
+		if inWord && hasAlnum {
+			words++
+		}
+		inWord, hasAlnum = false, false
+	}
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			endWord()
+			chars++
+		case unicode.IsSpace(r):
+			endWord()
+		default:
+			inWord = true
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				hasAlnum = true
+			}
+		}
+	}
+	endWord()
+	return words, chars
+}
+
+// ReadingMinutes estimates how long text with the given counts takes to read,
+// rounded up to whole minutes. Any text takes at least a minute.
+func ReadingMinutes(words, chars int) int {
+	if words == 0 && chars == 0 {
+		return 0
+	}
+	minutes := float64(words)/wordsPerMinute + float64(chars)/charsPerMinute
+	return max(1, int(math.Ceil(minutes)))
+}
+
+// Excerpt shortens text to at most n runes plus an ellipsis. The cut falls on
+// a space if there is one near the end, so Latin words and Korean eojeol are
+// kept whole; text without spaces, e.g. Japanese, is cut mid-run.
+func Excerpt(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	cut := n
+	for i := n; i > n*3/4; i-- {
+		if unicode.IsSpace(runes[i]) {
+			cut = i
+			break
+		}
+	}
+	trimmed := strings.TrimRightFunc(string(runes[:cut]), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	})
+	return trimmed + ellipsis
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hangul, unicode.Hiragana, unicode.Katakana)
+}
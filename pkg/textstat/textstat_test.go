@@ -0,0 +1,60 @@
+package textstat
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		text  string
+		words int
+		chars int
+	}{
+		{text: "", words: 0, chars: 0},
+		{text: "Hello, world - again!", words: 3, chars: 0},
+		{text: "블로그를 시작했다", words: 0, chars: 8},
+		{text: "Go 언어로 서버 만들기", words: 1, chars: 8},
+		{text: "日本語のテキスト", words: 0, chars: 8},
+		{text: "version 1.24", words: 2, chars: 0},
+	}
+	for _, tt := range tests {
+		words, chars := Count(tt.text)
+		require.Equal(t, tt.words, words, tt.text)
+		require.Equal(t, tt.chars, chars, tt.text)
+	}
+}
+
+func TestReadingMinutes(t *testing.T) {
+	require.Equal(t, 0, ReadingMinutes(0, 0))
+	require.Equal(t, 1, ReadingMinutes(10, 0))
+	require.Equal(t, 2, ReadingMinutes(231, 0))
+	require.Equal(t, 3, ReadingMinutes(0, 1200), "CJK text is timed per character")
+	require.Equal(t, 2, ReadingMinutes(116, 250))
+}
+
+func TestExcerpt(t *testing.T) {
+	require.Equal(t, "short text", Excerpt("short text", 20))
+	require.Equal(t, "one two three…", Excerpt("one two three four", 16))
+	require.Equal(t, "블로그를 시작했다…", Excerpt("블로그를 시작했다. 첫 글입니다", 10))
+	require.Equal(t, "日本語の…", Excerpt("日本語のテキスト", 4))
+}
+
+func TestFromHTML(t *testing.T) {
+	body := `<h1 id="intro">Intro</h1>
+<p>Hello &amp; <em>wel</em>come.</p><p>Second</p>
+<pre><code class="chroma">fmt.Println("hi")</code></pre>
+<p>안녕하세요</p>`
+	stats := FromHTML(body)
+	require.Equal(t, "Intro Hello & welcome. Second 안녕하세요", stats.Excerpt)
+	require.Equal(t, 5+5, stats.Words)
+	require.Equal(t, 1, stats.ReadingMinutes)
+
+	long := FromHTML("<p>" + strings.Repeat("word ", 500) + "</p>")
+	require.Equal(t, 500, long.Words)
+	require.Equal(t, 3, long.ReadingMinutes)
+	require.True(t, strings.HasSuffix(long.Excerpt, "word…"))
+	require.LessOrEqual(t, len([]rune(long.Excerpt)), ExcerptLength+1)
+}
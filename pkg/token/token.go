@@ -23,6 +23,10 @@ type UserClaims struct {
 	ExpirationTime time.Time
 	NotBefore      time.Time
 	UserID         int64
+	// ID identifies the token, so it can be revoked before it expires.
+	ID string
+	// SessionID is the session the token was issued for.
+	SessionID int64
 }
 
 func NewUserClaims() *UserClaims {
@@ -33,6 +37,8 @@ func NewUserClaims() *UserClaims {
 		ExpirationTime: time.Time{},
 		NotBefore:      time.Time{},
 		UserID:         0,
+		ID:             "",
+		SessionID:      0,
 	}
 }
 
@@ -40,12 +46,12 @@ func NewWithUserClaims(claims UserClaims) *jwt.Token {
 	return jwt.NewWithClaims(signingMethod, claims)
 }
 
-func ParseWithClaims(tok string, claims *UserClaims) (*jwt.Token, error) {
+func ParseWithClaims(tok string, claims *UserClaims, opts ...jwt.ParserOption) (*jwt.Token, error) {
 	keyFunc := func(t *jwt.Token) (any, error) {
 		// slog.Debug("keyfunc", slog.String("sec", signingSecret))
 		return []byte(signingSecret), nil
 	}
-	t, err := jwt.ParseWithClaims(tok, claims, keyFunc)
+	t, err := jwt.ParseWithClaims(tok, claims, keyFunc, opts...)
 	if err != nil {
 		slog.Error("parsing jwt token", slog.Any("error", err))
 		return nil, err
@@ -6,16 +6,17 @@ import (
 
 	"github.com/gaesemo/blog-server/gen/db/postgres"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // HACK: pgx, sqlc Queries에 매우 의존하고 있다.. 일단 ㄱ
 type Transaction[R any] struct {
-	db      *pgx.Conn
+	db      *pgxpool.Pool
 	queries *postgres.Queries
 	opt     pgx.TxOptions
 }
 
-func New[R any](db *pgx.Conn, opt pgx.TxOptions, queries *postgres.Queries) *Transaction[R] {
+func New[R any](db *pgxpool.Pool, opt pgx.TxOptions, queries *postgres.Queries) *Transaction[R] {
 	return &Transaction[R]{
 		db:      db,
 		opt:     opt,
@@ -0,0 +1,26 @@
+package userloader
+
+import (
+	"context"
+	"slices"
+
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+)
+
+// Load fetches the users with the given IDs in a single query, keyed by ID.
+// IDs may repeat; users that do not exist or were deleted are left out.
+func Load(ctx context.Context, q *postgres.Queries, ids []int64) (map[int64]*postgres.User, error) {
+	users := map[int64]*postgres.User{}
+	ids = slices.Compact(slices.Sorted(slices.Values(ids)))
+	if len(ids) == 0 {
+		return users, nil
+	}
+	rows, err := q.ListUsersByIds(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		users[rows[i].ID] = &rows[i]
+	}
+	return users, nil
+}
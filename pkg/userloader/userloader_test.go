@@ -0,0 +1,47 @@
+package userloader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/pgtest"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	db := pgtest.New(t)
+	q := postgres.New(db)
+	ctx := context.Background()
+	now := pgtype.Timestamptz{Time: time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC), Valid: true}
+
+	var ids []int64
+	for _, name := range []string{"alice", "bob", "carol"} {
+		u, err := q.CreateUser(ctx, postgres.CreateUserParams{
+			IdentityProvider: "IDENTITY_PROVIDER_GITHUB",
+			Email:            name + "@example.com",
+			Username:         name,
+			CreatedAt:        now,
+			UpdatedAt:        now,
+		})
+		require.NoError(t, err)
+		ids = append(ids, u.ID)
+	}
+	alice, bob, carol := ids[0], ids[1], ids[2]
+	_, err := db.Exec(ctx, "UPDATE users SET deleted_at = $1 WHERE id = $2", now, carol)
+	require.NoError(t, err)
+
+	users, err := Load(ctx, q, []int64{bob, alice, bob, carol, carol + 1000})
+	require.NoError(t, err)
+	require.Len(t, users, 2, "deleted and unknown users are left out")
+	require.Equal(t, "alice", users[alice].Username)
+	require.Equal(t, "bob", users[bob].Username)
+}
+
+func TestLoadNoIDs(t *testing.T) {
+	users, err := Load(context.Background(), nil, nil)
+	require.NoError(t, err)
+	require.Empty(t, users)
+}
@@ -0,0 +1,120 @@
+package viewcount
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Buffer counts post views in memory until they are drained and written to
+// the database in one batch. A viewer is counted at most once per post within
+// the dedup window.
+type Buffer struct {
+	window time.Duration
+
+	mu     sync.Mutex
+	seen   map[seenKey]time.Time // when a viewer's view of a post was last counted
+	counts map[int64]int64
+}
+
+type seenKey struct {
+	postID int64
+	viewer string
+}
+
+func NewBuffer(window time.Duration) *Buffer {
+	return &Buffer{
+		window: window,
+		seen:   map[seenKey]time.Time{},
+		counts: map[int64]int64{},
+	}
+}
+
+// UserViewer identifies a signed-in viewer.
+func UserViewer(userID int64) string {
+	return "u:" + strconv.FormatInt(userID, 10)
+}
+
+// Hasher identifies anonymous viewers by IP address and user agent. Both go
+// through an HMAC with a random key that is only kept in memory, so the
+// buffer holds no personal data: unlike a plain hash, the IDs cannot be
+// reversed by hashing every IPv4 address. The key is replaced every period,
+// so IDs cannot be linked across periods either; a viewer may then count
+// once more than the dedup window allows.
+type Hasher struct {
+	period time.Duration
+
+	mu       sync.Mutex
+	key      []byte
+	keyUntil time.Time
+}
+
+func NewHasher(period time.Duration) *Hasher {
+	return &Hasher{period: period}
+}
+
+// AnonymousViewer identifies a viewer by IP address and user agent at now.
+func (h *Hasher) AnonymousViewer(ip, userAgent string, now time.Time) string {
+	mac := hmac.New(sha256.New, h.keyAt(now))
+	mac.Write([]byte(ip + "\x00" + userAgent))
+	return "a:" + hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+func (h *Hasher) keyAt(now time.Time) []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.key == nil || !now.Before(h.keyUntil) {
+		h.key = make([]byte, 32)
+		rand.Read(h.key)
+		h.keyUntil = now.Add(h.period)
+	}
+	return h.key
+}
+
+// Record counts a view of postID by viewer at now, unless the viewer's last
+// counted view of the post is less than the window ago. It reports whether
+// the view was counted.
+func (b *Buffer) Record(postID int64, viewer string, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	k := seenKey{postID: postID, viewer: viewer}
+	if last, ok := b.seen[k]; ok && now.Sub(last) < b.window {
+		return false
+	}
+	b.seen[k] = now
+	b.counts[postID]++
+	return true
+}
+
+// Drain returns the views counted since the last drain, keyed by post ID, and
+// resets them. Viewers whose window has passed by now are forgotten, so the
+// dedup table only grows with recent traffic.
+func (b *Buffer) Drain(now time.Time) map[int64]int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for k, last := range b.seen {
+		if now.Sub(last) >= b.window {
+			delete(b.seen, k)
+		}
+	}
+	counts := b.counts
+	b.counts = map[int64]int64{}
+	return counts
+}
+
+// Add puts drained counts back, e.g. after writing them failed.
+func (b *Buffer) Add(counts map[int64]int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for postID, n := range counts {
+		b.counts[postID] += n
+	}
+}
@@ -0,0 +1,69 @@
+package viewcount
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordDedupsWithinWindow(t *testing.T) {
+	b := NewBuffer(time.Hour)
+	t0 := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	alice := UserViewer(1)
+
+	require.True(t, b.Record(10, alice, t0))
+	require.False(t, b.Record(10, alice, t0.Add(59*time.Minute)))
+	require.True(t, b.Record(11, alice, t0), "other posts count separately")
+	require.True(t, b.Record(10, UserViewer(2), t0), "other viewers count separately")
+	require.True(t, b.Record(10, alice, t0.Add(time.Hour)))
+
+	require.Equal(t, map[int64]int64{10: 3, 11: 1}, b.Drain(t0.Add(time.Hour)))
+	require.Empty(t, b.Drain(t0.Add(time.Hour)))
+}
+
+func TestDrainForgetsExpiredViewers(t *testing.T) {
+	b := NewBuffer(time.Hour)
+	t0 := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	viewer := NewHasher(24*time.Hour).AnonymousViewer("203.0.113.7", "Mozilla/5.0", t0)
+
+	b.Record(10, viewer, t0)
+	b.Drain(t0.Add(30 * time.Minute))
+	require.Len(t, b.seen, 1)
+	b.Drain(t0.Add(time.Hour))
+	require.Empty(t, b.seen)
+}
+
+func TestAddRestoresCounts(t *testing.T) {
+	b := NewBuffer(time.Hour)
+	now := time.Now()
+	b.Record(10, UserViewer(1), now)
+
+	drained := b.Drain(now)
+	b.Record(10, UserViewer(2), now)
+	b.Add(drained)
+
+	require.Equal(t, map[int64]int64{10: 2}, b.Drain(now))
+}
+
+func TestAnonymousViewer(t *testing.T) {
+	h := NewHasher(24 * time.Hour)
+	t0 := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	a := h.AnonymousViewer("203.0.113.7", "Mozilla/5.0", t0)
+	require.Equal(t, a, h.AnonymousViewer("203.0.113.7", "Mozilla/5.0", t0.Add(time.Hour)))
+	require.NotEqual(t, a, h.AnonymousViewer("203.0.113.8", "Mozilla/5.0", t0))
+	require.NotEqual(t, a, h.AnonymousViewer("203.0.113.7", "curl/8.0", t0))
+	require.NotContains(t, a, "203.0.113.7")
+}
+
+func TestAnonymousViewerKeys(t *testing.T) {
+	t0 := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	h := NewHasher(24 * time.Hour)
+	a := h.AnonymousViewer("203.0.113.7", "Mozilla/5.0", t0)
+
+	require.NotEqual(t, a, NewHasher(24*time.Hour).AnonymousViewer("203.0.113.7", "Mozilla/5.0", t0),
+		"IDs depend on a key, not only on the address")
+	require.NotEqual(t, a, h.AnonymousViewer("203.0.113.7", "Mozilla/5.0", t0.Add(24*time.Hour)),
+		"the key is replaced every period")
+}
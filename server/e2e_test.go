@@ -13,7 +13,7 @@ import (
 	typesv1 "github.com/gaesemo/blog-api/go/types/v1"
 	"github.com/gaesemo/blog-server/client"
 	"github.com/gaesemo/blog-server/config"
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
 	tcpg "github.com/testcontainers/testcontainers-go/modules/postgres"
@@ -80,10 +80,11 @@ func TestGetAuthUrl(t *testing.T) {
 	t.Log(resp.Msg.AuthUrl)
 }
 
-func setUp(ctx context.Context) (*pgx.Conn, []cleanUpFunc, error) {
+func setUp(ctx context.Context) (*pgxpool.Pool, []cleanUpFunc, error) {
 	if err := config.Load(); err != nil {
 		return nil, nil, fmt.Errorf("loading config: %v", err)
 	}
+	viper.SetDefault("SITE_URL", "http://localhost:8080")
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -113,20 +114,21 @@ func setUp(ctx context.Context) (*pgx.Conn, []cleanUpFunc, error) {
 		return nil, nil, fmt.Errorf("getting connection string: %v", err)
 	}
 
-	db, err := pgx.Connect(ctx, connStr)
+	db, err := pgxpool.New(ctx, connStr)
 	if err != nil {
 		return nil, nil, fmt.Errorf("connecting db: %v", err)
 	}
 	defer func() {
 		if err != nil {
-			db.Close(ctx)
+			db.Close()
 		}
 	}()
 
 	cleanUpFuncs := []cleanUpFunc{
 		func(c context.Context) error {
 			<-c.Done()
-			return db.Close(c)
+			db.Close()
+			return nil
 		},
 		func(c context.Context) error {
 			<-c.Done()
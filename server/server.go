@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"connectrpc.com/authn"
@@ -14,13 +16,22 @@ import (
 	connectcors "connectrpc.com/cors"
 	"github.com/gaesemo/blog-api/go/service/auth/v1/authv1connect"
 	"github.com/gaesemo/blog-api/go/service/post/v1/postv1connect"
+	"github.com/gaesemo/blog-server/pkg/denylist"
+	"github.com/gaesemo/blog-server/pkg/httpapi"
 	"github.com/gaesemo/blog-server/pkg/middleware"
 	"github.com/gaesemo/blog-server/pkg/oauth"
+	"github.com/gaesemo/blog-server/pkg/ogimage"
+	"github.com/gaesemo/blog-server/pkg/schedule"
 	authsvc "github.com/gaesemo/blog-server/service/auth/v1"
+	commentsvc "github.com/gaesemo/blog-server/service/comment/v1"
+	feedsvc "github.com/gaesemo/blog-server/service/feed/v1"
+	ogsvc "github.com/gaesemo/blog-server/service/og/v1"
 	postsvc "github.com/gaesemo/blog-server/service/post/v1"
+	sitemapsvc "github.com/gaesemo/blog-server/service/sitemap/v1"
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/cors"
+	"github.com/spf13/viper"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -29,11 +40,13 @@ import (
 type Server struct {
 	logger *slog.Logger
 	port   uint16
-	db     *pgx.Conn
+	db     *pgxpool.Pool
 	// objstorage
 }
 
-func New(logger *slog.Logger, port uint16, db *pgx.Conn) *Server {
+const shutdownTimeout = 10 * time.Second
+
+func New(logger *slog.Logger, port uint16, db *pgxpool.Pool) *Server {
 	return &Server{
 		logger: logger,
 		port:   port,
@@ -51,24 +64,97 @@ func (s *Server) Serve(ctx context.Context) error {
 
 	db := s.db
 	httpClient := &http.Client{Timeout: 10 * time.Second}
+	oauthApps := []authsvc.OAuthAppOption{
+		authsvc.WithGitHubOAuthApp(oauth.NewGitHub(httpClient)),
+	}
+	if viper.GetString("OAUTH_GOOGLE_CLIENT_ID") != "" {
+		oauthApps = append(oauthApps, authsvc.WithGoogleOAuthApp(oauth.NewGoogle(httpClient)))
+	}
+	for _, name := range strings.Split(viper.GetString("OIDC_PROVIDERS"), ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		app, err := oauth.NewOIDC(ctx, httpClient, oauth.LoadOIDCConfig(name))
+		if err != nil {
+			return fmt.Errorf("configuring OIDC provider %s: %v", name, err)
+		}
+		oauthApps = append(oauthApps, authsvc.WithOIDCApp(name, app))
+	}
+	tokenDenylist := denylist.New()
 	authService := authsvc.New(
 		slog.Default(),
 		httpClient,
 		db,
 		timeNow,
 		randStr,
-		authsvc.WithGitHubOAuthApp(oauth.NewGitHub(httpClient, randStr)),
+		tokenDenylist,
+		oauthApps...,
 	)
+	// Tokens revoked before a restart must stay refused.
+	if err := authService.SyncDenylist(ctx); err != nil {
+		return fmt.Errorf("loading token denylist: %v", err)
+	}
+	adminIDs, err := parseUserIDs(viper.GetString("ADMIN_USER_IDS"))
+	if err != nil {
+		return fmt.Errorf("reading ADMIN_USER_IDS: %v", err)
+	}
+	commentService := commentsvc.New(
+		slog.Default(),
+		db,
+		timeNow,
+		commentsvc.WithAdmins(adminIDs...),
+	)
+	// Feeds, sitemaps and previews are cached publicly, so their links
+	// must not depend on the Host header of whoever asked first.
+	site, err := httpapi.NewSite(viper.GetString("SITE_URL"), viper.GetString("API_URL"), viper.GetString("SITE_TITLE"))
+	if err != nil {
+		return fmt.Errorf("reading SITE_URL: %v", err)
+	}
+	sitemapOpts := []sitemapsvc.Option{}
+	if path := viper.GetString("ROBOTS_TXT_FILE"); path != "" {
+		rules, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading ROBOTS_TXT_FILE: %v", err)
+		}
+		sitemapOpts = append(sitemapOpts, sitemapsvc.WithRobots(string(rules)))
+	}
+	sitemapService := sitemapsvc.New(slog.Default(), db, timeNow, site, sitemapOpts...)
 	postService := postsvc.New(
 		slog.Default(),
 		db,
 		timeNow,
+		postsvc.WithTrashRetention(viper.GetDuration("POST_TRASH_RETENTION")),
+		postsvc.WithViewWindow(viper.GetDuration("POST_VIEW_WINDOW")),
+		postsvc.WithRelatedPosts(viper.GetInt("POST_RELATED_LIMIT")),
+		postsvc.WithChangeHook(sitemapService.Invalidate),
+	)
+	feedOpts := []feedsvc.Option{}
+	if viper.GetBool("FEED_SUMMARY_ONLY") {
+		feedOpts = append(feedOpts, feedsvc.WithSummaryOnly())
+	}
+	feedService := feedsvc.New(slog.Default(), db, site, feedOpts...)
+	var ogFont []byte
+	if path := viper.GetString("OG_FONT_FILE"); path != "" {
+		if ogFont, err = os.ReadFile(path); err != nil {
+			return fmt.Errorf("reading OG_FONT_FILE: %v", err)
+		}
+	}
+	ogRenderer, err := ogimage.NewRenderer(ogFont)
+	if err != nil {
+		return fmt.Errorf("loading OG_FONT_FILE: %v", err)
+	}
+	ogService := ogsvc.New(
+		slog.Default(),
+		httpClient,
+		db,
+		ogRenderer,
+		site,
 	)
 
 	mux := http.NewServeMux()
 
 	authorizer := authn.NewMiddleware(
-		middleware.Authorize,
+		middleware.Authorize(tokenDenylist),
 	)
 	{
 		path, svcHandler := authv1connect.NewAuthServiceHandler(
@@ -76,6 +162,12 @@ func (s *Server) Serve(ctx context.Context) error {
 			connect.WithInterceptors(middleware.UnaryLogger()),
 		) // TOOD: add request id interceptor, add logging interceptor,
 		mux.Handle(path, svcHandler)
+		// Signing in, refreshing and logging out happen without a valid access token, so
+		// only the session endpoints go through the authorizer.
+		mux.Handle("/api/auth/", authService)
+		sessionHandler := authorizer.Wrap(authService)
+		mux.Handle("/api/auth/sessions", sessionHandler)
+		mux.Handle("/api/auth/sessions/", sessionHandler)
 	}
 	{
 		path, svcHandler := postv1connect.NewPostServiceHandler(
@@ -84,9 +176,41 @@ func (s *Server) Serve(ctx context.Context) error {
 		)
 		svcHandler = authorizer.Wrap(svcHandler)
 		mux.Handle(path, svcHandler)
+		postHTTPHandler := authorizer.Wrap(postService)
+		mux.Handle("/api/posts", postHTTPHandler)
+		mux.Handle("/api/posts/", postHTTPHandler)
+		mux.Handle("/api/tags", postHTTPHandler)
+		mux.Handle("/api/slugs/", postHTTPHandler)
+		mux.Handle("/api/series", postHTTPHandler)
+		mux.Handle("/api/series/", postHTTPHandler)
+	}
+	{
+		commentHandler := authorizer.Wrap(commentService)
+		mux.Handle("/api/posts/{id}/comments", commentHandler)
+		mux.Handle("/api/comments/", commentHandler)
+	}
+	{
+		// Feeds are public, so they are served without the authorizer.
+		mux.Handle("/feed.xml", feedService)
+		mux.Handle("/atom.xml", feedService)
+		mux.Handle("/feed.json", feedService)
+		mux.Handle("/authors/", feedService)
+		mux.Handle("/tags/", feedService)
+	}
+	{
+		mux.Handle("/robots.txt", sitemapService)
+		mux.Handle("/sitemap.xml", sitemapService)
+		mux.Handle("/sitemaps/", sitemapService)
+	}
+	{
+		mux.Handle("/og/", ogService)
 	}
 
-	handler := withCORS(mux)
+	trustedProxies, err := middleware.ParsePrefixes(viper.GetString("TRUSTED_PROXIES"))
+	if err != nil {
+		return fmt.Errorf("reading TRUSTED_PROXIES: %v", err)
+	}
+	handler := withCORS(middleware.RealIP(trustedProxies)(mux))
 
 	addr := ":" + strconv.FormatUint(uint64(s.port), 10)
 	server := &http.Server{
@@ -95,9 +219,16 @@ func (s *Server) Serve(ctx context.Context) error {
 	}
 
 	eg, ctx := errgroup.WithContext(ctx)
+	// stopped is closed once the server has finished the requests in flight.
+	stopped := make(chan struct{})
 	onShutdown := func() error {
 		<-ctx.Done()
-		err := server.Shutdown(ctx)
+		defer close(stopped)
+		// ctx is already done here; give in-flight requests a moment of
+		// their own.
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		defer cancel()
+		err := server.Shutdown(shutdownCtx)
 		if err != nil {
 			return fmt.Errorf("shutting down http server: %v", err)
 		}
@@ -115,6 +246,53 @@ func (s *Server) Serve(ctx context.Context) error {
 	}
 	eg.Go(serve)
 
+	purgeTrash := func() error {
+		return schedule.Every(ctx, "purge trash", time.Hour, postService.PurgeTrash)
+	}
+	eg.Go(purgeTrash)
+
+	publishScheduled := func() error {
+		return schedule.Every(ctx, "publish scheduled posts", time.Minute, postService.PublishDuePosts)
+	}
+	eg.Go(publishScheduled)
+
+	flushViews := func() error {
+		if err := schedule.Every(ctx, "flush post views", 10*time.Second, postService.FlushViews); err != nil {
+			return err
+		}
+		// Views recorded by the last requests are only in memory. They are
+		// flushed here, after the job above has stopped, so the two flushes
+		// never overlap.
+		<-stopped
+		flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		defer cancel()
+		if err := postService.FlushViews(flushCtx); err != nil {
+			return fmt.Errorf("flushing post views: %v", err)
+		}
+		return nil
+	}
+	eg.Go(flushViews)
+
+	refreshRelated := func() error {
+		return schedule.Every(ctx, "refresh related posts", 5*time.Minute, postService.RefreshRelatedPosts)
+	}
+	eg.Go(refreshRelated)
+
+	refreshRankings := func() error {
+		return schedule.Every(ctx, "refresh post rankings", 10*time.Minute, postService.RefreshRankings)
+	}
+	eg.Go(refreshRankings)
+
+	purgeSessions := func() error {
+		return schedule.Every(ctx, "purge sessions", time.Hour, authService.PurgeSessions)
+	}
+	eg.Go(purgeSessions)
+
+	syncDenylist := func() error {
+		return schedule.Every(ctx, "sync token denylist", 30*time.Second, authService.SyncDenylist)
+	}
+	eg.Go(syncDenylist)
+
 	if err := eg.Wait(); err != nil {
 		return fmt.Errorf("server stopped: %v", err)
 	}
@@ -123,15 +301,37 @@ func (s *Server) Serve(ctx context.Context) error {
 
 func withCORS(h http.Handler) http.Handler {
 	allowedHeaders := connectcors.AllowedHeaders()
-	allowedHeaders = append(allowedHeaders, "Credentials")
+	allowedHeaders = append(allowedHeaders, "Credentials", "Sort", "OAuth-State", "If-Match")
+	// Post edits are checked against the ETag of the version the client
+	// edited, so the browser has to let scripts read it.
+	exposedHeaders := append(connectcors.ExposedHeaders(), "ETag")
+	// The plain HTTP endpoints next to the RPCs are REST-style.
+	allowedMethods := append(connectcors.AllowedMethods(), http.MethodPut, http.MethodPatch, http.MethodDelete)
 	middlewares := cors.New(cors.Options{
 		AllowedOrigins:       []string{"http://localhost:3000"},
-		AllowedMethods:       connectcors.AllowedMethods(),
+		AllowedMethods:       allowedMethods,
 		AllowedHeaders:       allowedHeaders,
 		AllowCredentials:     true,
-		ExposedHeaders:       connectcors.ExposedHeaders(),
+		ExposedHeaders:       exposedHeaders,
 		Debug:                true,
 		OptionsSuccessStatus: http.StatusOK,
 	})
 	return middlewares.Handler(h)
 }
+
+// parseUserIDs reads a comma-separated list of user IDs.
+func parseUserIDs(s string) ([]int64, error) {
+	var ids []int64
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(f, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user id %q", f)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,232 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/httpapi"
+	"github.com/gaesemo/blog-server/pkg/oauth"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// routes registers the HTTP endpoints for signing in with the generic OpenID
+// Connect providers and for sessions.
+func (svc *service) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/auth/oidc", svc.listOIDCProviders)
+	mux.HandleFunc("GET /api/auth/oidc/{name}/url", svc.getOIDCAuthURL)
+	mux.HandleFunc("POST /api/auth/oidc/{name}/login", svc.oidcLogin)
+	mux.HandleFunc("POST /api/auth/refresh", svc.refreshToken)
+	mux.HandleFunc("POST /api/auth/logout", svc.logout)
+	mux.HandleFunc("GET /api/auth/sessions", svc.listSessions)
+	mux.HandleFunc("DELETE /api/auth/sessions/{id}", svc.deleteSession)
+	return mux
+}
+
+// ServeHTTP implements http.Handler.
+func (svc *service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	svc.mux.ServeHTTP(w, r)
+}
+
+// listOIDCProviders lists the names of the configured OpenID Connect
+// providers, for the sign-in page to offer.
+//
+//	GET /api/auth/oidc
+func (svc *service) listOIDCProviders(w http.ResponseWriter, r *http.Request) {
+	names := []string{}
+	for key := range svc.oauthApps {
+		if name, ok := strings.CutPrefix(key, oidcPrefix); ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	svc.respond.JSON(w, r, struct {
+		Providers []string `json:"providers"`
+	}{Providers: names})
+}
+
+// getOIDCAuthURL is GetAuthURL for an OpenID Connect provider.
+//
+//	GET /api/auth/oidc/{name}/url
+func (svc *service) getOIDCAuthURL(w http.ResponseWriter, r *http.Request) {
+	oauthApp, err := svc.getOIDCApp(r.PathValue("name"))
+	if err != nil {
+		svc.respond.Error(w, r, err)
+		return
+	}
+	authURL, cookie, err := svc.beginSignIn(r.Context(), oauthApp, oidcPrefix+r.PathValue("name"))
+	if err != nil {
+		svc.respond.Error(w, r, err)
+		return
+	}
+	http.SetCookie(w, cookie)
+	svc.respond.JSON(w, r, struct {
+		AuthURL string `json:"auth_url"`
+	}{AuthURL: authURL})
+}
+
+// oidcLogin is Login for an OpenID Connect provider. The state goes in the
+// body rather than a header.
+//
+//	POST /api/auth/oidc/{name}/login
+//	{"code": "...", "state": "..."}
+func (svc *service) oidcLogin(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	oauthApp, err := svc.getOIDCApp(name)
+	if err != nil {
+		svc.respond.Error(w, r, err)
+		return
+	}
+	var req struct {
+		Code  string `json:"code"`
+		State string `json:"state"`
+	}
+	if err := httpapi.ReadJSON(r, &req); err != nil {
+		svc.respond.Error(w, r, err)
+		return
+	}
+	if req.Code == "" {
+		svc.respond.Error(w, r, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("code required")))
+		return
+	}
+
+	result, err := svc.signIn(r.Context(), r.URL.Path, oauthApp, oidcPrefix+name, signInCallback{
+		code:    req.Code,
+		state:   req.State,
+		binding: stateBinding(r.Header),
+		client:  newClient(r.UserAgent(), r.RemoteAddr),
+	})
+	if err != nil {
+		svc.respond.Error(w, r, err)
+		return
+	}
+	http.SetCookie(w, result.cookie)
+	http.SetCookie(w, result.refreshCookie)
+	http.SetCookie(w, clearStateCookie())
+	svc.respond.JSON(w, r, struct {
+		Token     string `json:"token"`
+		IsNewUser bool   `json:"is_new_user"`
+	}{
+		Token:     result.token,
+		IsNewUser: result.isNewUser,
+	})
+}
+
+// refreshToken trades the refresh cookie for a new access token, and the
+// cookie for a new one. It fails with unauthenticated once the session is
+// over, and with aborted if another request just rotated the cookie, in
+// which case the request can be retried with the cookie that one set.
+//
+//	POST /api/auth/refresh
+func (svc *service) refreshToken(w http.ResponseWriter, r *http.Request) {
+	var refreshToken string
+	if c, err := r.Cookie(refreshCookie); err == nil {
+		refreshToken = c.Value
+	}
+	result, err := svc.refresh(r.Context(), refreshToken, newClient(r.UserAgent(), r.RemoteAddr))
+	if err != nil {
+		svc.respond.Error(w, r, err)
+		return
+	}
+	http.SetCookie(w, result.cookie)
+	http.SetCookie(w, result.refreshCookie)
+	svc.respond.JSON(w, r, struct {
+		Token string `json:"token"`
+	}{Token: result.token})
+}
+
+// logout is Logout for browsers. Being under the path of the refresh
+// cookie, it is sent that cookie, so it ends the session even when the
+// access token has expired or is missing.
+//
+//	POST /api/auth/logout
+func (svc *service) logout(w http.ResponseWriter, r *http.Request) {
+	if err := svc.endSessions(r.Context(), r.Header); err != nil {
+		svc.respond.Error(w, r, err)
+		return
+	}
+	http.SetCookie(w, clearCookie("token", "/"))
+	http.SetCookie(w, clearCookie(refreshCookie, refreshPath))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type sessionJSON struct {
+	ID         int64     `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Current marks the session of the browser asking.
+	Current bool `json:"current"`
+}
+
+// listSessions lists the active sessions of the signed-in user, most
+// recently used first.
+//
+//	GET /api/auth/sessions
+func (svc *service) listSessions(w http.ResponseWriter, r *http.Request) {
+	uid, err := httpapi.RequireUserID(r)
+	if err != nil {
+		svc.respond.Error(w, r, err)
+		return
+	}
+	sessions, err := svc.queries.ListActiveSessions(r.Context(), postgres.ListActiveSessionsParams{
+		UserID: uid,
+		Now:    pgtype.Timestamptz{Time: svc.timeNow(), Valid: true},
+	})
+	if err != nil {
+		svc.respond.Error(w, r, connect.NewError(connect.CodeInternal, fmt.Errorf("listing sessions: %v", err)))
+		return
+	}
+	current := svc.currentSessionID(r.Context(), r.Header)
+	out := make([]sessionJSON, 0, len(sessions))
+	for _, s := range sessions {
+		out = append(out, sessionJSON{
+			ID:         s.ID,
+			UserAgent:  s.UserAgent,
+			IPAddress:  s.IpAddress,
+			CreatedAt:  s.CreatedAt.Time,
+			LastUsedAt: s.LastUsedAt.Time,
+			ExpiresAt:  s.ExpiresAt.Time,
+			Current:    s.ID == current,
+		})
+	}
+	svc.respond.JSON(w, r, struct {
+		Sessions []sessionJSON `json:"sessions"`
+	}{Sessions: out})
+}
+
+// deleteSession signs the device of a session of the signed-in user out.
+//
+//	DELETE /api/auth/sessions/{id}
+func (svc *service) deleteSession(w http.ResponseWriter, r *http.Request) {
+	uid, err := httpapi.RequireUserID(r)
+	if err != nil {
+		svc.respond.Error(w, r, err)
+		return
+	}
+	id, err := httpapi.PathInt64(r, "id")
+	if err != nil {
+		svc.respond.Error(w, r, err)
+		return
+	}
+	if err := svc.revokeSession(r.Context(), uid, id); err != nil {
+		svc.respond.Error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (svc *service) getOIDCApp(name string) (oauth.App, error) {
+	oa, exist := svc.oauthApps[oidcPrefix+name]
+	if !exist {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("unsupported identity provider: %s", name))
+	}
+	return oa, nil
+}
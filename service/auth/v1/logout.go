@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"connectrpc.com/connect"
+	authv1 "github.com/gaesemo/blog-api/go/service/auth/v1"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/token"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Logout implements authv1connect.AuthServiceHandler.
+//
+// The access token in the token cookie is revoked until it expires, and the
+// session it was issued for ends, so neither a copy of the token nor of the
+// refresh token stays usable. Both cookies are cleared. Logging out without
+// a token, or with one that is no longer valid, only clears the cookies.
+//
+// Browsers only send the refresh cookie to /api/auth/, so they should log
+// out with POST /api/auth/logout, which ends the session even once the
+// access token has expired.
+func (svc *service) Logout(ctx context.Context, req *connect.Request[authv1.LogoutRequest]) (*connect.Response[authv1.LogoutResponse], error) {
+	if err := svc.endSessions(ctx, req.Header()); err != nil {
+		return nil, err
+	}
+
+	resp := connect.NewResponse(&authv1.LogoutResponse{})
+	resp.Header().Add("Set-Cookie", clearCookie("token", "/").String())
+	resp.Header().Add("Set-Cookie", clearCookie(refreshCookie, refreshPath).String())
+	return resp, nil
+}
+
+// endSessions revokes the access token among the cookies in h and ends the
+// sessions of both it and the refresh token, which may be sent without the
+// other.
+func (svc *service) endSessions(ctx context.Context, h http.Header) error {
+	if claims := accessClaims(h); claims != nil {
+		if err := svc.revokeAccessToken(ctx, claims); err != nil {
+			return err
+		}
+		if claims.SessionID != 0 {
+			if err := svc.endSession(ctx, claims.UserID, claims.SessionID); err != nil {
+				return err
+			}
+		}
+	}
+	if id := svc.currentSessionID(ctx, h); id != 0 {
+		s, err := svc.queries.GetSession(ctx, id)
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving session: %v", err))
+		}
+		if err := svc.endSession(ctx, s.UserID, s.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// endSession revokes session id of userID, if it is still going.
+func (svc *service) endSession(ctx context.Context, userID, id int64) error {
+	_, err := svc.queries.RevokeSession(ctx, postgres.RevokeSessionParams{
+		RevokedAt: pgtype.Timestamptz{Time: svc.timeNow(), Valid: true},
+		ID:        id,
+		UserID:    userID,
+	})
+	if err != nil {
+		return connect.NewError(connect.CodeInternal, fmt.Errorf("revoking session: %v", err))
+	}
+	return nil
+}
+
+// accessClaims reads the claims of the access token in the token cookie
+// among h. Expired tokens are read too, since their session may still be
+// going; tokens with a bad signature are not.
+func accessClaims(h http.Header) *token.UserClaims {
+	c, err := (&http.Request{Header: h}).Cookie("token")
+	if err != nil {
+		return nil
+	}
+	claims := token.NewUserClaims()
+	if _, err := token.ParseWithClaims(c.Value, claims, jwt.WithoutClaimsValidation()); err != nil {
+		return nil
+	}
+	return claims
+}
+
+// revokeAccessToken adds the token of claims to the denylist until it
+// expires. Tokens issued before they had IDs cannot be revoked.
+func (svc *service) revokeAccessToken(ctx context.Context, claims *token.UserClaims) error {
+	if claims.ID == "" || !claims.ExpirationTime.After(svc.timeNow()) {
+		return nil
+	}
+	err := svc.queries.RevokeToken(ctx, postgres.RevokeTokenParams{
+		TokenID:   claims.ID,
+		ExpiresAt: pgtype.Timestamptz{Time: claims.ExpirationTime, Valid: true},
+	})
+	if err != nil {
+		return connect.NewError(connect.CodeInternal, fmt.Errorf("revoking token: %v", err))
+	}
+	svc.denylist.Add(claims.ID, claims.ExpirationTime)
+	return nil
+}
+
+// SyncDenylist implements Service. Rows of tokens that have expired are
+// deleted along the way.
+func (svc *service) SyncDenylist(ctx context.Context) error {
+	now := pgtype.Timestamptz{Time: svc.timeNow(), Valid: true}
+	if err := svc.queries.DeleteExpiredRevokedTokens(ctx, now); err != nil {
+		return fmt.Errorf("deleting expired revoked tokens: %v", err)
+	}
+	revoked, err := svc.queries.ListRevokedTokens(ctx, now)
+	if err != nil {
+		return fmt.Errorf("listing revoked tokens: %v", err)
+	}
+	for _, t := range revoked {
+		svc.denylist.Add(t.TokenID, t.ExpiresAt.Time)
+	}
+	svc.denylist.Prune(now.Time)
+	svc.logger.DebugContext(ctx, "synced token denylist", slog.Int("count", svc.denylist.Len()))
+	return nil
+}
+
+func clearCookie(name, path string) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    "",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Path:     path,
+		Secure:   false,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
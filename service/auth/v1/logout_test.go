@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	authv1 "github.com/gaesemo/blog-api/go/service/auth/v1"
+	"github.com/gaesemo/blog-server/pkg/token"
+	"github.com/stretchr/testify/require"
+)
+
+// expiredAccessCookie is the token cookie of an access token for sessionID
+// that expired an hour ago.
+func expiredAccessCookie(t *testing.T, s *service, userID, sessionID int64) *http.Cookie {
+	t.Helper()
+	issued := time.Now().Add(-2 * time.Hour)
+	signed, err := token.NewWithUserClaims(token.UserClaims{
+		Audience:       []string{},
+		Issuer:         "gsm",
+		IssuedAt:       issued,
+		ExpirationTime: issued.Add(time.Hour),
+		NotBefore:      issued,
+		UserID:         userID,
+		ID:             s.randStr(),
+		SessionID:      sessionID,
+	}).SignedString([]byte(os.Getenv("JWT_SIGNING_SECRET")))
+	require.NoError(t, err)
+	return &http.Cookie{Name: "token", Value: signed}
+}
+
+func TestLogoutWithoutAccessTokenRevokesSession(t *testing.T) {
+	for name, accessCookie := range map[string]func(t *testing.T, s *service, userID, sessionID int64) *http.Cookie{
+		"missing": nil,
+		"expired": expiredAccessCookie,
+	} {
+		t.Run(name, func(t *testing.T) {
+			s, _ := newTestService(t)
+			uid := createUser(t, s, "user")
+			id, refreshToken := startTestSession(t, s, uid)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/logout", nil)
+			req.AddCookie(newRefreshCookie(refreshToken, s.timeNow()))
+			if accessCookie != nil {
+				req.AddCookie(accessCookie(t, s, uid, id))
+			}
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+			cleared := map[string]bool{}
+			for _, c := range rec.Result().Cookies() {
+				cleared[c.Name] = c.MaxAge < 0
+			}
+			require.Equal(t, map[string]bool{"token": true, refreshCookie: true}, cleared)
+
+			session, err := s.queries.GetSession(context.Background(), id)
+			require.NoError(t, err)
+			require.True(t, session.RevokedAt.Valid)
+			_, err = s.refresh(context.Background(), refreshToken, newClient("test", "192.0.2.1:1234"))
+			require.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+		})
+	}
+}
+
+func TestLogoutRevokesAccessToken(t *testing.T) {
+	s, _ := newTestService(t)
+	uid := createUser(t, s, "user")
+	id, refreshToken := startTestSession(t, s, uid)
+	_, cookie, err := s.issueAccessToken(uid, id)
+	require.NoError(t, err)
+	claims := accessClaims(withCookie(cookie))
+	require.NotNil(t, claims)
+
+	req := connect.NewRequest(&authv1.LogoutRequest{})
+	req.Header().Add("Cookie", cookie.String())
+	_, err = s.Logout(context.Background(), req)
+	require.NoError(t, err)
+
+	require.True(t, s.denylist.Contains(claims.ID, time.Now()))
+	_, err = s.refresh(context.Background(), refreshToken, newClient("test", "192.0.2.1:1234"))
+	require.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+}
@@ -0,0 +1,248 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/transaction"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// sessionLifetime is how long a session lasts without being refreshed.
+	// Each refresh extends it.
+	sessionLifetime = 30 * 24 * time.Hour
+	// refreshCookie holds the refresh token. It is only sent to the auth
+	// endpoints, so it leaks less than the access token.
+	refreshCookie = "refresh_token"
+	refreshPath   = "/api/auth/"
+	// rotationGrace is how long a rotated refresh token is turned away
+	// without being treated as reused, so that tabs refreshing at the same
+	// time do not revoke their own session.
+	rotationGrace = 10 * time.Second
+)
+
+// client describes the device a session was started or last refreshed from.
+type client struct {
+	userAgent string
+	ip        string
+}
+
+// newClient describes the device of a request from remoteAddr. Behind a load
+// balancer, remoteAddr is only the client's once middleware.RealIP has set
+// it from X-Forwarded-For; otherwise the proxy is recorded.
+func newClient(userAgent, remoteAddr string) client {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return client{userAgent: userAgent, ip: host}
+}
+
+// startSession starts a session for userID on c, using q so it can take part
+// in the sign-in transaction, and returns its ID and first refresh token.
+func (svc *service) startSession(ctx context.Context, q *postgres.Queries, userID int64, c client) (int64, string, error) {
+	now := svc.timeNow()
+	s, err := q.CreateSession(ctx, postgres.CreateSessionParams{
+		UserID:     userID,
+		UserAgent:  c.userAgent,
+		IpAddress:  c.ip,
+		CreatedAt:  pgtype.Timestamptz{Time: now, Valid: true},
+		LastUsedAt: pgtype.Timestamptz{Time: now, Valid: true},
+		ExpiresAt:  pgtype.Timestamptz{Time: now.Add(sessionLifetime), Valid: true},
+	})
+	if err != nil {
+		return 0, "", fmt.Errorf("creating session: %v", err)
+	}
+	refreshToken := rand.Text()
+	err = q.CreateSessionToken(ctx, postgres.CreateSessionTokenParams{
+		TokenHash: hashSecret(refreshToken),
+		SessionID: s.ID,
+		CreatedAt: pgtype.Timestamptz{Time: now, Valid: true},
+	})
+	if err != nil {
+		return 0, "", fmt.Errorf("creating refresh token: %v", err)
+	}
+	return s.ID, refreshToken, nil
+}
+
+type rotation struct {
+	userID       int64
+	refreshToken string
+	// reused is set when a refresh token that was already rotated came
+	// back, so its session was revoked instead.
+	reused    bool
+	sessionID int64
+}
+
+// refresh trades refreshToken for a new one and an access token. Each refresh
+// token can be used once: presenting one again means it was copied, and ends
+// its session for whoever holds the latest token too.
+func (svc *service) refresh(ctx context.Context, refreshToken string, c client) (*signInResult, error) {
+	if refreshToken == "" {
+		return nil, connect.NewError(connect.CodeUnauthenticated, fmt.Errorf("refresh token required"))
+	}
+	now := svc.timeNow()
+
+	tx := transaction.New[rotation](
+		svc.db,
+		pgx.TxOptions{IsoLevel: pgx.ReadCommitted, AccessMode: pgx.ReadWrite},
+		svc.queries,
+	)
+	result, err := tx.Exec(ctx, func(ctx context.Context, q *postgres.Queries) (*rotation, error) {
+		// Locking the token makes concurrent refreshes with it take turns,
+		// so only the first one rotates it.
+		t, err := q.GetSessionTokenForUpdate(ctx, hashSecret(refreshToken))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, connect.NewError(connect.CodeUnauthenticated, fmt.Errorf("invalid refresh token"))
+		}
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving refresh token: %v", err))
+		}
+		s, err := q.GetSession(ctx, t.SessionID)
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving session: %v", err))
+		}
+		if !s.ExpiresAt.Time.After(now) {
+			return nil, connect.NewError(connect.CodeUnauthenticated, fmt.Errorf("session ended"))
+		}
+
+		// A rotated token coming back is reuse even when its session has
+		// been revoked since, by the reuse of another token or by signing
+		// out; revoking again changes nothing then.
+		if t.RotatedAt.Valid && now.Sub(t.RotatedAt.Time) >= rotationGrace {
+			_, err := q.RevokeSession(ctx, postgres.RevokeSessionParams{
+				RevokedAt: pgtype.Timestamptz{Time: now, Valid: true},
+				ID:        s.ID,
+				UserID:    s.UserID,
+			})
+			if err != nil {
+				return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("revoking session: %v", err))
+			}
+			return &rotation{userID: s.UserID, reused: true, sessionID: s.ID}, nil
+		}
+		if s.RevokedAt.Valid {
+			return nil, connect.NewError(connect.CodeUnauthenticated, fmt.Errorf("session ended"))
+		}
+		if t.RotatedAt.Valid {
+			return nil, connect.NewError(connect.CodeAborted, fmt.Errorf("refresh token was just rotated"))
+		}
+
+		err = q.RotateSessionToken(ctx, postgres.RotateSessionTokenParams{
+			RotatedAt: pgtype.Timestamptz{Time: now, Valid: true},
+			TokenHash: t.TokenHash,
+		})
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("rotating refresh token: %v", err))
+		}
+		next := rand.Text()
+		err = q.CreateSessionToken(ctx, postgres.CreateSessionTokenParams{
+			TokenHash: hashSecret(next),
+			SessionID: s.ID,
+			CreatedAt: pgtype.Timestamptz{Time: now, Valid: true},
+		})
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("creating refresh token: %v", err))
+		}
+		err = q.TouchSession(ctx, postgres.TouchSessionParams{
+			LastUsedAt: pgtype.Timestamptz{Time: now, Valid: true},
+			ExpiresAt:  pgtype.Timestamptz{Time: now.Add(sessionLifetime), Valid: true},
+			UserAgent:  c.userAgent,
+			IpAddress:  c.ip,
+			ID:         s.ID,
+		})
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("updating session: %v", err))
+		}
+		return &rotation{userID: s.UserID, refreshToken: next, sessionID: s.ID}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.reused {
+		svc.logger.WarnContext(ctx, "refresh token reused; session revoked",
+			slog.Int64("user_id", result.userID),
+			slog.Int64("session_id", result.sessionID),
+			slog.String("ip", c.ip),
+		)
+		return nil, connect.NewError(connect.CodeUnauthenticated, fmt.Errorf("refresh token reused; session revoked"))
+	}
+
+	accessToken, cookie, err := svc.issueAccessToken(result.userID, result.sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &signInResult{
+		token:         accessToken,
+		cookie:        cookie,
+		refreshCookie: newRefreshCookie(result.refreshToken, now),
+	}, nil
+}
+
+// revokeSession ends session id of userID. Its refresh tokens stop working
+// at once; access tokens issued for it run out within the hour.
+func (svc *service) revokeSession(ctx context.Context, userID, id int64) error {
+	n, err := svc.queries.RevokeSession(ctx, postgres.RevokeSessionParams{
+		RevokedAt: pgtype.Timestamptz{Time: svc.timeNow(), Valid: true},
+		ID:        id,
+		UserID:    userID,
+	})
+	if err != nil {
+		return connect.NewError(connect.CodeInternal, fmt.Errorf("revoking session: %v", err))
+	}
+	if n == 0 {
+		return connect.NewError(connect.CodeNotFound, fmt.Errorf("session %d not found", id))
+	}
+	return nil
+}
+
+// currentSessionID returns the session the refresh cookie among h belongs
+// to, or 0 if there is none.
+func (svc *service) currentSessionID(ctx context.Context, h http.Header) int64 {
+	c, err := (&http.Request{Header: h}).Cookie(refreshCookie)
+	if err != nil {
+		return 0
+	}
+	t, err := svc.queries.GetSessionToken(ctx, hashSecret(c.Value))
+	if err != nil {
+		return 0
+	}
+	return t.SessionID
+}
+
+// PurgeSessions implements Service. The refresh tokens of purged sessions go
+// with them. A revoked session is only purged once it has expired, as its
+// refresh tokens would have by then, so that presenting a rotated one until
+// then is still caught as reuse.
+func (svc *service) PurgeSessions(ctx context.Context) error {
+	n, err := svc.queries.PurgeSessions(ctx, pgtype.Timestamptz{Time: svc.timeNow(), Valid: true})
+	if err != nil {
+		return fmt.Errorf("purging sessions: %v", err)
+	}
+	if n > 0 {
+		svc.logger.InfoContext(ctx, "purged sessions", slog.Int64("count", n))
+	}
+	return nil
+}
+
+func newRefreshCookie(refreshToken string, now time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     refreshCookie,
+		Value:    refreshToken,
+		Expires:  now.Add(sessionLifetime),
+		MaxAge:   int(sessionLifetime.Seconds()),
+		HttpOnly: true,
+		Path:     refreshPath,
+		Secure:   false,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
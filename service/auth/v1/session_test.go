@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshRotatesToken(t *testing.T) {
+	s, clock := newTestService(t)
+	uid := createUser(t, s, "user")
+	id, first := startTestSession(t, s, uid)
+
+	clock.now = clock.now.Add(time.Minute)
+	result, err := s.refresh(context.Background(), first, newClient("other agent", "198.51.100.7:4321"))
+	require.NoError(t, err)
+	require.NotEmpty(t, result.token)
+	second := result.refreshCookie.Value
+	require.NotEqual(t, first, second)
+	require.Equal(t, refreshPath, result.refreshCookie.Path)
+
+	session, err := s.queries.GetSession(context.Background(), id)
+	require.NoError(t, err)
+	require.Equal(t, "other agent", session.UserAgent)
+	require.Equal(t, "198.51.100.7", session.IpAddress)
+	require.True(t, clock.now.Add(sessionLifetime).Equal(session.ExpiresAt.Time), "refreshing extends the session")
+
+	clock.now = clock.now.Add(time.Minute)
+	result, err = s.refresh(context.Background(), second, newClient("test", "192.0.2.1:1234"))
+	require.NoError(t, err)
+	require.Equal(t, id, s.currentSessionID(context.Background(), withCookie(result.refreshCookie)))
+}
+
+func TestRefreshWithinRotationGrace(t *testing.T) {
+	s, clock := newTestService(t)
+	uid := createUser(t, s, "user")
+	_, first := startTestSession(t, s, uid)
+
+	result, err := s.refresh(context.Background(), first, newClient("test", "192.0.2.1:1234"))
+	require.NoError(t, err)
+
+	clock.now = clock.now.Add(rotationGrace - time.Second)
+	_, err = s.refresh(context.Background(), first, newClient("test", "192.0.2.1:1234"))
+	require.Equal(t, connect.CodeAborted, connect.CodeOf(err))
+
+	// The tab that lost the race retries with the cookie the winner got.
+	_, err = s.refresh(context.Background(), result.refreshCookie.Value, newClient("test", "192.0.2.1:1234"))
+	require.NoError(t, err, "a refresh within the grace window must not end the session")
+}
+
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	s, clock := newTestService(t)
+	uid := createUser(t, s, "user")
+	id, first := startTestSession(t, s, uid)
+
+	result, err := s.refresh(context.Background(), first, newClient("test", "192.0.2.1:1234"))
+	require.NoError(t, err)
+	latest := result.refreshCookie.Value
+
+	clock.now = clock.now.Add(rotationGrace)
+	_, err = s.refresh(context.Background(), first, newClient("attacker", "203.0.113.9:1"))
+	require.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+
+	session, err := s.queries.GetSession(context.Background(), id)
+	require.NoError(t, err)
+	require.True(t, session.RevokedAt.Valid)
+
+	_, err = s.refresh(context.Background(), latest, newClient("test", "192.0.2.1:1234"))
+	require.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err), "the latest token of the family must stop working too")
+}
+
+func TestRefreshEndedSession(t *testing.T) {
+	t.Run("expired", func(t *testing.T) {
+		s, clock := newTestService(t)
+		uid := createUser(t, s, "user")
+		_, refreshToken := startTestSession(t, s, uid)
+
+		clock.now = clock.now.Add(sessionLifetime)
+		_, err := s.refresh(context.Background(), refreshToken, newClient("test", "192.0.2.1:1234"))
+		require.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+	})
+	t.Run("revoked", func(t *testing.T) {
+		s, _ := newTestService(t)
+		uid := createUser(t, s, "user")
+		id, refreshToken := startTestSession(t, s, uid)
+
+		require.NoError(t, s.revokeSession(context.Background(), uid, id))
+		_, err := s.refresh(context.Background(), refreshToken, newClient("test", "192.0.2.1:1234"))
+		require.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+	})
+	t.Run("unknown token", func(t *testing.T) {
+		s, _ := newTestService(t)
+		_, err := s.refresh(context.Background(), "unknown", newClient("test", "192.0.2.1:1234"))
+		require.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+	})
+}
+
+func TestPurgeSessionsKeepsRevokedTokens(t *testing.T) {
+	s, clock := newTestService(t)
+	ctx := context.Background()
+	uid := createUser(t, s, "user")
+	_, expired := startTestSession(t, s, uid)
+	clock.now = clock.now.Add(time.Hour)
+	id, first := startTestSession(t, s, uid)
+	_, err := s.refresh(ctx, first, newClient("test", "192.0.2.1:1234"))
+	require.NoError(t, err)
+
+	clock.now = clock.now.Add(time.Hour)
+	require.NoError(t, s.revokeSession(ctx, uid, id))
+	// The first session has expired; the revoked one expires in half an hour.
+	clock.now = clock.now.Add(sessionLifetime - 90*time.Minute)
+	require.NoError(t, s.PurgeSessions(ctx))
+	_, err = s.queries.GetSession(ctx, id)
+	require.NoError(t, err, "a revoked session is kept until it expires")
+	_, err = s.refresh(ctx, expired, newClient("test", "192.0.2.1:1234"))
+	require.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+	require.ErrorContains(t, err, "invalid refresh token", "an expired session is purged")
+
+	_, err = s.refresh(ctx, first, newClient("attacker", "203.0.113.9:1"))
+	require.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+	require.ErrorContains(t, err, "reused", "a rotated token of a revoked session is still reuse")
+
+	clock.now = clock.now.Add(time.Hour)
+	require.NoError(t, s.PurgeSessions(ctx))
+	_, err = s.queries.GetSession(ctx, id)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func TestRevokeSessionOfOtherUser(t *testing.T) {
+	s, _ := newTestService(t)
+	owner := createUser(t, s, "owner")
+	other := createUser(t, s, "other")
+	id, refreshToken := startTestSession(t, s, owner)
+
+	err := s.revokeSession(context.Background(), other, id)
+	require.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+	_, err = s.refresh(context.Background(), refreshToken, newClient("test", "192.0.2.1:1234"))
+	require.NoError(t, err, "the session must survive")
+}
+
+func TestCurrentSessionID(t *testing.T) {
+	s, _ := newTestService(t)
+	uid := createUser(t, s, "user")
+	id, refreshToken := startTestSession(t, s, uid)
+
+	require.Equal(t, id, s.currentSessionID(context.Background(), withCookie(newRefreshCookie(refreshToken, s.timeNow()))))
+	require.Zero(t, s.currentSessionID(context.Background(), withCookie(newRefreshCookie("unknown", s.timeNow()))))
+	require.Zero(t, s.currentSessionID(context.Background(), http.Header{}))
+}
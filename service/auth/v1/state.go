@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/oauth"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// stateTTL is how long users have to complete a sign-in at the provider.
+	stateTTL = 10 * time.Minute
+	// stateCookie holds a secret binding a sign-in to the browser that
+	// started it, so a state lured into another browser is refused.
+	stateCookie = "oauth_state"
+	// stateHeader carries the state the provider redirected back with to
+	// Login, whose request has no field for it.
+	stateHeader = "OAuth-State"
+)
+
+// beginSignIn records a sign-in with oauthApp and returns the URL to send the
+// user to, along with the cookie binding the sign-in to their browser.
+// Providers supporting PKCE are sent a code challenge as well.
+func (svc *service) beginSignIn(ctx context.Context, oauthApp oauth.App, idpName string) (string, *http.Cookie, error) {
+	now := svc.timeNow()
+	// Abandoned sign-ins are cleared as new ones begin.
+	if err := svc.queries.DeleteExpiredOAuthStates(ctx, pgtype.Timestamptz{Time: now, Valid: true}); err != nil {
+		return "", nil, connect.NewError(connect.CodeInternal, fmt.Errorf("deleting expired states: %v", err))
+	}
+
+	state := svc.randStr()
+	binding := svc.randStr()
+	var verifier string
+	if oauthApp.SupportsPKCE() {
+		verifier = oauth2.GenerateVerifier()
+	}
+	err := svc.queries.CreateOAuthState(ctx, postgres.CreateOAuthStateParams{
+		State:            state,
+		IdentityProvider: idpName,
+		CodeVerifier:     verifier,
+		BrowserHash:      hashSecret(binding),
+		CreatedAt:        pgtype.Timestamptz{Time: now, Valid: true},
+		ExpiresAt:        pgtype.Timestamptz{Time: now.Add(stateTTL), Valid: true},
+	})
+	if err != nil {
+		return "", nil, connect.NewError(connect.CodeInternal, fmt.Errorf("saving state: %v", err))
+	}
+
+	authURL, err := oauthApp.GetAuthURL(state, oauth.GetAuthURLOption{CodeChallengeVerifier: verifier})
+	if err != nil {
+		return "", nil, connect.NewError(connect.CodeInternal, err)
+	}
+	cookie := &http.Cookie{
+		Name:     stateCookie,
+		Value:    binding,
+		Expires:  now.Add(stateTTL),
+		MaxAge:   int(stateTTL.Seconds()),
+		HttpOnly: true,
+		Path:     "/",
+		Secure:   false,
+		SameSite: http.SameSiteLaxMode,
+	}
+	return authURL, cookie, nil
+}
+
+// consumeState checks that state was issued by beginSignIn for idpName to the
+// browser holding binding, and has not expired. The state is used up either
+// way. It returns the PKCE code verifier of the sign-in, if any.
+func (svc *service) consumeState(ctx context.Context, idpName, state, binding string) (string, error) {
+	if state == "" || binding == "" {
+		return "", connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("state required"))
+	}
+	s, err := svc.queries.ConsumeOAuthState(ctx, postgres.ConsumeOAuthStateParams{
+		State: state,
+		Now:   pgtype.Timestamptz{Time: svc.timeNow(), Valid: true},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", connect.NewError(connect.CodeFailedPrecondition, fmt.Errorf("sign-in expired or already completed"))
+	}
+	if err != nil {
+		return "", connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving state: %v", err))
+	}
+	if s.IdentityProvider != idpName || subtle.ConstantTimeCompare([]byte(s.BrowserHash), []byte(hashSecret(binding))) != 1 {
+		return "", connect.NewError(connect.CodePermissionDenied, fmt.Errorf("state mismatch"))
+	}
+	return s.CodeVerifier, nil
+}
+
+// clearStateCookie removes the state cookie once a sign-in is over.
+func clearStateCookie() *http.Cookie {
+	return clearCookie(stateCookie, "/")
+}
+
+// stateBinding reads the state cookie from request headers.
+func stateBinding(h http.Header) string {
+	c, err := (&http.Request{Header: h}).Cookie(stateCookie)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+// hashSecret hashes a secret handed to the browser, so the database only
+// holds values that are useless if leaked.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
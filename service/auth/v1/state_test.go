@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsumeStateOnlyOnce(t *testing.T) {
+	s, _ := newTestService(t)
+	state, binding := beginTestSignIn(t, s, &fakeApp{}, github)
+
+	_, err := s.consumeState(context.Background(), github, state, binding)
+	require.NoError(t, err)
+
+	_, err = s.consumeState(context.Background(), github, state, binding)
+	require.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+}
+
+func TestConsumeStateExpired(t *testing.T) {
+	s, clock := newTestService(t)
+	state, binding := beginTestSignIn(t, s, &fakeApp{}, github)
+
+	clock.now = clock.now.Add(stateTTL)
+	_, err := s.consumeState(context.Background(), github, state, binding)
+	require.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+}
+
+func TestConsumeStateMismatch(t *testing.T) {
+	for name, consume := range map[string]func(s *service, state, binding string) error{
+		"other browser": func(s *service, state, binding string) error {
+			_, err := s.consumeState(context.Background(), github, state, "not-"+binding)
+			return err
+		},
+		"other provider": func(s *service, state, binding string) error {
+			_, err := s.consumeState(context.Background(), google, state, binding)
+			return err
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s, _ := newTestService(t)
+			state, binding := beginTestSignIn(t, s, &fakeApp{}, github)
+
+			err := consume(s, state, binding)
+			require.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+
+			// A mismatch uses the state up, so it cannot be retried.
+			_, err = s.consumeState(context.Background(), github, state, binding)
+			require.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+		})
+	}
+}
+
+func TestConsumeStateMissing(t *testing.T) {
+	s, _ := newTestService(t)
+	state, binding := beginTestSignIn(t, s, &fakeApp{}, github)
+
+	_, err := s.consumeState(context.Background(), github, "", binding)
+	require.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	_, err = s.consumeState(context.Background(), github, state, "")
+	require.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+}
+
+func TestConsumeStateReturnsVerifier(t *testing.T) {
+	s, _ := newTestService(t)
+
+	app := &fakeApp{pkce: true}
+	state, binding := beginTestSignIn(t, s, app, github)
+	require.NotEmpty(t, app.verifier)
+	verifier, err := s.consumeState(context.Background(), github, state, binding)
+	require.NoError(t, err)
+	require.Equal(t, app.verifier, verifier)
+
+	state, binding = beginTestSignIn(t, s, &fakeApp{}, github)
+	verifier, err = s.consumeState(context.Background(), github, state, binding)
+	require.NoError(t, err)
+	require.Empty(t, verifier, "providers without PKCE get no verifier")
+}
@@ -14,23 +14,41 @@ import (
 	"github.com/gaesemo/blog-api/go/service/auth/v1/authv1connect"
 	typesv1 "github.com/gaesemo/blog-api/go/types/v1"
 	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/denylist"
+	"github.com/gaesemo/blog-server/pkg/httpapi"
 	"github.com/gaesemo/blog-server/pkg/oauth"
 	"github.com/gaesemo/blog-server/pkg/token"
 	"github.com/gaesemo/blog-server/pkg/transaction"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-var _ authv1connect.AuthServiceHandler = (*service)(nil)
+var _ Service = (*service)(nil)
+
+// Service serves the AuthService RPCs and, over plain HTTP, sign-in with the
+// generic OpenID Connect providers, which the RPC API has no identity
+// provider values for, as well as refreshing and managing sessions.
+type Service interface {
+	authv1connect.AuthServiceHandler
+	http.Handler
+	// PurgeSessions deletes expired sessions, including revoked ones once
+	// they have expired. It is meant to be run periodically.
+	PurgeSessions(ctx context.Context) error
+	// SyncDenylist loads tokens revoked by any server into the denylist the
+	// authorizer checks. It is meant to be run at startup and periodically.
+	SyncDenylist(ctx context.Context) error
+}
 
 func New(
 	logger *slog.Logger,
 	httpClient *http.Client,
-	db *pgx.Conn,
+	db *pgxpool.Pool,
 	timeNow func() time.Time,
 	randStr func() string,
+	denylist *denylist.List,
 	opts ...OAuthAppOption,
-) authv1connect.AuthServiceHandler {
+) Service {
 	svc := &service{
 		logger:     logger,
 		httpClient: httpClient,
@@ -38,33 +56,46 @@ func New(
 		queries:    postgres.New(db),
 		timeNow:    timeNow,
 		randStr:    randStr,
+		denylist:   denylist,
 		oauthApps:  map[string]oauth.App{},
+		respond:    httpapi.NewResponder(logger),
 	}
 
 	for _, o := range opts {
 		o(svc.oauthApps)
 	}
 
+	svc.mux = svc.routes()
 	return svc
 }
 
 const (
 	github = "github"
 	google = "google"
+	// oidcPrefix prefixes the names of generic OpenID Connect providers,
+	// both as keys of oauthApps and as the identity provider of their users.
+	oidcPrefix = "oidc:"
 )
 
 type OAuthAppOption func(cfgs map[string]oauth.App)
 
 type service struct {
 	logger     *slog.Logger
-	db         *pgx.Conn
+	db         *pgxpool.Pool
 	queries    *postgres.Queries
 	httpClient *http.Client
 	oauthApps  map[string]oauth.App
 	timeNow    func() time.Time
 	randStr    func() string
+	denylist   *denylist.List
+	mux        *http.ServeMux
+	respond    *httpapi.Responder
 }
 
+// GetAuthURL implements authv1connect.AuthServiceHandler.
+//
+// The sign-in is recorded under a fresh state, which Login checks, and bound
+// to the browser by a cookie set on the response.
 func (svc *service) GetAuthURL(ctx context.Context, req *connect.Request[authv1.GetAuthURLRequest]) (*connect.Response[authv1.GetAuthURLResponse], error) {
 
 	identityProvider := req.Msg.IdentityProvider
@@ -73,37 +104,99 @@ func (svc *service) GetAuthURL(ctx context.Context, req *connect.Request[authv1.
 	if err != nil {
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
+	idpName := typesv1.IdentityProvider_name[int32(identityProvider)]
 
-	authURL, err := oauthApp.GetAuthURL()
+	authURL, cookie, err := svc.beginSignIn(ctx, oauthApp, idpName)
 	if err != nil {
-		return nil, connect.NewError(connect.CodeInternal, err)
+		return nil, err
 	}
-	return connect.NewResponse(&authv1.GetAuthURLResponse{
+	resp := connect.NewResponse(&authv1.GetAuthURLResponse{
 		AuthUrl: authURL,
-	}), nil
+	})
+	resp.Header().Set("Set-Cookie", cookie.String())
+	return resp, nil
 }
 
+// Login implements authv1connect.AuthServiceHandler.
+//
+// LoginRequest has no field for the state the provider redirected back
+// with, so it is sent in the OAuth-State header. It must match a sign-in
+// begun by GetAuthURL in the same browser, and can be used only once.
+//
+// Besides the access token, which expires after an hour, the response sets
+// a refresh cookie, which POST /api/auth/refresh trades for a new one.
 func (svc *service) Login(ctx context.Context, req *connect.Request[authv1.LoginRequest]) (*connect.Response[authv1.LoginResponse], error) {
-	ll := svc.logger.With("login", req.Spec().Procedure)
 	identityProvider := req.Msg.IdentityProvider
-	code := req.Msg.Code
 
-	oauthApp, _ := svc.getOAuthApp(identityProvider)
+	oauthApp, err := svc.getOAuthApp(identityProvider)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	idpName := typesv1.IdentityProvider_name[int32(identityProvider)]
 
-	ll.DebugContext(ctx, "exchanging temporary code with access token", slog.String("code", code))
-	accessToken, err := oauthApp.ExchangeCode(code)
+	result, err := svc.signIn(ctx, req.Spec().Procedure, oauthApp, idpName, signInCallback{
+		code:    req.Msg.Code,
+		state:   req.Header().Get(stateHeader),
+		binding: stateBinding(req.Header()),
+		client:  newClient(req.Header().Get("User-Agent"), req.Peer().Addr),
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := connect.NewResponse(&authv1.LoginResponse{
+		Token:     result.token,
+		IsNewUser: result.isNewUser,
+	})
+	resp.Header().Add("Set-Cookie", result.cookie.String())
+	resp.Header().Add("Set-Cookie", result.refreshCookie.String())
+	resp.Header().Add("Set-Cookie", clearStateCookie().String())
+	return resp, nil
+}
+
+// signInCallback is what the provider redirected the user back with, plus
+// the state cookie of their browser and the device it is.
+type signInCallback struct {
+	code    string
+	state   string
+	binding string
+	client  client
+}
+
+type signInResult struct {
+	token     string
+	isNewUser bool
+	cookie    *http.Cookie
+	// refreshCookie carries the refresh token of the session signed in to.
+	refreshCookie *http.Cookie
+}
+
+// signIn checks the state of a sign-in begun by beginSignIn, exchanges its
+// authorization code with oauthApp, finds or creates the user it identifies
+// under idpName and starts a session for them.
+func (svc *service) signIn(ctx context.Context, via string, oauthApp oauth.App, idpName string, cb signInCallback) (*signInResult, error) {
+	ll := svc.logger.With("login", via)
+
+	verifier, err := svc.consumeState(ctx, idpName, cb.state, cb.binding)
+	if err != nil {
+		return nil, err
+	}
+
+	ll.DebugContext(ctx, "exchanging temporary code with access token", slog.String("code", cb.code))
+	accessToken, err := oauthApp.ExchangeCode(cb.code, oauth.ExchangeCodeOption{CodeVerifier: verifier})
 	if err != nil {
 		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("exchaning code: %v", err))
 	}
 
 	profile, err := oauthApp.GetUserProfile(accessToken)
 	if err != nil {
-		return nil, fmt.Errorf("denied: %v", err)
+		return nil, connect.NewError(connect.CodePermissionDenied, fmt.Errorf("denied: %v", err))
 	}
 
 	type Result struct {
-		User      *postgres.User
-		IsNewUser bool
+		User         *postgres.User
+		IsNewUser    bool
+		SessionID    int64
+		RefreshToken string
 	}
 
 	tx := transaction.New[Result](
@@ -113,16 +206,15 @@ func (svc *service) Login(ctx context.Context, req *connect.Request[authv1.Login
 	)
 
 	result, txErr := tx.Exec(ctx, func(c context.Context, q *postgres.Queries) (*Result, error) {
-		u, err := q.GetUserByEmailAndIDP(ctx, postgres.GetUserByEmailAndIDPParams{
+		isNewUser := false
+		u, err := q.GetUserByEmailAndIDP(c, postgres.GetUserByEmailAndIDPParams{
 			Email:            profile.Email,
-			IdentityProvider: typesv1.IdentityProvider_name[int32(typesv1.IdentityProvider_IDENTITY_PROVIDER_GITHUB)],
+			IdentityProvider: idpName,
 		})
-		if err == nil {
-			return &Result{User: &u, IsNewUser: false}, nil
-		}
 		if errors.Is(err, pgx.ErrNoRows) {
-			u, err := q.CreateUser(ctx, postgres.CreateUserParams{
-				IdentityProvider: typesv1.IdentityProvider_name[int32(typesv1.IdentityProvider_IDENTITY_PROVIDER_GITHUB)],
+			isNewUser = true
+			u, err = q.CreateUser(c, postgres.CreateUserParams{
+				IdentityProvider: idpName,
 				Email:            profile.Email,
 				Username:         profile.Name,
 				AvatarUrl:        profile.AvatarURL,
@@ -130,32 +222,49 @@ func (svc *service) Login(ctx context.Context, req *connect.Request[authv1.Login
 				CreatedAt:        pgtype.Timestamptz{Time: svc.timeNow(), Valid: true},
 				UpdatedAt:        pgtype.Timestamptz{Time: svc.timeNow(), Valid: true},
 			})
-			return &Result{User: &u, IsNewUser: true}, err
 		}
-		return nil, err
+		if err != nil {
+			return nil, err
+		}
+		sessionID, refreshToken, err := svc.startSession(c, q, u.ID, cb.client)
+		if err != nil {
+			return nil, err
+		}
+		return &Result{User: &u, IsNewUser: isNewUser, SessionID: sessionID, RefreshToken: refreshToken}, nil
 	})
 	if txErr != nil {
-		return nil, fmt.Errorf("in login flow: %v", err)
+		return nil, fmt.Errorf("in login flow: %v", txErr)
+	}
+
+	accessToken, cookie, err := svc.issueAccessToken(result.User.ID, result.SessionID)
+	if err != nil {
+		return nil, err
 	}
+	return &signInResult{
+		token:         accessToken,
+		isNewUser:     result.IsNewUser,
+		cookie:        cookie,
+		refreshCookie: newRefreshCookie(result.RefreshToken, svc.timeNow()),
+	}, nil
+}
 
-	user := result.User
-	isNewUser := result.IsNewUser
+// issueAccessToken signs a token for userID in sessionID, returned both as is
+// and as the cookie the authorizer reads.
+func (svc *service) issueAccessToken(userID, sessionID int64) (string, *http.Cookie, error) {
 	token := token.NewWithUserClaims(token.UserClaims{
 		Audience:       []string{},
 		Issuer:         "gsm",
 		IssuedAt:       time.Now(),
 		ExpirationTime: time.Now().Add(time.Hour),
 		NotBefore:      time.Now(),
-		UserID:         user.ID,
+		UserID:         userID,
+		ID:             svc.randStr(),
+		SessionID:      sessionID,
 	})
 	gsmAccessToken, err := token.SignedString([]byte(os.Getenv("JWT_SIGNING_SECRET")))
 	if err != nil {
-		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("signing token: %v", err))
+		return "", nil, connect.NewError(connect.CodeInternal, fmt.Errorf("signing token: %v", err))
 	}
-	resp := connect.NewResponse(&authv1.LoginResponse{
-		Token:     gsmAccessToken,
-		IsNewUser: isNewUser,
-	})
 
 	cookie := &http.Cookie{
 		Name:     "token",
@@ -167,13 +276,7 @@ func (svc *service) Login(ctx context.Context, req *connect.Request[authv1.Login
 		Secure:   false,
 		SameSite: http.SameSiteLaxMode,
 	}
-	resp.Header().Set("Set-Cookie", cookie.String())
-	return resp, nil
-}
-
-func (svc *service) Logout(ctx context.Context, req *connect.Request[authv1.LogoutRequest]) (*connect.Response[authv1.LogoutResponse], error) {
-	// TODO: cookie 무효화 하기
-	return connect.NewResponse(&authv1.LogoutResponse{}), nil
+	return gsmAccessToken, cookie, nil
 }
 
 func (svc *service) getOAuthApp(identityProvider typesv1.IdentityProvider) (oauth.App, error) {
@@ -184,6 +287,12 @@ func (svc *service) getOAuthApp(identityProvider typesv1.IdentityProvider) (oaut
 			return nil, fmt.Errorf("unsupported identity provider: github")
 		}
 		return oa, nil
+	case typesv1.IdentityProvider_IDENTITY_PROVIDER_GOOGLE:
+		oa, exist := svc.oauthApps[google]
+		if !exist {
+			return nil, fmt.Errorf("unsupported identity provider: google")
+		}
+		return oa, nil
 	case typesv1.IdentityProvider_IDENTITY_PROVIDER_UNSPECIFIED:
 		return nil, fmt.Errorf("identity provider unspecified")
 	default:
@@ -199,3 +308,24 @@ func WithGitHubOAuthApp(app oauth.App) OAuthAppOption {
 		}
 	}
 }
+
+func WithGoogleOAuthApp(app oauth.App) OAuthAppOption {
+	return func(oa map[string]oauth.App) {
+		_, exists := oa[google]
+		if !exists {
+			oa[google] = app
+		}
+	}
+}
+
+// WithOIDCApp registers a generic OpenID Connect provider under name. Its
+// users are told apart from those of other providers by name, so renaming a
+// provider signs its users up anew.
+func WithOIDCApp(name string, app oauth.App) OAuthAppOption {
+	return func(oa map[string]oauth.App) {
+		_, exists := oa[oidcPrefix+name]
+		if !exists {
+			oa[oidcPrefix+name] = app
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/denylist"
+	"github.com/gaesemo/blog-server/pkg/oauth"
+	"github.com/gaesemo/blog-server/pkg/pgtest"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+// clock is a timeNow that tests move by hand.
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func newTestService(t *testing.T, opts ...OAuthAppOption) (*service, *clock) {
+	t.Helper()
+	c := &clock{now: time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)}
+	svc := New(slog.New(slog.DiscardHandler), http.DefaultClient, pgtest.New(t), c.Now, rand.Text, denylist.New(), opts...)
+	return svc.(*service), c
+}
+
+// fakeApp is a provider that is never called over the network. It
+// remembers the code verifier it was last given a challenge for.
+type fakeApp struct {
+	pkce     bool
+	verifier string
+}
+
+func (f *fakeApp) GetAuthURL(state string, opt oauth.GetAuthURLOption) (string, error) {
+	f.verifier = opt.CodeChallengeVerifier
+	return "https://idp.example/authorize?" + url.Values{"state": {state}}.Encode(), nil
+}
+
+func (f *fakeApp) ExchangeCode(code string, opt oauth.ExchangeCodeOption) (string, error) {
+	return code, nil
+}
+
+func (f *fakeApp) GetUserProfile(accessToken string) (*oauth.UserProfile, error) {
+	return &oauth.UserProfile{Name: accessToken, Email: accessToken + "@example.com"}, nil
+}
+
+func (f *fakeApp) SupportsPKCE() bool { return f.pkce }
+
+// beginTestSignIn begins a sign-in with app under idpName, returning its
+// state and the value of the cookie binding it to the browser.
+func beginTestSignIn(t *testing.T, s *service, app oauth.App, idpName string) (string, string) {
+	t.Helper()
+	authURL, cookie, err := s.beginSignIn(context.Background(), app, idpName)
+	require.NoError(t, err)
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	return u.Query().Get("state"), cookie.Value
+}
+func createUser(t *testing.T, s *service, name string) int64 {
+	t.Helper()
+	now := pgtype.Timestamptz{Time: s.timeNow(), Valid: true}
+	u, err := s.queries.CreateUser(context.Background(), postgres.CreateUserParams{
+		IdentityProvider: "IDENTITY_PROVIDER_GITHUB",
+		Email:            name + "@example.com",
+		Username:         name,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	})
+	require.NoError(t, err)
+	return u.ID
+}
+
+// startTestSession starts a session for userID, returning its ID and
+// refresh token.
+func startTestSession(t *testing.T, s *service, userID int64) (int64, string) {
+	t.Helper()
+	id, refreshToken, err := s.startSession(context.Background(), s.queries, userID, newClient("test", "192.0.2.1:1234"))
+	require.NoError(t, err)
+	return id, refreshToken
+}
+
+// withCookie returns headers of a request carrying cookie.
+func withCookie(cookie *http.Cookie) http.Header {
+	h := http.Header{}
+	h.Add("Cookie", cookie.String())
+	return h
+}
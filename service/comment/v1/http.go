@@ -0,0 +1,81 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/httpapi"
+)
+
+func (s *service) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/posts/{id}/comments", s.list)
+	mux.HandleFunc("POST /api/posts/{id}/comments", s.create)
+	mux.HandleFunc("GET /api/comments/{id}/replies", s.replies)
+	mux.HandleFunc("PATCH /api/comments/{id}", s.edit)
+	mux.HandleFunc("DELETE /api/comments/{id}", s.remove)
+	mux.HandleFunc("PUT /api/comments/{id}/hidden", s.setHidden)
+	return mux
+}
+
+// ServeHTTP implements http.Handler.
+func (s *service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+type commentJSON struct {
+	ID        int64          `json:"id"`
+	PostID    int64          `json:"post_id"`
+	ParentID  *int64         `json:"parent_id,omitempty"`
+	Depth     int32          `json:"depth"`
+	Author    *authorJSON    `json:"author,omitempty"`
+	Body      string         `json:"body"`
+	Hidden    bool           `json:"hidden"`
+	Deleted   bool           `json:"deleted"`
+	EditedAt  *time.Time     `json:"edited_at,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	Replies   []*commentJSON `json:"replies,omitempty"`
+	// RepliesNext continues a thread that list cut short.
+	RepliesNext string `json:"replies_next,omitempty"`
+}
+
+type authorJSON struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// newCommentJSON converts a comment for HTTP responses. Deleted comments lose
+// their body and author; hidden ones lose their body unless showHidden is set
+// for a moderator or the comment's author.
+func newCommentJSON(c *postgres.Comment, author *postgres.User, showHidden bool) *commentJSON {
+	comment := &commentJSON{
+		ID:        c.ID,
+		PostID:    c.PostID,
+		Depth:     c.Depth,
+		Body:      c.Body,
+		Hidden:    c.HiddenAt.Valid,
+		Deleted:   c.DeletedAt.Valid,
+		EditedAt:  httpapi.TimePtr(c.EditedAt),
+		CreatedAt: c.CreatedAt.Time,
+	}
+	if c.ParentID.Valid {
+		comment.ParentID = &c.ParentID.Int64
+	}
+	if author != nil {
+		comment.Author = &authorJSON{
+			ID:        author.ID,
+			Username:  author.Username,
+			AvatarURL: author.AvatarUrl,
+		}
+	}
+	switch {
+	case comment.Deleted:
+		comment.Body = ""
+		comment.Author = nil
+	case comment.Hidden && !showHidden:
+		comment.Body = ""
+	}
+	return comment
+}
@@ -0,0 +1,519 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"connectrpc.com/connect"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/cursor"
+	"github.com/gaesemo/blog-server/pkg/httpapi"
+	"github.com/gaesemo/blog-server/pkg/transaction"
+	"github.com/gaesemo/blog-server/pkg/userloader"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ http.Handler = (*service)(nil)
+
+// New returns the HTTP handler for comments on posts.
+func New(
+	logger *slog.Logger,
+	db *pgxpool.Pool,
+	timeNow func() time.Time,
+	opts ...Option,
+) http.Handler {
+	svc := &service{
+		logger:  logger,
+		db:      db,
+		queries: postgres.New(db),
+		timeNow: timeNow,
+		respond: httpapi.NewResponder(logger),
+		admins:  map[int64]bool{},
+	}
+
+	for _, o := range opts {
+		o(svc)
+	}
+
+	svc.mux = svc.routes()
+	return svc
+}
+
+const (
+	// maxDepth bounds how deep replies nest: top-level comments have depth 0,
+	// so a reply to a comment at depth maxDepth-1 is rejected.
+	maxDepth      = 3
+	maxBodyLength = 5000
+	pageSize      = 20
+	// threadSize bounds how many replies list shows below each top-level
+	// comment; the rest of a thread is paged through with replies.
+	threadSize = 50
+)
+
+type Option func(svc *service)
+
+// WithAdmins lets the given users hide comments on any post, not only on
+// their own.
+func WithAdmins(userIDs ...int64) Option {
+	return func(svc *service) {
+		for _, id := range userIDs {
+			svc.admins[id] = true
+		}
+	}
+}
+
+type service struct {
+	logger  *slog.Logger
+	db      *pgxpool.Pool
+	queries *postgres.Queries
+	timeNow func() time.Time
+	mux     *http.ServeMux
+	respond *httpapi.Responder
+	admins  map[int64]bool
+}
+
+// list returns a page of threads on a post: top-level comments, oldest first,
+// each with its first replies nested below it. A thread with more replies
+// carries a replies_next cursor for replies.
+//
+//	GET /api/posts/{id}/comments?cursor=<opaque>
+func (s *service) list(w http.ResponseWriter, r *http.Request) {
+	postID, err := httpapi.PathInt64(r, "id")
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	post, err := s.visiblePost(r.Context(), s.queries, postID)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+
+	roots, err := s.queries.ListRootComments(r.Context(), postgres.ListRootCommentsParams{
+		PostID:   post.ID,
+		Cursor:   cursor.MustParseInt64(httpapi.QueryCursor(r)),
+		PageSize: pageSize + 1,
+	})
+	if err != nil {
+		s.respond.Error(w, r, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving comments: %v", err)))
+		return
+	}
+	hasNext := len(roots) > pageSize
+	if hasNext {
+		roots = roots[:pageSize]
+	}
+	rootIDs := make([]int64, 0, len(roots))
+	for _, c := range roots {
+		rootIDs = append(rootIDs, c.ID)
+	}
+	var replies []postgres.Comment
+	if len(rootIDs) > 0 {
+		// One reply more than the thread size tells whether a thread goes on.
+		replies, err = s.queries.ListCommentReplies(r.Context(), postgres.ListCommentRepliesParams{
+			RootIds:    rootIDs,
+			ThreadSize: threadSize + 1,
+		})
+		if err != nil {
+			s.respond.Error(w, r, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving replies: %v", err)))
+			return
+		}
+	}
+
+	all := append(roots, replies...)
+	authors, err := s.loadAuthors(r.Context(), all)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+
+	viewer := httpapi.ViewerID(r.Context())
+	moderator := s.canModerate(viewer, post)
+	threads := []*commentJSON{}
+	byID := make(map[int64]*commentJSON, len(all))
+	shown := make(map[int64]int, len(roots))
+	last := make(map[int64]int64, len(roots))
+	// Replies come after their parents, as ids grow with time, so the
+	// replies kept in a thread always hang from comments that are kept too.
+	for _, c := range all {
+		comment := newCommentJSON(&c, authors[c.UserID], moderator || c.UserID == viewer)
+		if !c.ParentID.Valid {
+			byID[c.ID] = comment
+			threads = append(threads, comment)
+			continue
+		}
+		rootID := c.RootID.Int64
+		if shown[rootID] == threadSize {
+			byID[rootID].RepliesNext = string(cursor.FromInt64(last[rootID]).Opaque)
+			continue
+		}
+		shown[rootID]++
+		last[rootID] = c.ID
+		byID[c.ID] = comment
+		if parent, ok := byID[c.ParentID.Int64]; ok {
+			parent.Replies = append(parent.Replies, comment)
+		}
+	}
+
+	var next string
+	if hasNext {
+		next = string(cursor.FromInt64(roots[len(roots)-1].ID).Opaque)
+	}
+	s.respond.JSON(w, r, struct {
+		Comments []*commentJSON `json:"comments"`
+		Next     string         `json:"next,omitempty"`
+	}{
+		Comments: threads,
+		Next:     next,
+	})
+}
+
+// replies returns a page of the replies in the thread started by a top-level
+// comment, oldest first, to go on from the replies_next cursor of list. The
+// replies come flat; parent_id places each of them in the thread.
+//
+//	GET /api/comments/{id}/replies?cursor=<opaque>
+func (s *service) replies(w http.ResponseWriter, r *http.Request) {
+	rootID, err := httpapi.PathInt64(r, "id")
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	root, err := s.queries.GetCommentById(r.Context(), rootID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && root.ParentID.Valid) {
+		s.respond.Error(w, r, connect.NewError(connect.CodeNotFound, fmt.Errorf("thread not found")))
+		return
+	}
+	if err != nil {
+		s.respond.Error(w, r, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving comment: %v", err)))
+		return
+	}
+	post, err := s.visiblePost(r.Context(), s.queries, root.PostID)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+
+	replies, err := s.queries.ListThreadReplies(r.Context(), postgres.ListThreadRepliesParams{
+		RootID:   root.ID,
+		Cursor:   cursor.MustParseInt64(httpapi.QueryCursor(r)),
+		PageSize: pageSize + 1,
+	})
+	if err != nil {
+		s.respond.Error(w, r, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving replies: %v", err)))
+		return
+	}
+	hasNext := len(replies) > pageSize
+	if hasNext {
+		replies = replies[:pageSize]
+	}
+	authors, err := s.loadAuthors(r.Context(), replies)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+
+	viewer := httpapi.ViewerID(r.Context())
+	moderator := s.canModerate(viewer, post)
+	page := make([]*commentJSON, 0, len(replies))
+	for _, c := range replies {
+		page = append(page, newCommentJSON(&c, authors[c.UserID], moderator || c.UserID == viewer))
+	}
+	var next string
+	if hasNext {
+		next = string(cursor.FromInt64(replies[len(replies)-1].ID).Opaque)
+	}
+	s.respond.JSON(w, r, struct {
+		Replies []*commentJSON `json:"replies"`
+		Next    string         `json:"next,omitempty"`
+	}{
+		Replies: page,
+		Next:    next,
+	})
+}
+
+// create adds a comment to a published post, or a reply to a comment when
+// parent_id is set.
+//
+//	POST /api/posts/{id}/comments
+func (s *service) create(w http.ResponseWriter, r *http.Request) {
+	uid, err := httpapi.RequireUserID(r)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	postID, err := httpapi.PathInt64(r, "id")
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	var req struct {
+		Body     string `json:"body"`
+		ParentID *int64 `json:"parent_id"`
+	}
+	if err := httpapi.ReadJSON(r, &req); err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	body, err := validBody(req.Body)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+
+	tx := transaction.New[postgres.Comment](
+		s.db,
+		pgx.TxOptions{
+			IsoLevel:   pgx.RepeatableRead,
+			AccessMode: pgx.ReadWrite,
+		},
+		s.queries,
+	)
+	comment, txErr := tx.Exec(r.Context(), func(c context.Context, q *postgres.Queries) (*postgres.Comment, error) {
+		post, err := s.visiblePost(c, q, postID)
+		if err != nil {
+			return nil, err
+		}
+		if post.Status != "published" {
+			return nil, connect.NewError(connect.CodeFailedPrecondition, fmt.Errorf("only published posts take comments"))
+		}
+		params := postgres.CreateCommentParams{
+			PostID:    post.ID,
+			UserID:    uid,
+			Body:      body,
+			CreatedAt: pgtype.Timestamptz{Time: s.timeNow(), Valid: true},
+			UpdatedAt: pgtype.Timestamptz{Time: s.timeNow(), Valid: true},
+		}
+		if req.ParentID != nil {
+			parent, err := q.GetCommentByIdForUpdate(c, *req.ParentID)
+			if errors.Is(err, pgx.ErrNoRows) || (err == nil && parent.PostID != post.ID) {
+				return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("parent comment not found"))
+			}
+			if err != nil {
+				return nil, fmt.Errorf("retrieving parent comment: %v", err)
+			}
+			if parent.DeletedAt.Valid {
+				return nil, connect.NewError(connect.CodeFailedPrecondition, fmt.Errorf("cannot reply to a deleted comment"))
+			}
+			if parent.Depth+1 >= maxDepth {
+				return nil, connect.NewError(connect.CodeFailedPrecondition, fmt.Errorf("replies nest at most %d levels deep", maxDepth))
+			}
+			params.ParentID = pgtype.Int8{Int64: parent.ID, Valid: true}
+			params.RootID = parent.RootID
+			if !parent.RootID.Valid {
+				params.RootID = pgtype.Int8{Int64: parent.ID, Valid: true}
+			}
+			params.Depth = parent.Depth + 1
+		}
+		comment, err := q.CreateComment(c, params)
+		if err != nil {
+			return nil, fmt.Errorf("insert new comment: %v", err)
+		}
+		return &comment, nil
+	})
+	if txErr != nil {
+		s.respond.Error(w, r, httpapi.AsConnectError(txErr, "creating comment"))
+		return
+	}
+	s.writeComment(w, r, comment)
+}
+
+// edit replaces the body of the signed-in user's comment and marks it as
+// edited.
+//
+//	PATCH /api/comments/{id}
+func (s *service) edit(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Body string `json:"body"`
+	}
+	if err := httpapi.ReadJSON(r, &req); err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	body, err := validBody(req.Body)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	s.modify(w, r, func(c context.Context, q *postgres.Queries, uid int64, comment *postgres.Comment) (*postgres.Comment, error) {
+		if comment.UserID != uid {
+			return nil, connect.NewError(connect.CodePermissionDenied, fmt.Errorf("not the author of the comment"))
+		}
+		if comment.Body == body {
+			return comment, nil
+		}
+		edited, err := q.UpdateCommentBody(c, postgres.UpdateCommentBodyParams{
+			Body:     body,
+			EditedAt: pgtype.Timestamptz{Time: s.timeNow(), Valid: true},
+			ID:       comment.ID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("updating comment: %v", err)
+		}
+		return &edited, nil
+	})
+}
+
+// remove soft-deletes the signed-in user's comment. Replies stay in place
+// below it.
+//
+//	DELETE /api/comments/{id}
+func (s *service) remove(w http.ResponseWriter, r *http.Request) {
+	s.modify(w, r, func(c context.Context, q *postgres.Queries, uid int64, comment *postgres.Comment) (*postgres.Comment, error) {
+		if comment.UserID != uid {
+			return nil, connect.NewError(connect.CodePermissionDenied, fmt.Errorf("not the author of the comment"))
+		}
+		deleted, err := q.SoftDeleteComment(c, postgres.SoftDeleteCommentParams{
+			DeletedAt: pgtype.Timestamptz{Time: s.timeNow(), Valid: true},
+			ID:        comment.ID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("deleting comment: %v", err)
+		}
+		return &deleted, nil
+	})
+}
+
+// setHidden hides a comment from readers, or shows it again. Only the author
+// of the post and admins may do so; the comment's author still sees it.
+//
+//	PUT /api/comments/{id}/hidden
+func (s *service) setHidden(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Hidden bool `json:"hidden"`
+	}
+	if err := httpapi.ReadJSON(r, &req); err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	s.modify(w, r, func(c context.Context, q *postgres.Queries, uid int64, comment *postgres.Comment) (*postgres.Comment, error) {
+		post, err := s.visiblePost(c, q, comment.PostID)
+		if err != nil {
+			return nil, err
+		}
+		if !s.canModerate(uid, post) {
+			return nil, connect.NewError(connect.CodePermissionDenied, fmt.Errorf("only the post author or an admin can hide comments"))
+		}
+		if comment.HiddenAt.Valid == req.Hidden {
+			return comment, nil
+		}
+		now := pgtype.Timestamptz{Time: s.timeNow(), Valid: true}
+		params := postgres.SetCommentHiddenParams{
+			UpdatedAt: now,
+			ID:        comment.ID,
+		}
+		if req.Hidden {
+			params.HiddenAt = now
+			params.HiddenBy = pgtype.Int8{Int64: uid, Valid: true}
+		}
+		hidden, err := q.SetCommentHidden(c, params)
+		if err != nil {
+			return nil, fmt.Errorf("hiding comment: %v", err)
+		}
+		return &hidden, nil
+	})
+}
+
+type modifyFunc func(c context.Context, q *postgres.Queries, uid int64, comment *postgres.Comment) (*postgres.Comment, error)
+
+// modify runs f on the locked comment named in the path, on behalf of the
+// signed-in user, and responds with the modified comment. Deleted comments
+// cannot be modified.
+func (s *service) modify(w http.ResponseWriter, r *http.Request, f modifyFunc) {
+	uid, err := httpapi.RequireUserID(r)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	commentID, err := httpapi.PathInt64(r, "id")
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+
+	tx := transaction.New[postgres.Comment](
+		s.db,
+		pgx.TxOptions{
+			IsoLevel:   pgx.RepeatableRead,
+			AccessMode: pgx.ReadWrite,
+		},
+		s.queries,
+	)
+	comment, txErr := tx.Exec(r.Context(), func(c context.Context, q *postgres.Queries) (*postgres.Comment, error) {
+		comment, err := q.GetCommentByIdForUpdate(c, commentID)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && comment.DeletedAt.Valid) {
+			return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("comment not found"))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("retrieving comment: %v", err)
+		}
+		return f(c, q, uid, &comment)
+	})
+	if txErr != nil {
+		s.respond.Error(w, r, httpapi.AsConnectError(txErr, "updating comment"))
+		return
+	}
+	s.writeComment(w, r, comment)
+}
+
+// visiblePost returns the post if the viewer can read it.
+func (s *service) visiblePost(ctx context.Context, q *postgres.Queries, postID int64) (*postgres.Post, error) {
+	post, err := q.GetPostById(ctx, postgres.GetPostByIdParams{
+		ID:       postID,
+		ViewerID: httpapi.ViewerID(ctx),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("post not found"))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving post: %v", err))
+	}
+	return &post, nil
+}
+
+// canModerate reports whether uid may hide comments on post.
+func (s *service) canModerate(uid int64, post *postgres.Post) bool {
+	return uid != 0 && (uid == post.UserID || s.admins[uid])
+}
+
+// loadAuthors returns the authors of comments by id.
+func (s *service) loadAuthors(ctx context.Context, comments []postgres.Comment) (map[int64]*postgres.User, error) {
+	ids := make([]int64, 0, len(comments))
+	for _, c := range comments {
+		ids = append(ids, c.UserID)
+	}
+	authors, err := userloader.Load(ctx, s.queries, ids)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving authors: %v", err))
+	}
+	return authors, nil
+}
+
+// writeComment responds with a comment the signed-in user just changed. They
+// are its author or a moderator, so a hidden body is shown.
+func (s *service) writeComment(w http.ResponseWriter, r *http.Request, c *postgres.Comment) {
+	authors, err := userloader.Load(r.Context(), s.queries, []int64{c.UserID})
+	if err != nil {
+		s.respond.Error(w, r, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving author: %v", err)))
+		return
+	}
+	s.respond.JSON(w, r, newCommentJSON(c, authors[c.UserID], true))
+}
+
+func validBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("comment body required"))
+	}
+	if utf8.RuneCountInString(body) > maxBodyLength {
+		return "", connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("comment longer than %d characters", maxBodyLength))
+	}
+	return body, nil
+}
@@ -0,0 +1,339 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/authn"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/pgtest"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+// clock is a timeNow that tests move by hand.
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func newTestService(t *testing.T, opts ...Option) (*service, *clock) {
+	t.Helper()
+	c := &clock{now: time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)}
+	svc := New(slog.New(slog.DiscardHandler), pgtest.New(t), c.Now, opts...)
+	return svc.(*service), c
+}
+
+func createUser(t *testing.T, s *service, name string) int64 {
+	t.Helper()
+	now := pgtype.Timestamptz{Time: s.timeNow(), Valid: true}
+	u, err := s.queries.CreateUser(context.Background(), postgres.CreateUserParams{
+		IdentityProvider: "IDENTITY_PROVIDER_GITHUB",
+		Email:            name + "@example.com",
+		Username:         name,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	})
+	require.NoError(t, err)
+	return u.ID
+}
+
+func asUser(uid int64) context.Context {
+	return authn.SetInfo(context.Background(), &uid)
+}
+
+func createPost(t *testing.T, s *service, uid int64, slug, status string) int64 {
+	t.Helper()
+	now := pgtype.Timestamptz{Time: s.timeNow(), Valid: true}
+	params := postgres.CreatePostParams{
+		Title:     slug,
+		Body:      "body",
+		UserID:    uid,
+		Status:    status,
+		Slug:      slug,
+		Toc:       []byte("[]"),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if status == "published" {
+		params.PublishedAt = now
+	}
+	p, err := s.queries.CreatePost(context.Background(), params)
+	require.NoError(t, err)
+	return p.ID
+}
+
+// serve sends an HTTP request to s from the user with ctx.
+func serve(t *testing.T, s *service, ctx context.Context, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequestWithContext(ctx, method, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var v T
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &v))
+	return v
+}
+
+func commentPath(id int64, suffix string) string {
+	return "/api/comments/" + strconv.FormatInt(id, 10) + suffix
+}
+
+func postComment(t *testing.T, s *service, uid, postID int64, parentID int64, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := map[string]any{"body": body}
+	if parentID != 0 {
+		req["parent_id"] = parentID
+	}
+	b, err := json.Marshal(req)
+	require.NoError(t, err)
+	return serve(t, s, asUser(uid), http.MethodPost, "/api/posts/"+strconv.FormatInt(postID, 10)+"/comments", string(b))
+}
+
+func comment(t *testing.T, s *service, uid, postID int64, parentID int64, body string) *commentJSON {
+	t.Helper()
+	return decode[*commentJSON](t, postComment(t, s, uid, postID, parentID, body))
+}
+
+type listJSON struct {
+	Comments []*commentJSON `json:"comments"`
+	Next     string         `json:"next"`
+}
+
+func listComments(t *testing.T, s *service, ctx context.Context, postID int64, cursor string) listJSON {
+	t.Helper()
+	path := "/api/posts/" + strconv.FormatInt(postID, 10) + "/comments"
+	if cursor != "" {
+		path += "?cursor=" + cursor
+	}
+	return decode[listJSON](t, serve(t, s, ctx, http.MethodGet, path, ""))
+}
+
+func TestReplyDepthLimit(t *testing.T) {
+	s, _ := newTestService(t)
+	uid := createUser(t, s, "user")
+	postID := createPost(t, s, uid, "post", "published")
+
+	parent := comment(t, s, uid, postID, 0, "root")
+	require.Zero(t, parent.Depth)
+	for depth := int32(1); depth < maxDepth; depth++ {
+		reply := comment(t, s, uid, postID, parent.ID, "reply")
+		require.Equal(t, depth, reply.Depth)
+		require.Equal(t, parent.ID, *reply.ParentID)
+		parent = reply
+	}
+	w := postComment(t, s, uid, postID, parent.ID, "too deep")
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	list := listComments(t, s, context.Background(), postID, "")
+	require.Len(t, list.Comments, 1)
+	thread := list.Comments[0]
+	for depth := int32(1); depth < maxDepth; depth++ {
+		require.Len(t, thread.Replies, 1, "replies nest below their parents")
+		thread = thread.Replies[0]
+		require.Equal(t, depth, thread.Depth)
+	}
+	require.Empty(t, thread.Replies)
+}
+
+func TestCommentNeedsPublishedPost(t *testing.T) {
+	s, _ := newTestService(t)
+	uid := createUser(t, s, "user")
+	draft := createPost(t, s, uid, "draft", "draft")
+	w := postComment(t, s, uid, draft, 0, "first")
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	other := createPost(t, s, uid, "other", "published")
+	root := comment(t, s, uid, other, 0, "elsewhere")
+	published := createPost(t, s, uid, "published", "published")
+	w = postComment(t, s, uid, published, root.ID, "reply across posts")
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+}
+
+func TestRemoveKeepsReplies(t *testing.T) {
+	s, _ := newTestService(t)
+	author := createUser(t, s, "author")
+	replier := createUser(t, s, "replier")
+	postID := createPost(t, s, author, "post", "published")
+	root := comment(t, s, author, postID, 0, "root")
+	reply := comment(t, s, replier, postID, root.ID, "reply")
+
+	w := serve(t, s, asUser(replier), http.MethodDelete, commentPath(root.ID, ""), "")
+	require.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	removed := decode[*commentJSON](t, serve(t, s, asUser(author), http.MethodDelete, commentPath(root.ID, ""), ""))
+	require.True(t, removed.Deleted)
+	require.Empty(t, removed.Body)
+	require.Nil(t, removed.Author)
+
+	list := listComments(t, s, context.Background(), postID, "")
+	require.Len(t, list.Comments, 1)
+	require.True(t, list.Comments[0].Deleted)
+	require.Empty(t, list.Comments[0].Body)
+	require.Len(t, list.Comments[0].Replies, 1)
+	require.Equal(t, reply.ID, list.Comments[0].Replies[0].ID)
+	require.Equal(t, "reply", list.Comments[0].Replies[0].Body)
+
+	w = serve(t, s, asUser(author), http.MethodDelete, commentPath(root.ID, ""), "")
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	w = serve(t, s, asUser(author), http.MethodPatch, commentPath(root.ID, ""), `{"body":"back"}`)
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	w = postComment(t, s, replier, postID, root.ID, "another reply")
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+}
+
+func TestEditMarksEdited(t *testing.T) {
+	s, c := newTestService(t)
+	author := createUser(t, s, "author")
+	other := createUser(t, s, "other")
+	postID := createPost(t, s, author, "post", "published")
+	created := comment(t, s, author, postID, 0, "first")
+	require.Nil(t, created.EditedAt)
+
+	c.now = c.now.Add(time.Hour)
+	same := decode[*commentJSON](t, serve(t, s, asUser(author), http.MethodPatch, commentPath(created.ID, ""), `{"body":" first "}`))
+	require.Nil(t, same.EditedAt, "the same body is no edit")
+
+	w := serve(t, s, asUser(other), http.MethodPatch, commentPath(created.ID, ""), `{"body":"mine"}`)
+	require.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	w = serve(t, s, asUser(author), http.MethodPatch, commentPath(created.ID, ""), `{"body":"  "}`)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	edited := decode[*commentJSON](t, serve(t, s, asUser(author), http.MethodPatch, commentPath(created.ID, ""), `{"body":"second"}`))
+	require.Equal(t, "second", edited.Body)
+	require.NotNil(t, edited.EditedAt)
+	require.True(t, c.now.Equal(*edited.EditedAt))
+
+	list := listComments(t, s, context.Background(), postID, "")
+	require.Equal(t, "second", list.Comments[0].Body)
+	require.NotNil(t, list.Comments[0].EditedAt)
+}
+
+func TestHideComment(t *testing.T) {
+	s, _ := newTestService(t)
+	postAuthor := createUser(t, s, "post-author")
+	commenter := createUser(t, s, "commenter")
+	reader := createUser(t, s, "reader")
+	admin := createUser(t, s, "admin")
+	// Users get their ids from the database the service runs on.
+	WithAdmins(admin)(s)
+	postID := createPost(t, s, postAuthor, "post", "published")
+	created := comment(t, s, commenter, postID, 0, "rude")
+
+	w := serve(t, s, asUser(commenter), http.MethodPut, commentPath(created.ID, "/hidden"), `{"hidden":true}`)
+	require.Equal(t, http.StatusForbidden, w.Code, "the comment's author cannot hide it")
+	w = serve(t, s, asUser(reader), http.MethodPut, commentPath(created.ID, "/hidden"), `{"hidden":true}`)
+	require.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+
+	hidden := decode[*commentJSON](t, serve(t, s, asUser(postAuthor), http.MethodPut, commentPath(created.ID, "/hidden"), `{"hidden":true}`))
+	require.True(t, hidden.Hidden)
+
+	for name, tc := range map[string]struct {
+		ctx  context.Context
+		body string
+	}{
+		"anonymous":   {context.Background(), ""},
+		"reader":      {asUser(reader), ""},
+		"commenter":   {asUser(commenter), "rude"},
+		"post author": {asUser(postAuthor), "rude"},
+		"admin":       {asUser(admin), "rude"},
+	} {
+		list := listComments(t, s, tc.ctx, postID, "")
+		require.Len(t, list.Comments, 1, name)
+		require.True(t, list.Comments[0].Hidden, name)
+		require.Equal(t, tc.body, list.Comments[0].Body, name)
+	}
+
+	shown := decode[*commentJSON](t, serve(t, s, asUser(admin), http.MethodPut, commentPath(created.ID, "/hidden"), `{"hidden":false}`))
+	require.False(t, shown.Hidden)
+	list := listComments(t, s, context.Background(), postID, "")
+	require.Equal(t, "rude", list.Comments[0].Body)
+}
+
+func TestListPagesThreads(t *testing.T) {
+	s, _ := newTestService(t)
+	uid := createUser(t, s, "user")
+	postID := createPost(t, s, uid, "post", "published")
+	var ids []int64
+	for i := range pageSize {
+		ids = append(ids, comment(t, s, uid, postID, 0, "comment "+strconv.Itoa(i)).ID)
+	}
+
+	list := listComments(t, s, context.Background(), postID, "")
+	require.Len(t, list.Comments, pageSize)
+	require.Empty(t, list.Next, "a full last page has no next page")
+
+	for i := range 5 {
+		ids = append(ids, comment(t, s, uid, postID, 0, "more "+strconv.Itoa(i)).ID)
+	}
+	var got []int64
+	cursor := ""
+	for page := 0; ; page++ {
+		require.Less(t, page, 2)
+		list := listComments(t, s, context.Background(), postID, cursor)
+		for _, c := range list.Comments {
+			got = append(got, c.ID)
+		}
+		if list.Next == "" {
+			break
+		}
+		cursor = list.Next
+	}
+	require.Equal(t, ids, got, "oldest first, each comment once")
+}
+
+func TestListBoundsThreads(t *testing.T) {
+	s, _ := newTestService(t)
+	uid := createUser(t, s, "user")
+	postID := createPost(t, s, uid, "post", "published")
+	root := comment(t, s, uid, postID, 0, "root")
+	var ids []int64
+	for i := range threadSize + pageSize + 1 {
+		parent := root.ID
+		if i%2 == 1 {
+			parent = ids[i-1]
+		}
+		ids = append(ids, comment(t, s, uid, postID, parent, "reply "+strconv.Itoa(i)).ID)
+	}
+
+	list := listComments(t, s, context.Background(), postID, "")
+	require.Len(t, list.Comments, 1)
+	thread := list.Comments[0]
+	var shown []int64
+	for _, reply := range thread.Replies {
+		shown = append(shown, reply.ID)
+		for _, nested := range reply.Replies {
+			shown = append(shown, nested.ID)
+		}
+	}
+	require.ElementsMatch(t, ids[:threadSize], shown)
+	require.NotEmpty(t, thread.RepliesNext)
+
+	type repliesJSON struct {
+		Replies []*commentJSON `json:"replies"`
+		Next    string         `json:"next"`
+	}
+	rest := decode[repliesJSON](t, serve(t, s, context.Background(), http.MethodGet, commentPath(root.ID, "/replies?cursor="+thread.RepliesNext), ""))
+	require.Len(t, rest.Replies, pageSize)
+	require.NotEmpty(t, rest.Next)
+	last := decode[repliesJSON](t, serve(t, s, context.Background(), http.MethodGet, commentPath(root.ID, "/replies?cursor="+rest.Next), ""))
+	require.Empty(t, last.Next)
+	var paged []int64
+	for _, reply := range append(rest.Replies, last.Replies...) {
+		paged = append(paged, reply.ID)
+	}
+	require.Equal(t, ids[threadSize:], paged)
+
+	w := serve(t, s, context.Background(), http.MethodGet, commentPath(ids[0], "/replies"), "")
+	require.Equal(t, http.StatusNotFound, w.Code, "replies page through threads, not through replies")
+}
@@ -0,0 +1,251 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"connectrpc.com/connect"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/feed"
+	"github.com/gaesemo/blog-server/pkg/httpapi"
+	"github.com/gaesemo/blog-server/pkg/markdown"
+	"github.com/gaesemo/blog-server/pkg/tag"
+	"github.com/gaesemo/blog-server/pkg/textstat"
+	"github.com/gaesemo/blog-server/pkg/userloader"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ http.Handler = (*service)(nil)
+
+// New returns the HTTP handler serving RSS, Atom and JSON feeds of the
+// latest published posts, for the whole blog, per author and per tag.
+func New(
+	logger *slog.Logger,
+	db *pgxpool.Pool,
+	site httpapi.Site,
+	opts ...Option,
+) http.Handler {
+	svc := &service{
+		logger:  logger,
+		queries: postgres.New(db),
+		respond: httpapi.NewResponder(logger),
+		site:    site,
+	}
+
+	for _, o := range opts {
+		o(svc)
+	}
+
+	svc.mux = svc.routes()
+	return svc
+}
+
+const feedSize = 20
+
+type Option func(svc *service)
+
+// WithSummaryOnly makes feed items carry a plain text summary instead of the
+// full post, so readers have to visit the blog to read on.
+func WithSummaryOnly() Option {
+	return func(svc *service) {
+		svc.summaryOnly = true
+	}
+}
+
+type service struct {
+	logger      *slog.Logger
+	queries     *postgres.Queries
+	mux         *http.ServeMux
+	respond     *httpapi.Responder
+	site        httpapi.Site
+	summaryOnly bool
+}
+
+// format encodes a feed in one of the supported formats.
+type format struct {
+	contentType string
+	encode      func(*feed.Feed) ([]byte, error)
+}
+
+var (
+	rss      = format{contentType: feed.RSSContentType, encode: feed.RSS}
+	atom     = format{contentType: feed.AtomContentType, encode: feed.Atom}
+	jsonFeed = format{contentType: feed.JSONContentType, encode: feed.JSON}
+)
+
+func (s *service) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	for name, f := range map[string]format{
+		"feed.xml":  rss,
+		"atom.xml":  atom,
+		"feed.json": jsonFeed,
+	} {
+		mux.HandleFunc("GET /"+name, s.siteFeed(f))
+		mux.HandleFunc("GET /authors/{id}/"+name, s.authorFeed(f))
+		mux.HandleFunc("GET /tags/{tag}/"+name, s.tagFeed(f))
+	}
+	return mux
+}
+
+// ServeHTTP implements http.Handler.
+func (s *service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// siteFeed serves the latest posts of the blog.
+//
+//	GET /feed.xml, /atom.xml, /feed.json
+func (s *service) siteFeed(f format) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.serveFeed(w, r, f, s.site.Title, postgres.ListRecentPostsParams{})
+	}
+}
+
+// authorFeed serves the latest posts of one author.
+//
+//	GET /authors/{id}/feed.xml, /authors/{id}/atom.xml, /authors/{id}/feed.json
+func (s *service) authorFeed(f format) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := httpapi.PathInt64(r, "id")
+		if err != nil {
+			s.respond.Error(w, r, err)
+			return
+		}
+		author, err := s.queries.GetUserById(r.Context(), id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			s.respond.Error(w, r, connect.NewError(connect.CodeNotFound, fmt.Errorf("author not found")))
+			return
+		}
+		if err != nil {
+			s.respond.Error(w, r, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving author: %v", err)))
+			return
+		}
+		s.serveFeed(w, r, f, s.site.Title+" - "+author.Username, postgres.ListRecentPostsParams{
+			AuthorID: pgtype.Int8{Int64: author.ID, Valid: true},
+		})
+	}
+}
+
+// tagFeed serves the latest posts with one tag.
+//
+//	GET /tags/{tag}/feed.xml, /tags/{tag}/atom.xml, /tags/{tag}/feed.json
+func (s *service) tagFeed(f format) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := tag.Normalize(r.PathValue("tag"))
+		if name == "" {
+			s.respond.Error(w, r, connect.NewError(connect.CodeNotFound, fmt.Errorf("tag not found")))
+			return
+		}
+		s.serveFeed(w, r, f, s.site.Title+" - #"+name, postgres.ListRecentPostsParams{
+			Tags:     []string{name},
+			MatchAll: true,
+		})
+	}
+}
+
+// serveFeed writes the feed of the posts matching params. Conditional GETs
+// are answered by http.ServeContent from the ETag, a hash of the encoded
+// feed, and Last-Modified, the latest update among its posts.
+func (s *service) serveFeed(w http.ResponseWriter, r *http.Request, f format, title string, params postgres.ListRecentPostsParams) {
+	// Anonymous, so only published posts are listed.
+	params.ViewerID = 0
+	params.PageSize = feedSize
+	posts, err := s.queries.ListRecentPosts(r.Context(), params)
+	if err != nil {
+		s.respond.Error(w, r, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving posts: %v", err)))
+		return
+	}
+	fd, err := s.feed(r.Context(), s.site.URL, r.URL.Path, title, posts)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	body, err := f.encode(fd)
+	if err != nil {
+		s.respond.Error(w, r, connect.NewError(connect.CodeInternal, fmt.Errorf("encoding feed: %v", err)))
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	w.Header().Set("Content-Type", f.contentType)
+	w.Header().Set("ETag", `"`+base64.RawURLEncoding.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	http.ServeContent(w, r, "", fd.Updated, bytes.NewReader(body))
+}
+
+func (s *service) feed(ctx context.Context, site, path, title string, posts []postgres.Post) (*feed.Feed, error) {
+	ids := make([]int64, 0, len(posts))
+	authorIDs := make([]int64, 0, len(posts))
+	for _, p := range posts {
+		ids = append(ids, p.ID)
+		authorIDs = append(authorIDs, p.UserID)
+	}
+	authors, err := userloader.Load(ctx, s.queries, authorIDs)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving authors: %v", err))
+	}
+	tags := map[int64][]string{}
+	if len(ids) > 0 {
+		rows, err := s.queries.ListTagsByPostIds(ctx, ids)
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving tags: %v", err))
+		}
+		for _, t := range rows {
+			tags[t.PostID] = append(tags[t.PostID], t.Name)
+		}
+	}
+
+	fd := &feed.Feed{
+		Title:   title,
+		Link:    site,
+		FeedURL: site + path,
+		Items:   make([]feed.Item, 0, len(posts)),
+	}
+	for _, p := range posts {
+		content := p.BodyHtml
+		if content == "" && p.Body != "" {
+			// Posts saved before bodies were rendered on write.
+			doc, err := markdown.Render([]byte(p.Body))
+			if err != nil {
+				return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("rendering post %d: %v", p.ID, err))
+			}
+			content = doc.HTML
+		}
+		item := feed.Item{
+			ID:        site + "/posts/" + strconv.FormatInt(p.ID, 10),
+			URL:       site + "/posts/" + p.Slug,
+			Title:     p.Title,
+			Summary:   p.Excerpt,
+			Tags:      tags[p.ID],
+			Published: p.CreatedAt.Time,
+			Updated:   p.UpdatedAt.Time,
+		}
+		if item.Summary == "" {
+			// Posts saved before excerpts were stored.
+			item.Summary = textstat.FromHTML(content).Excerpt
+		}
+		if p.PublishedAt.Valid {
+			item.Published = p.PublishedAt.Time
+		}
+		if !s.summaryOnly {
+			item.ContentHTML = content
+		}
+		if author, ok := authors[p.UserID]; ok {
+			item.Author = author.Username
+		}
+		if item.Updated.After(fd.Updated) {
+			fd.Updated = item.Updated
+		}
+		fd.Items = append(fd.Items, item)
+	}
+	return fd, nil
+}
@@ -0,0 +1,110 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// avatar downloads and decodes an avatar. Avatars that cannot be had are
+// logged and left out of the image rather than failing it.
+func (s *service) avatar(ctx context.Context, avatarURL string) image.Image {
+	if avatarURL == "" {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, avatarURL, nil)
+	if err != nil {
+		s.logger.WarnContext(ctx, "fetching avatar", slog.String("url", avatarURL), slog.Any("error", err))
+		return nil
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		s.logger.WarnContext(ctx, "fetching avatar", slog.String("url", avatarURL), slog.String("error", "not an HTTP(S) URL"))
+		return nil
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		s.logger.WarnContext(ctx, "fetching avatar", slog.String("url", avatarURL), slog.Any("error", err))
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		s.logger.WarnContext(ctx, "fetching avatar", slog.String("url", avatarURL), slog.Int("status", resp.StatusCode))
+		return nil
+	}
+	img, err := decodeAvatar(io.LimitReader(resp.Body, maxAvatarSize))
+	if err != nil {
+		s.logger.WarnContext(ctx, "decoding avatar", slog.String("url", avatarURL), slog.Any("error", err))
+		return nil
+	}
+	return img
+}
+
+// decodeAvatar decodes an image no larger than maxAvatarSide on either side.
+// The size is read from the header first, so oversized images are turned
+// away before their pixels are allocated.
+func decodeAvatar(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width > maxAvatarSide || cfg.Height > maxAvatarSide {
+		return nil, fmt.Errorf("image of %dx%d is larger than %dx%d", cfg.Width, cfg.Height, maxAvatarSide, maxAvatarSide)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+var errNotPublic = errors.New("refusing to connect to a non-public address")
+
+// publicOnly returns a client like c that only connects to public addresses,
+// so user-supplied URLs cannot reach the loopback interface, the private
+// network or cloud metadata endpoints, also not through redirects or DNS
+// names resolving there. It connects directly, without proxies.
+func publicOnly(c *http.Client) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublic(addrPort.Addr()) {
+				return fmt.Errorf("%w %s", errNotPublic, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		Timeout: c.Timeout,
+	}
+}
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which
+// netip does not count as private.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!sharedAddressSpace.Contains(addr)
+}
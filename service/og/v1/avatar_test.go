@@ -0,0 +1,63 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))))
+	return buf.Bytes()
+}
+
+func TestDecodeAvatar(t *testing.T) {
+	img, err := decodeAvatar(bytes.NewReader(encodePNG(t, 64, 48)))
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 64, 48), img.Bounds())
+
+	_, err = decodeAvatar(bytes.NewReader(encodePNG(t, maxAvatarSide+1, 1)))
+	require.ErrorContains(t, err, "larger than")
+}
+
+func TestPublicOnlyRefusesLocalAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(encodePNG(t, 1, 1))
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	_, err = publicOnly(srv.Client()).Do(req)
+	require.True(t, errors.Is(err, errNotPublic), "got %v", err)
+}
+
+func TestIsPublic(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"203.0.113.7":      true,
+		"2001:db8::1":      true,
+		"127.0.0.1":        false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::1":              false,
+		"fd00::1":          false,
+		"fe80::1":          false,
+		"::ffff:127.0.0.1": false,
+	} {
+		require.Equal(t, want, isPublic(netip.MustParseAddr(addr)), addr)
+	}
+}
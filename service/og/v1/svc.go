@@ -0,0 +1,299 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/httpapi"
+	"github.com/gaesemo/blog-server/pkg/ogimage"
+	"github.com/gaesemo/blog-server/pkg/textstat"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	_ "golang.org/x/image/webp"
+)
+
+var _ http.Handler = (*service)(nil)
+
+// New returns the HTTP handler serving link previews of published posts: an
+// HTML document carrying Open Graph and Twitter card tags, for the frontend
+// to hand to crawlers, and a default preview image drawn by renderer.
+func New(
+	logger *slog.Logger,
+	httpClient *http.Client,
+	db *pgxpool.Pool,
+	renderer *ogimage.Renderer,
+	site httpapi.Site,
+) http.Handler {
+	svc := &service{
+		logger:     logger,
+		httpClient: publicOnly(httpClient),
+		queries:    postgres.New(db),
+		renderer:   renderer,
+		respond:    httpapi.NewResponder(logger),
+		site:       site,
+		images:     map[string][]byte{},
+	}
+
+	svc.mux = svc.routes()
+	return svc
+}
+
+const (
+	// maxAvatarSize bounds the avatars downloaded for preview images.
+	maxAvatarSize = 5 << 20
+	// maxAvatarSide bounds the width and height of avatars, since a small
+	// file can decode to a huge image.
+	maxAvatarSide = 4096
+	// maxCachedImages bounds the preview images kept in memory. The cache
+	// is simply emptied when it fills up.
+	maxCachedImages = 256
+)
+
+type service struct {
+	logger *slog.Logger
+	// httpClient fetches avatars. It only connects to public addresses,
+	// since avatar URLs come from users.
+	httpClient *http.Client
+	queries    *postgres.Queries
+	renderer   *ogimage.Renderer
+	mux        *http.ServeMux
+	respond    *httpapi.Responder
+	site       httpapi.Site
+
+	mu     sync.Mutex // guards images
+	images map[string][]byte
+}
+
+func (s *service) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /og/posts/{ref}", s.serveDocument)
+	mux.HandleFunc("GET /og/posts/{ref}/image.png", s.serveImage)
+	return mux
+}
+
+// ServeHTTP implements http.Handler.
+func (s *service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// preview is what a link preview shows of a post.
+type preview struct {
+	post   postgres.Post
+	author postgres.User
+}
+
+// etag identifies the preview image, which changes with the post and its
+// author's name and avatar.
+func (p *preview) etag(site string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		strconv.FormatInt(p.post.ID, 10),
+		strconv.FormatInt(p.post.Version, 10),
+		p.author.Username,
+		p.author.AvatarUrl,
+		site,
+	}, "\x00")))
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// load finds a published post by its slug, former slugs included, or by ID.
+func (s *service) load(ctx context.Context, ref string) (*preview, error) {
+	// Anonymous, so only published posts are found.
+	post, err := s.queries.GetPostBySlug(ctx, postgres.GetPostBySlugParams{Slug: ref})
+	if errors.Is(err, pgx.ErrNoRows) {
+		if id, parseErr := strconv.ParseInt(ref, 10, 64); parseErr == nil {
+			post, err = s.queries.GetPostById(ctx, postgres.GetPostByIdParams{ID: id})
+		}
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("post not found"))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving post: %v", err))
+	}
+	author, err := s.queries.GetUserById(ctx, post.UserID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving author: %v", err))
+	}
+	return &preview{post: post, author: author}, nil
+}
+
+var document = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<link rel="canonical" href="{{.URL}}">
+<meta http-equiv="refresh" content="0; url={{.URL}}">
+<meta name="description" content="{{.Description}}">
+{{- if .Author}}
+<meta name="author" content="{{.Author}}">
+{{- end}}
+<meta property="og:type" content="article">
+<meta property="og:site_name" content="{{.SiteName}}">
+<meta property="og:title" content="{{.Title}}">
+<meta property="og:description" content="{{.Description}}">
+<meta property="og:url" content="{{.URL}}">
+<meta property="og:image" content="{{.Image}}">
+{{- if .ImageGenerated}}
+<meta property="og:image:type" content="image/png">
+<meta property="og:image:width" content="{{.ImageWidth}}">
+<meta property="og:image:height" content="{{.ImageHeight}}">
+{{- end}}
+<meta property="og:image:alt" content="{{.Title}}">
+{{- if .Author}}
+<meta property="article:author" content="{{.Author}}">
+{{- end}}
+{{- if .Published}}
+<meta property="article:published_time" content="{{.Published}}">
+{{- end}}
+<meta name="twitter:card" content="summary_large_image">
+<meta name="twitter:title" content="{{.Title}}">
+<meta name="twitter:description" content="{{.Description}}">
+<meta name="twitter:image" content="{{.Image}}">
+</head>
+<body>
+<a href="{{.URL}}">{{.Title}}</a>
+</body>
+</html>
+`))
+
+// serveDocument serves the preview document of a post. Its og:image is the
+// first image of the post, or else the image served by serveImage. Browsers
+// landing on the document are sent on to the post.
+//
+//	GET /og/posts/{slug or id}
+func (s *service) serveDocument(w http.ResponseWriter, r *http.Request) {
+	p, err := s.load(r.Context(), r.PathValue("ref"))
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	site := s.site.URL
+	data := struct {
+		SiteName       string
+		Title          string
+		Description    string
+		URL            string
+		Author         string
+		Published      string
+		Image          string
+		ImageGenerated bool
+		ImageWidth     int
+		ImageHeight    int
+	}{
+		SiteName:    s.site.Title,
+		Title:       p.post.Title,
+		Description: p.post.Excerpt,
+		URL:         site + "/posts/" + url.PathEscape(p.post.Slug),
+		Author:      p.author.Username,
+		Image:       cover(site, p.post.BodyHtml),
+		ImageWidth:  ogimage.Width,
+		ImageHeight: ogimage.Height,
+	}
+	if data.Description == "" {
+		// Posts saved before excerpts were stored.
+		data.Description = textstat.FromHTML(p.post.BodyHtml).Excerpt
+	}
+	if p.post.PublishedAt.Valid {
+		data.Published = p.post.PublishedAt.Time.UTC().Format(time.RFC3339)
+	}
+	if data.Image == "" {
+		// The image is served from here rather than from the site, which
+		// may be a separate frontend.
+		data.Image = s.site.APIURL + "/og/posts/" + strconv.FormatInt(p.post.ID, 10) + "/image.png"
+		data.ImageGenerated = true
+	}
+
+	var body bytes.Buffer
+	if err := document.Execute(&body, data); err != nil {
+		s.respond.Error(w, r, connect.NewError(connect.CodeInternal, fmt.Errorf("rendering preview: %v", err)))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	http.ServeContent(w, r, "", p.post.UpdatedAt.Time, bytes.NewReader(body.Bytes()))
+}
+
+// serveImage serves the default preview image of a post, showing its title
+// and author. Images are cached in memory until the post or its author
+// changes.
+//
+//	GET /og/posts/{slug or id}/image.png
+func (s *service) serveImage(w http.ResponseWriter, r *http.Request) {
+	p, err := s.load(r.Context(), r.PathValue("ref"))
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	tag := p.etag(s.site.Title)
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("ETag", tag)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+
+	s.mu.Lock()
+	body, ok := s.images[tag]
+	s.mu.Unlock()
+	if !ok {
+		body, err = s.renderer.PNG(ogimage.Card{
+			Title:  p.post.Title,
+			Author: p.author.Username,
+			Site:   s.site.Title,
+			Avatar: s.avatar(r.Context(), p.author.AvatarUrl),
+		})
+		if err != nil {
+			s.respond.Error(w, r, connect.NewError(connect.CodeInternal, fmt.Errorf("drawing preview image: %v", err)))
+			return
+		}
+		s.mu.Lock()
+		if len(s.images) >= maxCachedImages {
+			clear(s.images)
+		}
+		s.images[tag] = body
+		s.mu.Unlock()
+	}
+	http.ServeContent(w, r, "", p.post.UpdatedAt.Time, bytes.NewReader(body))
+}
+
+// imageSrc matches the source of an image in rendered, sanitised post bodies.
+var imageSrc = regexp.MustCompile(`(?i)<img\b[^>]*?\bsrc="([^"]+)"`)
+
+// cover returns the absolute URL of the first image of a post body, if it has
+// one served over HTTP(S). Relative sources are resolved against the site.
+func cover(site, bodyHTML string) string {
+	m := imageSrc.FindStringSubmatch(bodyHTML)
+	if m == nil {
+		return ""
+	}
+	base, err := url.Parse(site + "/")
+	if err != nil {
+		return ""
+	}
+	src, err := url.Parse(html.UnescapeString(m[1]))
+	if err != nil {
+		return ""
+	}
+	abs := base.ResolveReference(src)
+	if abs.Scheme != "http" && abs.Scheme != "https" {
+		return ""
+	}
+	return abs.String()
+}
@@ -0,0 +1,186 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/httpapi"
+)
+
+// routes registers the HTTP endpoints for post operations that are not part
+// of the PostService RPC API.
+func (s *service) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/posts", s.listPosts)
+	mux.HandleFunc("POST /api/posts", s.createPost)
+	mux.HandleFunc("GET /api/posts/{id}", s.detail)
+	mux.HandleFunc("PUT /api/posts/{id}", s.updatePost)
+	mux.HandleFunc("GET /api/posts/search", s.search)
+	mux.HandleFunc("GET /api/posts/trash", s.listTrash)
+	mux.HandleFunc("POST /api/posts/{id}/restore", s.restore)
+	mux.HandleFunc("PUT /api/posts/{id}/status", s.setStatus)
+	mux.HandleFunc("PUT /api/posts/{id}/tags", s.setTags)
+	mux.HandleFunc("PUT /api/posts/{id}/like", s.like)
+	mux.HandleFunc("DELETE /api/posts/{id}/like", s.unlike)
+	mux.HandleFunc("GET /api/tags", s.listTagCounts)
+	mux.HandleFunc("GET /api/slugs/{slug}", s.getBySlug)
+	mux.HandleFunc("POST /api/series", s.createSeries)
+	mux.HandleFunc("GET /api/series/{id}", s.getSeries)
+	mux.HandleFunc("POST /api/series/{id}/posts", s.addSeriesPost)
+	mux.HandleFunc("PUT /api/series/{id}/posts", s.reorderSeries)
+	mux.HandleFunc("DELETE /api/series/{id}/posts/{post_id}", s.removeSeriesPost)
+	mux.HandleFunc("GET /api/posts/{id}/revisions", s.listRevisions)
+	mux.HandleFunc("GET /api/posts/{id}/revisions/diff", s.diffRevisions)
+	mux.HandleFunc("GET /api/posts/{id}/revisions/{revision}", s.getRevision)
+	mux.HandleFunc("POST /api/posts/{id}/revisions/{revision}/restore", s.restoreRevision)
+	return mux
+}
+
+// ServeHTTP implements http.Handler.
+func (s *service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// postContentJSON is the content a post is created or updated with.
+type postContentJSON struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	// Tags replace the tags of the post. Left out, they stay as they are.
+	Tags []string `json:"tags"`
+}
+
+// createPost is Create with tags, which the CreateRequest message has no
+// field for. Unlike Create, it saves a draft, which setStatus publishes.
+//
+//	POST /api/posts
+//	{"title": "...", "body": "...", "tags": ["go", "database"]}
+func (s *service) createPost(w http.ResponseWriter, r *http.Request) {
+	uid, err := httpapi.RequireUserID(r)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	var body postContentJSON
+	if err := httpapi.ReadJSON(r, &body); err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	result, err := s.create(r.Context(), uid, body.Title, body.Body, body.Tags, statusDraft)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	s.writePost(w, r, result.Post)
+}
+
+// updatePost is Update with tags, which the UpdateRequest message has no
+// field for. Like Update, it needs the current ETag of the post in If-Match.
+//
+//	PUT /api/posts/{id}
+//	{"title": "...", "body": "...", "tags": ["go"]}
+func (s *service) updatePost(w http.ResponseWriter, r *http.Request) {
+	uid, err := httpapi.RequireUserID(r)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	id, err := httpapi.PathInt64(r, "id")
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	version, err := parseETag(r.Header.Get("If-Match"))
+	if err != nil {
+		s.respond.Error(w, r, connect.NewError(connect.CodeFailedPrecondition, err))
+		return
+	}
+	var body postContentJSON
+	if err := httpapi.ReadJSON(r, &body); err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	result, err := s.update(r.Context(), uid, id, version, body.Title, body.Body, body.Tags)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	s.writePost(w, r, result.Post)
+}
+
+// writePost responds with post and its tags, and its version as the ETag.
+func (s *service) writePost(w http.ResponseWriter, r *http.Request, post *postgres.Post) {
+	posts, err := s.postsJSON(r.Context(), []postgres.Post{*post})
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(post.Version))
+	s.respond.JSON(w, r, posts[0])
+}
+
+type postJSON struct {
+	ID             int64              `json:"id"`
+	UserID         int64              `json:"user_id"`
+	Slug           string             `json:"slug"`
+	Author         *userJSON          `json:"author,omitempty"`
+	Title          string             `json:"title"`
+	Body           string             `json:"body"`
+	BodyHTML       string             `json:"body_html,omitempty"`
+	TOC            json.RawMessage    `json:"toc,omitempty"`
+	Excerpt        string             `json:"excerpt"`
+	WordCount      int32              `json:"word_count"`
+	ReadingMinutes int32              `json:"reading_minutes"`
+	Likes          int64              `json:"likes"`
+	Liked          bool               `json:"liked"` // by the viewer
+	Views          int64              `json:"views"`
+	Version        string             `json:"version"`
+	Status         string             `json:"status"`
+	Tags           []string           `json:"tags,omitempty"`
+	Series         *seriesNavJSON     `json:"series,omitempty"`
+	Related        []*relatedPostJSON `json:"related,omitempty"`
+	PublishedAt    *time.Time         `json:"published_at,omitempty"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+	DeletedAt      *time.Time         `json:"deleted_at,omitempty"`
+}
+
+func newPostJSON(p *postgres.Post) *postJSON {
+	return &postJSON{
+		ID:             p.ID,
+		UserID:         p.UserID,
+		Slug:           p.Slug,
+		Title:          p.Title,
+		Body:           p.Body,
+		Excerpt:        excerpt(p),
+		WordCount:      p.WordCount,
+		ReadingMinutes: p.ReadingMinutes,
+		Likes:          p.Likes,
+		Views:          p.Views,
+		Version:        etag(p.Version),
+		Status:         p.Status,
+		PublishedAt:    httpapi.TimePtr(p.PublishedAt),
+		CreatedAt:      p.CreatedAt.Time,
+		UpdatedAt:      p.UpdatedAt.Time,
+		DeletedAt:      httpapi.TimePtr(p.DeletedAt),
+	}
+}
+
+// userJSON is the public profile of a user, e.g. the author of a post.
+type userJSON struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	AvatarURL string `json:"avatar_url"`
+	AboutMe   string `json:"about_me"`
+}
+
+func newUserJSON(u *postgres.User) *userJSON {
+	return &userJSON{
+		ID:        u.ID,
+		Username:  u.Username,
+		AvatarURL: u.AvatarUrl,
+		AboutMe:   u.AboutMe,
+	}
+}
@@ -0,0 +1,128 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"connectrpc.com/connect"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/httpapi"
+	"github.com/gaesemo/blog-server/pkg/transaction"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type likeJSON struct {
+	Liked bool  `json:"liked"`
+	Likes int64 `json:"likes"`
+}
+
+// like makes the signed-in user like a post. Liking a post twice is a no-op.
+//
+//	PUT /api/posts/{id}/like
+func (s *service) like(w http.ResponseWriter, r *http.Request) {
+	s.setLiked(w, r, true)
+}
+
+// unlike takes the signed-in user's like back. Unliking a post that is not
+// liked is a no-op.
+//
+//	DELETE /api/posts/{id}/like
+func (s *service) unlike(w http.ResponseWriter, r *http.Request) {
+	s.setLiked(w, r, false)
+}
+
+func (s *service) setLiked(w http.ResponseWriter, r *http.Request, liked bool) {
+	uid, err := httpapi.RequireUserID(r)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	postID, err := httpapi.PathInt64(r, "id")
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+
+	tx := transaction.New[likeJSON](
+		s.db,
+		pgx.TxOptions{
+			// The like row decides whether the counter moves, and the counter
+			// is bumped in place, so concurrent likes by different users need
+			// not fail each other as they would under repeatable read.
+			IsoLevel:   pgx.ReadCommitted,
+			AccessMode: pgx.ReadWrite,
+		},
+		s.queries,
+	)
+	result, txErr := tx.Exec(r.Context(), func(c context.Context, q *postgres.Queries) (*likeJSON, error) {
+		post, err := q.GetPostById(c, postgres.GetPostByIdParams{
+			ID:       postID,
+			ViewerID: uid,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("post not found"))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("retrieving post: %v", err)
+		}
+
+		var changed int64
+		delta := int64(1)
+		if liked {
+			changed, err = q.LikePost(c, postgres.LikePostParams{
+				UserID:    uid,
+				PostID:    post.ID,
+				CreatedAt: pgtype.Timestamptz{Time: s.timeNow(), Valid: true},
+			})
+		} else {
+			changed, err = q.UnlikePost(c, postgres.UnlikePostParams{
+				UserID: uid,
+				PostID: post.ID,
+			})
+			delta = -1
+		}
+		if err != nil {
+			return nil, fmt.Errorf("saving like: %v", err)
+		}
+		if changed == 0 {
+			return &likeJSON{Liked: liked, Likes: post.Likes}, nil
+		}
+		likes, err := q.AddPostLikes(c, postgres.AddPostLikesParams{
+			Delta: delta,
+			ID:    post.ID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("counting like: %v", err)
+		}
+		return &likeJSON{Liked: liked, Likes: likes}, nil
+	})
+	if txErr != nil {
+		s.respond.Error(w, r, httpapi.AsConnectError(txErr, "saving like"))
+		return
+	}
+	s.respond.JSON(w, r, result)
+}
+
+// likedPosts returns which of the given posts the viewer likes. Anonymous
+// viewers like nothing.
+func (s *service) likedPosts(ctx context.Context, postIDs []int64) (map[int64]bool, error) {
+	liked := map[int64]bool{}
+	uid := httpapi.ViewerID(ctx)
+	if uid == 0 || len(postIDs) == 0 {
+		return liked, nil
+	}
+	ids, err := s.queries.ListLikedPostIds(ctx, postgres.ListLikedPostIdsParams{
+		UserID:  uid,
+		PostIds: postIDs,
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving likes: %v", err))
+	}
+	for _, id := range ids {
+		liked[id] = true
+	}
+	return liked, nil
+}
@@ -0,0 +1,129 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func setLike(t *testing.T, s *service, uid, postID int64, liked bool) likeJSON {
+	t.Helper()
+	method := http.MethodPut
+	if !liked {
+		method = http.MethodDelete
+	}
+	w := serve(t, s, asUser(uid), method, "/api/posts/"+strconv.FormatInt(postID, 10)+"/like", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var result likeJSON
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	return result
+}
+
+// likeCount returns the counter of a post and the number of likes it counts.
+func likeCount(t *testing.T, s *service, postID int64) (counter, rows int64) {
+	t.Helper()
+	err := s.db.QueryRow(context.Background(),
+		"SELECT likes, (SELECT count(*) FROM post_likes WHERE post_id = posts.id) FROM posts WHERE id = $1", postID,
+	).Scan(&counter, &rows)
+	require.NoError(t, err)
+	return counter, rows
+}
+
+func TestLikeIsIdempotent(t *testing.T) {
+	s, _ := newTestService(t)
+	author := createUser(t, s, "author")
+	reader := createUser(t, s, "reader")
+	post := createPublished(t, s, author, "liked", "text")
+
+	require.Equal(t, likeJSON{Liked: true, Likes: 1}, setLike(t, s, reader, post.ID, true))
+	require.Equal(t, likeJSON{Liked: true, Likes: 1}, setLike(t, s, reader, post.ID, true), "liking again changes nothing")
+	counter, rows := likeCount(t, s, post.ID)
+	require.Equal(t, int64(1), counter)
+	require.Equal(t, int64(1), rows)
+
+	require.Equal(t, likeJSON{Liked: false, Likes: 0}, setLike(t, s, reader, post.ID, false))
+	require.Equal(t, likeJSON{Liked: false, Likes: 0}, setLike(t, s, reader, post.ID, false), "unliking again changes nothing")
+	counter, rows = likeCount(t, s, post.ID)
+	require.Zero(t, counter)
+	require.Zero(t, rows)
+}
+
+func TestUnlikeWithoutLike(t *testing.T) {
+	s, _ := newTestService(t)
+	author := createUser(t, s, "author")
+	reader := createUser(t, s, "reader")
+	other := createUser(t, s, "other")
+	post := createPublished(t, s, author, "liked", "text")
+	setLike(t, s, other, post.ID, true)
+
+	require.Equal(t, likeJSON{Liked: false, Likes: 1}, setLike(t, s, reader, post.ID, false), "only the reader's own like can be taken back")
+	counter, rows := likeCount(t, s, post.ID)
+	require.Equal(t, int64(1), counter)
+	require.Equal(t, int64(1), rows)
+}
+
+func TestConcurrentLikesKeepCounter(t *testing.T) {
+	s, _ := newTestService(t)
+	author := createUser(t, s, "author")
+	post := createPublished(t, s, author, "popular", "text")
+	readers := make([]int64, 6)
+	for i := range readers {
+		readers[i] = createUser(t, s, "reader"+strconv.Itoa(i))
+	}
+
+	const likesEach = 3
+	codes := make(chan int, len(readers)*likesEach)
+	for _, uid := range readers {
+		for range likesEach {
+			go func() {
+				w := serve(t, s, asUser(uid), http.MethodPut, "/api/posts/"+strconv.FormatInt(post.ID, 10)+"/like", "")
+				codes <- w.Code
+			}()
+		}
+	}
+	for range len(readers) * likesEach {
+		require.Equal(t, http.StatusOK, <-codes)
+	}
+	counter, rows := likeCount(t, s, post.ID)
+	require.Equal(t, int64(len(readers)), rows)
+	require.Equal(t, rows, counter)
+}
+
+func TestLikedInPostResponses(t *testing.T) {
+	s, _ := newTestService(t)
+	author := createUser(t, s, "author")
+	reader := createUser(t, s, "reader")
+	post := createPublished(t, s, author, "liked", "text")
+	setLike(t, s, reader, post.ID, true)
+
+	for name, tc := range map[string]struct {
+		ctx   context.Context
+		liked bool
+	}{
+		"liker":     {asUser(reader), true},
+		"other":     {asUser(author), false},
+		"anonymous": {context.Background(), false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := serve(t, s, tc.ctx, http.MethodGet, "/api/posts/"+strconv.FormatInt(post.ID, 10), "")
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			var detail postJSON
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+			require.Equal(t, tc.liked, detail.Liked)
+			require.Equal(t, int64(1), detail.Likes)
+
+			w = serve(t, s, tc.ctx, http.MethodGet, "/api/posts", "")
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			var list struct {
+				Posts []postJSON `json:"posts"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+			require.Len(t, list.Posts, 1)
+			require.Equal(t, tc.liked, list.Posts[0].Liked)
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"connectrpc.com/connect"
+	typesv1 "github.com/gaesemo/blog-api/go/types/v1"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/cursor"
+	"github.com/gaesemo/blog-server/pkg/httpapi"
+	"github.com/gaesemo/blog-server/pkg/tag"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	defaultListPageSize = 10
+	maxListPageSize     = 50
+)
+
+type listParams struct {
+	cursor   *typesv1.Cursor
+	pageSize int32
+	tags     []string
+	matchAll bool
+}
+
+type listPage struct {
+	posts []postgres.Post
+	// next continues towards older posts and is nil on the last page.
+	next *typesv1.Cursor
+	// prev goes back towards newer posts and is nil on the first page.
+	prev *typesv1.Cursor
+}
+
+// listRecent returns a page of posts ordered by (updated_at, id), newest
+// first. One row more than the page size is fetched to learn whether the list
+// goes on in the direction of travel.
+func (s *service) listRecent(ctx context.Context, p listParams) (*listPage, error) {
+	k, err := cursor.ParseKeyset(p.cursor)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	var cursorTime pgtype.Timestamptz
+	if !k.Time.IsZero() {
+		cursorTime = pgtype.Timestamptz{Time: k.Time, Valid: true}
+	}
+
+	var rows []postgres.Post
+	if k.Backward {
+		rows, err = s.queries.ListNewerPosts(ctx, postgres.ListNewerPostsParams{
+			ViewerID:   httpapi.ViewerID(ctx),
+			Tags:       p.tags,
+			MatchAll:   p.matchAll,
+			CursorTime: cursorTime,
+			CursorID:   k.ID,
+			PageSize:   p.pageSize + 1,
+		})
+	} else {
+		rows, err = s.queries.ListRecentPosts(ctx, postgres.ListRecentPostsParams{
+			ViewerID:   httpapi.ViewerID(ctx),
+			Tags:       p.tags,
+			MatchAll:   p.matchAll,
+			CursorTime: cursorTime,
+			CursorID:   k.ID,
+			PageSize:   p.pageSize + 1,
+		})
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving posts: %v", err))
+	}
+
+	more := len(rows) > int(p.pageSize)
+	if more {
+		rows = rows[:p.pageSize]
+	}
+	page := &listPage{posts: rows}
+	if k.Backward {
+		// Newer posts come back nearest first; show them newest first.
+		slices.Reverse(rows)
+	}
+	if len(rows) == 0 {
+		return page, nil
+	}
+	first, last := &rows[0], &rows[len(rows)-1]
+	if k.Backward {
+		// We came back from older posts, so there is always a way forward.
+		page.next = keysetCursor(last, false)
+		if more {
+			page.prev = keysetCursor(first, true)
+		}
+		return page, nil
+	}
+	if more {
+		page.next = keysetCursor(last, false)
+	}
+	if !k.Time.IsZero() {
+		page.prev = keysetCursor(first, true)
+	}
+	return page, nil
+}
+
+func keysetCursor(p *postgres.Post, backward bool) *typesv1.Cursor {
+	return cursor.FromKeyset(cursor.Keyset{
+		Time:     p.UpdatedAt.Time,
+		ID:       p.ID,
+		Backward: backward,
+	})
+}
+
+// listPosts lists recent posts like List, optionally narrowed down to posts
+// carrying any (the default) or all of the given tags. Like List, it leaves
+// out bodies in favour of excerpts. Follow next for older
+// posts and prev for newer ones; has_next and has_prev tell whether either
+// way leads anywhere.
+//
+// sort=trending, top-week, top-month or top-all lists posts by ranking
+// instead, best first; next and prev then page down and up the ranking.
+// Rankings cannot be filtered by tag.
+//
+//	GET /api/posts?tag=go&tag=database&match=all&page_size=20&cursor=<opaque>
+//	GET /api/posts?sort=trending&page_size=20&cursor=<opaque>
+func (s *service) listPosts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	match := query.Get("match")
+	if match != "" && match != "any" && match != "all" {
+		s.respond.Error(w, r, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("match must be any or all")))
+		return
+	}
+	pageSize := int64(defaultListPageSize)
+	if v := query.Get("page_size"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || n < 1 {
+			s.respond.Error(w, r, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid page size %q", v)))
+			return
+		}
+		pageSize = min(n, maxListPageSize)
+	}
+
+	sort, err := parseSort(query.Get("sort"))
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	tags := tag.NormalizeAll(query["tag"])
+	if sort != sortRecent && len(tags) > 0 {
+		s.respond.Error(w, r, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("only posts sorted by %s can be filtered by tag", sortRecent)))
+		return
+	}
+
+	var page *listPage
+	if sort == sortRecent {
+		page, err = s.listRecent(r.Context(), listParams{
+			cursor:   httpapi.QueryCursor(r),
+			pageSize: int32(pageSize),
+			tags:     tags,
+			matchAll: match == "all",
+		})
+	} else {
+		page, err = s.listRanked(r.Context(), sort, httpapi.QueryCursor(r), int32(pageSize))
+	}
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	posts, err := s.postsJSON(r.Context(), page.posts)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	for _, p := range posts {
+		p.Body = ""
+	}
+	resp := struct {
+		Posts   []*postJSON `json:"posts"`
+		Next    string      `json:"next,omitempty"`
+		Prev    string      `json:"prev,omitempty"`
+		HasNext bool        `json:"has_next"`
+		HasPrev bool        `json:"has_prev"`
+	}{
+		Posts:   posts,
+		HasNext: page.next != nil,
+		HasPrev: page.prev != nil,
+	}
+	if page.next != nil {
+		resp.Next = string(page.next.Opaque)
+	}
+	if page.prev != nil {
+		resp.Prev = string(page.prev.Opaque)
+	}
+	s.respond.JSON(w, r, resp)
+}
@@ -0,0 +1,118 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"connectrpc.com/connect"
+	typesv1 "github.com/gaesemo/blog-api/go/types/v1"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/cursor"
+	"github.com/gaesemo/blog-server/pkg/transaction"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Orders posts can be listed in. Every order but sortRecent is a ranking
+// stored in post_rankings under the same name.
+const (
+	sortRecent   = "recent"
+	sortTrending = "trending"
+	sortTopWeek  = "top-week"
+	sortTopMonth = "top-month"
+	sortTopAll   = "top-all"
+)
+
+const (
+	// likeWeight is how many views a like is worth in the rankings.
+	likeWeight = 10
+	// trendingHalfLife is how long it takes for a view or like to count
+	// half as much towards trending.
+	trendingHalfLife = 2 * 24 * time.Hour
+	// dailyViewRetention covers the longest ranking window, 30 days, with a
+	// day to spare for the partial day at its start.
+	dailyViewRetention = 31 * 24 * time.Hour
+	// maxRankedOffset bounds how deep rankings can be paged, like search.
+	maxRankedOffset = 1000
+)
+
+// parseSort validates a list order. An empty order is sortRecent.
+func parseSort(v string) (string, error) {
+	switch v {
+	case "":
+		return sortRecent, nil
+	case sortRecent, sortTrending, sortTopWeek, sortTopMonth, sortTopAll:
+		return v, nil
+	}
+	return "", connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("sort must be one of %s, %s, %s, %s or %s", sortRecent, sortTrending, sortTopWeek, sortTopMonth, sortTopAll))
+}
+
+// RefreshRankings implements Service.
+//
+// Trending decays with time even when nothing happens, so the rankings are
+// recomputed on every call.
+func (s *service) RefreshRankings(ctx context.Context) error {
+	now := s.timeNow()
+	tx := transaction.New[int64](
+		s.db,
+		pgx.TxOptions{
+			IsoLevel:   pgx.RepeatableRead,
+			AccessMode: pgx.ReadWrite,
+		},
+		s.queries,
+	)
+	n, txErr := tx.Exec(ctx, func(c context.Context, q *postgres.Queries) (*int64, error) {
+		err := q.PurgeDailyPostViews(c, pgtype.Date{Time: now.Add(-dailyViewRetention).UTC(), Valid: true})
+		if err != nil {
+			return nil, fmt.Errorf("purging daily views: %v", err)
+		}
+		if err := q.ClearPostRankings(c); err != nil {
+			return nil, fmt.Errorf("clearing rankings: %v", err)
+		}
+		n, err := q.ComputePostRankings(c, postgres.ComputePostRankingsParams{
+			Now:             pgtype.Timestamptz{Time: now, Valid: true},
+			LikeWeight:      likeWeight,
+			HalfLifeSeconds: trendingHalfLife.Seconds(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("computing rankings: %v", err)
+		}
+		return &n, nil
+	})
+	if txErr != nil {
+		return txErr
+	}
+	s.logger.InfoContext(ctx, "refreshed post rankings", slog.Int64("scores", *n))
+	return nil
+}
+
+// listRanked returns a page of published posts in the order of a ranking,
+// best first. Rankings are paged by offset, as they are reshuffled on every
+// refresh and have no stable key to resume from.
+func (s *service) listRanked(ctx context.Context, kind string, c *typesv1.Cursor, pageSize int32) (*listPage, error) {
+	offset := cursor.MustParseInt64(c)
+	if offset < 0 || offset > maxRankedOffset {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("cursor out of range"))
+	}
+	rows, err := s.queries.ListRankedPosts(ctx, postgres.ListRankedPostsParams{
+		Kind:       kind,
+		PageSize:   pageSize + 1,
+		PageOffset: int32(offset),
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving posts: %v", err))
+	}
+	page := &listPage{posts: rows}
+	if len(rows) > int(pageSize) {
+		page.posts = rows[:pageSize]
+		if offset+int64(pageSize) <= maxRankedOffset {
+			page.next = cursor.FromInt64(offset + int64(pageSize))
+		}
+	}
+	if offset > 0 {
+		page.prev = cursor.FromInt64(max(0, offset-int64(pageSize)))
+	}
+	return page, nil
+}
@@ -0,0 +1,155 @@
+package v1
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	postv1 "github.com/gaesemo/blog-api/go/service/post/v1"
+	typesv1 "github.com/gaesemo/blog-api/go/types/v1"
+	"github.com/stretchr/testify/require"
+)
+
+// addViews records views of a post on the day of at, and in its lifetime
+// counter.
+func addViews(t *testing.T, s *service, postID int64, at time.Time, views int64) {
+	t.Helper()
+	ctx := context.Background()
+	_, err := s.db.Exec(ctx, "INSERT INTO post_daily_views (post_id, day, views) VALUES ($1, $2::timestamptz::date, $3)", postID, at, views)
+	require.NoError(t, err)
+	_, err = s.db.Exec(ctx, "UPDATE posts SET views = views + $2 WHERE id = $1", postID, views)
+	require.NoError(t, err)
+}
+
+// addLike records a like of a post at the given time.
+func addLike(t *testing.T, s *service, uid, postID int64, at time.Time) {
+	t.Helper()
+	ctx := context.Background()
+	_, err := s.db.Exec(ctx, "INSERT INTO post_likes (user_id, post_id, created_at) VALUES ($1, $2, $3)", uid, postID, at)
+	require.NoError(t, err)
+	_, err = s.db.Exec(ctx, "UPDATE posts SET likes = likes + 1 WHERE id = $1", postID)
+	require.NoError(t, err)
+}
+
+func rankedIDs(t *testing.T, s *service, kind string) []int64 {
+	t.Helper()
+	page, err := s.listRanked(context.Background(), kind, &typesv1.Cursor{}, maxListPageSize)
+	require.NoError(t, err)
+	ids := []int64{}
+	for _, p := range page.posts {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func rankingScore(t *testing.T, s *service, kind string, postID int64) float64 {
+	t.Helper()
+	var score float64
+	err := s.db.QueryRow(context.Background(), "SELECT score FROM post_rankings WHERE kind = $1 AND post_id = $2", kind, postID).Scan(&score)
+	require.NoError(t, err)
+	return score
+}
+
+func TestRefreshRankings(t *testing.T) {
+	s, c := newTestService(t)
+	ctx := context.Background()
+	uid := createUser(t, s, "author")
+	reader := createUser(t, s, "reader")
+	day := 24 * time.Hour
+
+	ancient := createPublished(t, s, uid, "ancient", "text")
+	old := createPublished(t, s, uid, "old", "text")
+	week := createPublished(t, s, uid, "week", "text")
+	fresh := createPublished(t, s, uid, "fresh", "text")
+	liked := createPublished(t, s, uid, "liked", "text")
+	draft, err := s.create(ctx, uid, "draft", "text", nil, statusDraft)
+	require.NoError(t, err)
+
+	_, err = s.db.Exec(ctx, "UPDATE posts SET views = 1000 WHERE id = $1", ancient.ID)
+	require.NoError(t, err)
+	addViews(t, s, old.ID, c.now.Add(-20*day), 100)
+	addViews(t, s, week.ID, c.now.Add(-3*day), 30)
+	addViews(t, s, fresh.ID, c.now, 20)
+	addLike(t, s, reader, liked.ID, c.now.Add(-time.Hour))
+	addViews(t, s, draft.Post.ID, c.now, 5000)
+
+	require.NoError(t, s.RefreshRankings(ctx))
+	// Views count from the start of their day: trending weighs the 20 views
+	// of today at half a day old above the like an hour old, and that above
+	// the 30 views three and a half days old.
+	require.Equal(t, []int64{fresh.ID, liked.ID, week.ID, old.ID, ancient.ID}, rankedIDs(t, s, sortTrending))
+	require.Equal(t, []int64{week.ID, fresh.ID, liked.ID}, rankedIDs(t, s, sortTopWeek)[:3])
+	require.Equal(t, []int64{old.ID, week.ID, fresh.ID, liked.ID, ancient.ID}, rankedIDs(t, s, sortTopMonth))
+	require.Equal(t, []int64{ancient.ID, old.ID, week.ID, fresh.ID, liked.ID}, rankedIDs(t, s, sortTopAll))
+	require.InDelta(t, 20*math.Pow(0.5, 0.25), rankingScore(t, s, sortTrending, fresh.ID), 1e-6, "20 views a quarter of a half life old")
+	require.Zero(t, rankingScore(t, s, sortTopWeek, old.ID))
+
+	// A half life later, trending has halved without any new activity.
+	before := rankingScore(t, s, sortTrending, fresh.ID)
+	c.now = c.now.Add(trendingHalfLife)
+	require.NoError(t, s.RefreshRankings(ctx))
+	require.InDelta(t, before/2, rankingScore(t, s, sortTrending, fresh.ID), 1e-6)
+	require.Equal(t, float64(30), rankingScore(t, s, sortTopWeek, week.ID), "the views of five days ago are still this week's")
+
+	// A week on, the views of the week post leave the week but not the month.
+	c.now = c.now.Add(3 * day)
+	require.NoError(t, s.RefreshRankings(ctx))
+	require.Zero(t, rankingScore(t, s, sortTopWeek, week.ID))
+	require.Equal(t, float64(30), rankingScore(t, s, sortTopMonth, week.ID))
+	require.Equal(t, float64(100), rankingScore(t, s, sortTopMonth, old.ID))
+
+	// Eleven days on, those of the old post, by then 31 days old, leave the
+	// month.
+	c.now = c.now.Add(6 * day)
+	require.NoError(t, s.RefreshRankings(ctx))
+	require.Zero(t, rankingScore(t, s, sortTopMonth, old.ID))
+	require.Equal(t, float64(30), rankingScore(t, s, sortTopMonth, week.ID))
+	require.Equal(t, float64(1000), rankingScore(t, s, sortTopAll, ancient.ID))
+	require.Equal(t, float64(100), rankingScore(t, s, sortTopAll, old.ID), "top-all never forgets")
+	require.Equal(t, float64(likeWeight), rankingScore(t, s, sortTopAll, liked.ID))
+}
+
+func TestListRankedPages(t *testing.T) {
+	s, c := newTestService(t)
+	ctx := context.Background()
+	uid := createUser(t, s, "author")
+	var ids []int64
+	for i := range 5 {
+		p := createPublished(t, s, uid, "post", "text")
+		addViews(t, s, p.ID, c.now, int64(10-i))
+		ids = append(ids, p.ID)
+	}
+	require.NoError(t, s.RefreshRankings(ctx))
+
+	first, err := s.listRanked(ctx, sortTopWeek, &typesv1.Cursor{}, 2)
+	require.NoError(t, err)
+	require.Nil(t, first.prev)
+	require.NotNil(t, first.next)
+	second, err := s.listRanked(ctx, sortTopWeek, first.next, 2)
+	require.NoError(t, err)
+	require.NotNil(t, second.prev)
+	last, err := s.listRanked(ctx, sortTopWeek, second.next, 2)
+	require.NoError(t, err)
+	require.Nil(t, last.next)
+	var got []int64
+	for _, page := range []*listPage{first, second, last} {
+		for _, p := range page.posts {
+			got = append(got, p.ID)
+		}
+	}
+	require.Equal(t, ids, got)
+
+	// The RPC List picks the ranking from the Sort header.
+	req := connect.NewRequest(&postv1.ListRequest{})
+	req.Header().Set("Sort", sortTopWeek)
+	resp, err := s.List(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, resp.Msg.Posts, len(ids))
+	require.Equal(t, ids[0], resp.Msg.Posts[0].Id)
+
+	req.Header().Set("Sort", "best")
+	_, err = s.List(ctx, req)
+	require.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+}
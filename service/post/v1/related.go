@@ -0,0 +1,138 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+
+	"connectrpc.com/connect"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/transaction"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	defaultRelatedPosts = 5
+	// minRelatedScore keeps posts that have nothing in common out of the
+	// recommendations. One shared tag, a shared author or a fair text
+	// similarity is enough; see ComputeRelatedPosts for the scoring.
+	minRelatedScore = 0.3
+	// similarityWeight is what the trigram similarity of two posts is
+	// multiplied by in their score; see ComputeRelatedPosts.
+	similarityWeight = 2
+)
+
+// WithRelatedPosts sets how many related posts are kept for each post.
+// Non-positive values keep the default of five.
+func WithRelatedPosts(n int) Option {
+	return func(svc *service) {
+		if n > 0 {
+			svc.relatedPosts = n
+		}
+	}
+}
+
+type relatedPostJSON struct {
+	ID      int64  `json:"id"`
+	Slug    string `json:"slug"`
+	Title   string `json:"title"`
+	Excerpt string `json:"excerpt"`
+}
+
+// RefreshRelatedPosts implements Service.
+//
+// Only the posts that may be affected by the posts changed since the last
+// refresh are recomputed, each against its candidates only; the first
+// refresh computes every published post.
+func (s *service) RefreshRelatedPosts(ctx context.Context) error {
+	s.relatedMu.Lock()
+	all, changed := s.relatedAll, slices.Collect(maps.Keys(s.relatedChanged))
+	s.relatedAll, s.relatedChanged = false, map[int64]bool{}
+	s.relatedMu.Unlock()
+	if !all && len(changed) == 0 {
+		return nil
+	}
+
+	tx := transaction.New[int64](
+		s.db,
+		pgx.TxOptions{
+			IsoLevel:   pgx.RepeatableRead,
+			AccessMode: pgx.ReadWrite,
+		},
+		s.queries,
+	)
+	n, txErr := tx.Exec(ctx, func(c context.Context, q *postgres.Queries) (*int64, error) {
+		// A post with nothing else in common has to be this similar to
+		// reach minRelatedScore, so less similar posts need not be
+		// candidates.
+		threshold := strconv.FormatFloat(minRelatedScore/similarityWeight, 'f', -1, 64)
+		if err := q.SetSimilarityThreshold(c, threshold); err != nil {
+			return nil, fmt.Errorf("setting similarity threshold: %v", err)
+		}
+		var ids []int64
+		var err error
+		if all {
+			if err := q.ClearRelatedPosts(c); err != nil {
+				return nil, fmt.Errorf("clearing related posts: %v", err)
+			}
+			ids, err = q.ListPublishedPostIds(c)
+			if err != nil {
+				return nil, fmt.Errorf("listing published posts: %v", err)
+			}
+		} else {
+			ids, err = q.ListRelatedPostsAffectedBy(c, changed)
+			if err != nil {
+				return nil, fmt.Errorf("listing affected posts: %v", err)
+			}
+			if err := q.DeleteRelatedPosts(c, ids); err != nil {
+				return nil, fmt.Errorf("deleting related posts: %v", err)
+			}
+		}
+		n, err := q.ComputeRelatedPosts(c, postgres.ComputeRelatedPostsParams{
+			ComputedAt: pgtype.Timestamptz{Time: s.timeNow(), Valid: true},
+			PostIds:    ids,
+			MinScore:   minRelatedScore,
+			PerPost:    int32(s.relatedPosts),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("computing related posts: %v", err)
+		}
+		return &n, nil
+	})
+	if txErr != nil {
+		s.relatedMu.Lock()
+		s.relatedAll = s.relatedAll || all
+		for _, id := range changed {
+			s.relatedChanged[id] = true
+		}
+		s.relatedMu.Unlock()
+		return txErr
+	}
+	s.logger.InfoContext(ctx, "refreshed related posts", slog.Bool("all", all), slog.Int("changed", len(changed)), slog.Int64("pairs", *n))
+	return nil
+}
+
+// related returns the precomputed related posts of a post, best first.
+func (s *service) related(ctx context.Context, postID int64) ([]*relatedPostJSON, error) {
+	rows, err := s.queries.ListRelatedPosts(ctx, postgres.ListRelatedPostsParams{
+		PostID:   postID,
+		PageSize: int32(s.relatedPosts),
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving related posts: %v", err))
+	}
+	related := make([]*relatedPostJSON, 0, len(rows))
+	for _, r := range rows {
+		related = append(related, &relatedPostJSON{
+			ID:      r.ID,
+			Slug:    r.Slug,
+			Title:   r.Title,
+			Excerpt: r.Excerpt,
+		})
+	}
+	return related, nil
+}
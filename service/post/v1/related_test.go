@@ -0,0 +1,53 @@
+package v1
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/stretchr/testify/require"
+)
+
+// createPublished creates a published post by uid and returns it.
+func createPublished(t *testing.T, s *service, uid int64, title, body string, tags ...string) *postgres.Post {
+	t.Helper()
+	ctx := context.Background()
+	result, err := s.create(ctx, uid, title, body, tags, statusPublished)
+	require.NoError(t, err)
+	return result.Post
+}
+
+func relatedIDs(t *testing.T, s *service, postID int64) []int64 {
+	t.Helper()
+	related, err := s.related(context.Background(), postID)
+	require.NoError(t, err)
+	ids := []int64{}
+	for _, r := range related {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func TestRefreshRelatedPosts(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	carol := createUser(t, s, "carol")
+
+	goPost := createPublished(t, s, alice, "Concurrency in Go", "goroutines and channels", "go")
+	goTagged := createPublished(t, s, bob, "Profiling services", "pprof and flame graphs", "go")
+	similar := createPublished(t, s, carol, "Concurrency in Go, again", "goroutines and channels, again")
+	other := createPublished(t, s, carol, "Sourdough", "flour, water and salt")
+
+	require.NoError(t, s.RefreshRelatedPosts(ctx))
+	require.ElementsMatch(t, []int64{goTagged.ID, similar.ID}, relatedIDs(t, s, goPost.ID),
+		"shared tags and similar text make posts related")
+	require.Equal(t, []int64{similar.ID}, relatedIDs(t, s, other.ID), "so does the same author")
+
+	_, err := s.update(ctx, carol, other.ID, other.Version, other.Title, other.Body, []string{"go"})
+	require.NoError(t, err)
+	require.NoError(t, s.RefreshRelatedPosts(ctx))
+	require.Contains(t, relatedIDs(t, s, goPost.ID), other.ID,
+		"posts that become related to a changed post are recomputed too")
+}
@@ -0,0 +1,134 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"connectrpc.com/connect"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/httpapi"
+	"github.com/gaesemo/blog-server/pkg/markdown"
+	"github.com/gaesemo/blog-server/pkg/textstat"
+	"github.com/jackc/pgx/v5"
+)
+
+// rendered is what is derived from a Markdown body and stored next to it.
+type rendered struct {
+	HTML  string
+	TOC   []byte
+	Stats textstat.Stats
+}
+
+// render converts a Markdown body into sanitised HTML, a table of contents,
+// an excerpt and a reading time.
+func render(body string) (*rendered, error) {
+	doc, err := markdown.Render([]byte(body))
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("rendering body: %v", err))
+	}
+	toc, err := json.Marshal(doc.TOC)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("encoding table of contents: %v", err))
+	}
+	return &rendered{
+		HTML:  doc.HTML,
+		TOC:   toc,
+		Stats: textstat.FromHTML(doc.HTML),
+	}, nil
+}
+
+// withRendered adds the rendered body of p to post. Posts saved before bodies
+// were rendered on write are rendered on the fly.
+func withRendered(post *postJSON, p *postgres.Post) error {
+	html, toc := p.BodyHtml, p.Toc
+	if html == "" && p.Body != "" {
+		r, err := render(p.Body)
+		if err != nil {
+			return err
+		}
+		html, toc = r.HTML, r.TOC
+	}
+	post.BodyHTML = html
+	post.TOC = json.RawMessage(toc)
+	return nil
+}
+
+// excerpt returns the excerpt of p. Posts saved before excerpts were stored
+// get one from their rendered body, or failing that from the Markdown source.
+func excerpt(p *postgres.Post) string {
+	if p.Excerpt != "" {
+		return p.Excerpt
+	}
+	if p.BodyHtml != "" {
+		return textstat.FromHTML(p.BodyHtml).Excerpt
+	}
+	return textstat.Excerpt(strings.Join(strings.Fields(p.Body), " "), textstat.ExcerptLength)
+}
+
+// plainText returns the text of the body of p without Markdown syntax. Posts
+// saved before bodies were rendered fall back to the Markdown source.
+func plainText(p *postgres.Post) string {
+	if p.BodyHtml != "" {
+		return textstat.PlainText(p.BodyHtml)
+	}
+	return p.Body
+}
+
+// detail returns a post like Detail, together with its body rendered as HTML,
+// its table of contents, its place in a series with the previous and next
+// posts, and related posts, which the Post message has no fields for.
+//
+//	GET /api/posts/{id}
+func (s *service) detail(w http.ResponseWriter, r *http.Request) {
+	id, err := httpapi.PathInt64(r, "id")
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	post, err := s.queries.GetPostById(r.Context(), postgres.GetPostByIdParams{
+		ID:       id,
+		ViewerID: httpapi.ViewerID(r.Context()),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		s.respond.Error(w, r, connect.NewError(connect.CodeNotFound, fmt.Errorf("post not found")))
+		return
+	}
+	if err != nil {
+		s.respond.Error(w, r, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving post: %v", err)))
+		return
+	}
+	detail, err := s.detailOf(w, r, &post)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	s.respond.JSON(w, r, detail)
+}
+
+// detailOf readies the answer to r, a request for the detail of post, which
+// every handler serving posts to read uses. Besides the post, it returns its
+// rendered body, table of contents, place in a series and related posts. It
+// counts the view and sets the ETag on w; the caller writes the body. Errors
+// are connect errors.
+func (s *service) detailOf(w http.ResponseWriter, r *http.Request, post *postgres.Post) (*postJSON, error) {
+	posts, err := s.postsJSON(r.Context(), []postgres.Post{*post})
+	if err != nil {
+		return nil, err
+	}
+	detail := posts[0]
+	if err := withRendered(detail, post); err != nil {
+		return nil, err
+	}
+	if detail.Series, err = s.seriesNav(r.Context(), post.ID); err != nil {
+		return nil, err
+	}
+	if detail.Related, err = s.related(r.Context(), post.ID); err != nil {
+		return nil, err
+	}
+	s.recordView(r.Context(), post, r.RemoteAddr, r.UserAgent())
+	w.Header().Set("ETag", etag(post.Version))
+	return detail, nil
+}
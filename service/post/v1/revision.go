@@ -0,0 +1,231 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/cursor"
+	"github.com/gaesemo/blog-server/pkg/diff"
+	"github.com/gaesemo/blog-server/pkg/httpapi"
+	"github.com/jackc/pgx/v5"
+)
+
+const revisionPageSize = 20
+
+type revisionJSON struct {
+	ID        int64     `json:"id"`
+	PostID    int64     `json:"post_id"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body,omitempty"`
+	EditorID  int64     `json:"editor_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newRevisionJSON(r *postgres.PostRevision) *revisionJSON {
+	return &revisionJSON{
+		ID:        r.ID,
+		PostID:    r.PostID,
+		Title:     r.Title,
+		Body:      r.Body,
+		EditorID:  r.EditorID,
+		CreatedAt: r.CreatedAt.Time,
+	}
+}
+
+// listRevisions lists earlier versions of a post, newest first. Bodies are
+// left out; fetch a single revision to read one.
+//
+//	GET /api/posts/{id}/revisions?cursor=<opaque>
+func (s *service) listRevisions(w http.ResponseWriter, r *http.Request) {
+	post, err := s.authoredPost(r)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	rows, err := s.queries.ListPostRevisions(r.Context(), postgres.ListPostRevisionsParams{
+		PostID:   post.ID,
+		Cursor:   cursor.MustParseInt64(httpapi.QueryCursor(r)),
+		PageSize: revisionPageSize,
+	})
+	if err != nil {
+		s.respond.Error(w, r, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving revisions: %v", err)))
+		return
+	}
+
+	revisions := []*revisionJSON{}
+	for _, rev := range rows {
+		rev.Body = ""
+		revisions = append(revisions, newRevisionJSON(&rev))
+	}
+	var next string
+	if len(rows) == revisionPageSize {
+		next = string(cursor.FromInt64(rows[len(rows)-1].ID).Opaque)
+	}
+	s.respond.JSON(w, r, struct {
+		Revisions []*revisionJSON `json:"revisions"`
+		Next      string          `json:"next,omitempty"`
+	}{
+		Revisions: revisions,
+		Next:      next,
+	})
+}
+
+// getRevision returns a single revision including its body.
+//
+//	GET /api/posts/{id}/revisions/{revision}
+func (s *service) getRevision(w http.ResponseWriter, r *http.Request) {
+	post, err := s.authoredPost(r)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	revisionID, err := httpapi.PathInt64(r, "revision")
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	rev, err := s.revision(r.Context(), post.ID, revisionID)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	s.respond.JSON(w, r, newRevisionJSON(rev))
+}
+
+// diffRevisions compares two revisions line by line. Leaving out either side
+// compares against the current content of the post.
+//
+//	GET /api/posts/{id}/revisions/diff?from=<revision>&to=<revision>
+func (s *service) diffRevisions(w http.ResponseWriter, r *http.Request) {
+	post, err := s.authoredPost(r)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	current := &postgres.PostRevision{
+		PostID:    post.ID,
+		Title:     post.Title,
+		Body:      post.Body,
+		EditorID:  post.UserID,
+		CreatedAt: post.UpdatedAt,
+	}
+	side := func(param string) (*postgres.PostRevision, error) {
+		v := r.URL.Query().Get(param)
+		if v == "" {
+			return current, nil
+		}
+		revisionID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid %s %q", param, v))
+		}
+		return s.revision(r.Context(), post.ID, revisionID)
+	}
+	from, err := side("from")
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	to, err := side("to")
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	s.respond.JSON(w, r, struct {
+		From  int64       `json:"from,omitempty"`
+		To    int64       `json:"to,omitempty"`
+		Title []diff.Line `json:"title"`
+		Body  []diff.Line `json:"body"`
+	}{
+		From:  from.ID,
+		To:    to.ID,
+		Title: diff.Lines(from.Title, to.Title),
+		Body:  diff.Lines(from.Body, to.Body),
+	})
+}
+
+// restoreRevision brings back the content of an old revision as a new update,
+// so the content it replaces becomes a revision itself. Like Update, it needs
+// the current ETag of the post in If-Match.
+//
+//	POST /api/posts/{id}/revisions/{revision}/restore
+func (s *service) restoreRevision(w http.ResponseWriter, r *http.Request) {
+	uid, err := httpapi.RequireUserID(r)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	postID, err := httpapi.PathInt64(r, "id")
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	revisionID, err := httpapi.PathInt64(r, "revision")
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	version, err := parseETag(r.Header.Get("If-Match"))
+	if err != nil {
+		s.respond.Error(w, r, connect.NewError(connect.CodeFailedPrecondition, err))
+		return
+	}
+	rev, err := s.revision(r.Context(), postID, revisionID)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	result, err := s.update(r.Context(), uid, postID, version, rev.Title, rev.Body, nil)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(result.Post.Version))
+	s.respond.JSON(w, r, newPostJSON(result.Post))
+}
+
+func (s *service) revision(ctx context.Context, postID, revisionID int64) (*postgres.PostRevision, error) {
+	rev, err := s.queries.GetPostRevision(ctx, postgres.GetPostRevisionParams{
+		PostID: postID,
+		ID:     revisionID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("revision not found"))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving revision: %v", err))
+	}
+	return &rev, nil
+}
+
+// authoredPost loads the post named by the {id} path segment and makes sure
+// the caller wrote it.
+func (s *service) authoredPost(r *http.Request) (*postgres.Post, error) {
+	uid, err := httpapi.RequireUserID(r)
+	if err != nil {
+		return nil, err
+	}
+	id, err := httpapi.PathInt64(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	post, err := s.queries.GetPostById(r.Context(), postgres.GetPostByIdParams{
+		ID:       id,
+		ViewerID: uid,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("post not found"))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving post: %v", err))
+	}
+	if post.UserID != uid {
+		return nil, connect.NewError(connect.CodePermissionDenied, fmt.Errorf("not the author of the post"))
+	}
+	return &post, nil
+}
@@ -0,0 +1,101 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"connectrpc.com/connect"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/cursor"
+	"github.com/gaesemo/blog-server/pkg/httpapi"
+	"github.com/gaesemo/blog-server/pkg/snippet"
+)
+
+const (
+	searchPageSize   = 10
+	maxSearchTerms   = 8
+	maxSearchOffset  = 1000
+	searchSnippetLen = 160
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+type searchResultJSON struct {
+	Post    *postJSON `json:"post"`
+	Rank    float64   `json:"rank"`
+	Title   string    `json:"title"`   // HTML with matches in <mark>
+	Snippet string    `json:"snippet"` // HTML with matches in <mark>
+}
+
+// search finds published posts by title and body. Whole words are ranked with
+// full-text search; every term also matches as a substring, so Korean words
+// are found regardless of the particles attached to them. Snippets are cut
+// from the text of the rendered body, without Markdown syntax. One result
+// more than the page size is fetched to learn whether there is a next page.
+//
+//	GET /api/posts/search?q=<query>&cursor=<opaque>
+func (s *service) search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		s.respond.Error(w, r, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("search query required")))
+		return
+	}
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	}
+	patterns := make([]string, 0, len(terms))
+	for _, t := range terms {
+		patterns = append(patterns, "%"+likeEscaper.Replace(t)+"%")
+	}
+	offset := cursor.MustParseInt64(httpapi.QueryCursor(r))
+	if offset < 0 || offset > maxSearchOffset {
+		s.respond.Error(w, r, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("cursor out of range")))
+		return
+	}
+
+	rows, err := s.queries.SearchPosts(r.Context(), postgres.SearchPostsParams{
+		Query:      strings.Join(terms, " "),
+		Patterns:   patterns,
+		PageSize:   searchPageSize + 1,
+		PageOffset: int32(offset),
+	})
+	if err != nil {
+		s.respond.Error(w, r, connect.NewError(connect.CodeInternal, fmt.Errorf("searching posts: %v", err)))
+		return
+	}
+
+	var next string
+	if len(rows) > searchPageSize {
+		rows = rows[:searchPageSize]
+		next = string(cursor.FromInt64(offset + searchPageSize).Opaque)
+	}
+	found := make([]postgres.Post, 0, len(rows))
+	for _, row := range rows {
+		found = append(found, row.Post)
+	}
+	posts, err := s.postsJSON(r.Context(), found)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	results := []*searchResultJSON{}
+	for i, row := range rows {
+		post := posts[i]
+		post.Body = ""
+		results = append(results, &searchResultJSON{
+			Post:    post,
+			Rank:    row.Rank,
+			Title:   snippet.Highlight(row.Post.Title, terms, 0),
+			Snippet: snippet.Highlight(plainText(&row.Post), terms, searchSnippetLen),
+		})
+	}
+	s.respond.JSON(w, r, struct {
+		Results []*searchResultJSON `json:"results"`
+		Next    string              `json:"next,omitempty"`
+	}{
+		Results: results,
+		Next:    next,
+	})
+}
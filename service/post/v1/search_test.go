@@ -0,0 +1,103 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type searchPage struct {
+	Results []searchResultJSON `json:"results"`
+	Next    string             `json:"next"`
+}
+
+func searchPosts(t *testing.T, s *service, q, cur string) searchPage {
+	t.Helper()
+	w := serve(t, s, context.Background(), http.MethodGet, "/api/posts/search?"+url.Values{"q": {q}, "cursor": {cur}}.Encode(), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var page searchPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	return page
+}
+
+func resultIDs(page searchPage) []int64 {
+	ids := []int64{}
+	for _, r := range page.Results {
+		ids = append(ids, r.Post.ID)
+	}
+	return ids
+}
+
+func TestSearchRanksTitleMatchesFirst(t *testing.T) {
+	s, _ := newTestService(t)
+	uid := createUser(t, s, "author")
+	inBody := createPublished(t, s, uid, "Notes", "Tuning postgres for small servers.")
+	inTitle := createPublished(t, s, uid, "Postgres tuning", "Notes for small servers.")
+	createPublished(t, s, uid, "Unrelated", "Nothing to see here.")
+
+	page := searchPosts(t, s, "postgres", "")
+	require.Equal(t, []int64{inTitle.ID, inBody.ID}, resultIDs(page))
+	require.Contains(t, page.Results[0].Title, "<mark>Postgres</mark>")
+}
+
+func TestSearchMatchesKoreanWithParticles(t *testing.T) {
+	s, _ := newTestService(t)
+	uid := createUser(t, s, "author")
+	post := createPublished(t, s, uid, "첫 글", "드디어 블로그를 시작했습니다.")
+	createPublished(t, s, uid, "다른 글", "오늘은 날씨가 좋습니다.")
+
+	page := searchPosts(t, s, "블로그", "")
+	require.Equal(t, []int64{post.ID}, resultIDs(page), "a word is found with a particle attached")
+	require.Contains(t, page.Results[0].Snippet, "<mark>블로그</mark>를")
+}
+
+func TestSearchSnippetsArePlainText(t *testing.T) {
+	s, _ := newTestService(t)
+	uid := createUser(t, s, "author")
+	createPublished(t, s, uid, "Links", "Read **the manual** at [the docs](https://example.com/manual).\n\n```go\nfmt.Println(\"manual\")\n```")
+
+	page := searchPosts(t, s, "manual", "")
+	require.Len(t, page.Results, 1)
+	snippet := page.Results[0].Snippet
+	require.Contains(t, snippet, "<mark>manual</mark>")
+	for _, markdown := range []string{"**", "](", "https://example.com", "```"} {
+		require.NotContains(t, snippet, markdown)
+	}
+}
+
+func TestSearchLeavesOutDrafts(t *testing.T) {
+	s, _ := newTestService(t)
+	uid := createUser(t, s, "author")
+	_, err := s.create(asUser(uid), uid, "Draft about postgres", "text", nil, statusDraft)
+	require.NoError(t, err)
+
+	require.Empty(t, searchPosts(t, s, "postgres", "").Results)
+}
+
+func TestSearchPages(t *testing.T) {
+	s, _ := newTestService(t)
+	uid := createUser(t, s, "author")
+	for i := range searchPageSize {
+		createPublished(t, s, uid, "Post "+strconv.Itoa(i), "about paging")
+	}
+
+	page := searchPosts(t, s, "paging", "")
+	require.Len(t, page.Results, searchPageSize)
+	require.Empty(t, page.Next, "an exactly full page is the last one")
+
+	createPublished(t, s, uid, "One more", "about paging")
+	page = searchPosts(t, s, "paging", "")
+	require.Len(t, page.Results, searchPageSize)
+	require.NotEmpty(t, page.Next)
+	seen := resultIDs(page)
+
+	page = searchPosts(t, s, "paging", page.Next)
+	require.Len(t, page.Results, 1)
+	require.Empty(t, page.Next)
+	require.NotContains(t, seen, page.Results[0].Post.ID)
+}
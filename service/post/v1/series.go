@@ -0,0 +1,350 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"connectrpc.com/connect"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/httpapi"
+	"github.com/gaesemo/blog-server/pkg/transaction"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	maxSeriesTitleLength       = 255
+	maxSeriesDescriptionLength = 1000
+)
+
+type seriesJSON struct {
+	ID          int64       `json:"id"`
+	UserID      int64       `json:"user_id"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Posts       []*postJSON `json:"posts"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// seriesNavJSON places a post within its series.
+type seriesNavJSON struct {
+	ID       int64           `json:"id"`
+	Title    string          `json:"title"`
+	Position int             `json:"position"` // 1-based
+	Total    int             `json:"total"`
+	Prev     *seriesLinkJSON `json:"prev,omitempty"`
+	Next     *seriesLinkJSON `json:"next,omitempty"`
+}
+
+type seriesLinkJSON struct {
+	ID    int64  `json:"id"`
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+}
+
+func newSeriesLinkJSON(p *postgres.Post) *seriesLinkJSON {
+	return &seriesLinkJSON{
+		ID:    p.ID,
+		Slug:  p.Slug,
+		Title: p.Title,
+	}
+}
+
+// createSeries creates an empty series owned by the signed-in user.
+//
+//	POST /api/series
+//	{"title": "Building a blog", "description": "..."}
+func (s *service) createSeries(w http.ResponseWriter, r *http.Request) {
+	uid, err := httpapi.RequireUserID(r)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	var body struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+	}
+	if err := httpapi.ReadJSON(r, &body); err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	title := strings.TrimSpace(body.Title)
+	switch {
+	case title == "":
+		s.respond.Error(w, r, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("title is required")))
+		return
+	case utf8.RuneCountInString(title) > maxSeriesTitleLength:
+		s.respond.Error(w, r, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("title is longer than %d characters", maxSeriesTitleLength)))
+		return
+	case utf8.RuneCountInString(body.Description) > maxSeriesDescriptionLength:
+		s.respond.Error(w, r, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("description is longer than %d characters", maxSeriesDescriptionLength)))
+		return
+	}
+
+	now := pgtype.Timestamptz{Time: s.timeNow(), Valid: true}
+	series, err := s.queries.CreateSeries(r.Context(), postgres.CreateSeriesParams{
+		UserID:      uid,
+		Title:       title,
+		Description: body.Description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	})
+	if err != nil {
+		s.respond.Error(w, r, connect.NewError(connect.CodeInternal, fmt.Errorf("creating series: %v", err)))
+		return
+	}
+	s.writeSeries(w, r, &series)
+}
+
+// getSeries returns a series with the posts the viewer can read, in order,
+// with excerpts in place of their bodies.
+//
+//	GET /api/series/{id}
+func (s *service) getSeries(w http.ResponseWriter, r *http.Request) {
+	id, err := httpapi.PathInt64(r, "id")
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	series, err := s.queries.GetSeriesById(r.Context(), id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		s.respond.Error(w, r, connect.NewError(connect.CodeNotFound, fmt.Errorf("series not found")))
+		return
+	}
+	if err != nil {
+		s.respond.Error(w, r, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving series: %v", err)))
+		return
+	}
+	s.writeSeries(w, r, &series)
+}
+
+// addSeriesPost appends one of the owner's posts to the end of a series. A
+// post belongs to at most one series.
+//
+//	POST /api/series/{id}/posts
+//	{"post_id": 42}
+func (s *service) addSeriesPost(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		PostID int64 `json:"post_id"`
+	}
+	if err := httpapi.ReadJSON(r, &body); err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	s.modifySeries(w, r, "adding post to series", func(c context.Context, q *postgres.Queries, series *postgres.Series) error {
+		post, err := q.GetPostByIdForUpdate(c, body.PostID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return connect.NewError(connect.CodeNotFound, fmt.Errorf("post not found"))
+		}
+		if err != nil {
+			return fmt.Errorf("retrieving post: %v", err)
+		}
+		if post.UserID != series.UserID {
+			return connect.NewError(connect.CodePermissionDenied, fmt.Errorf("not the author of the post"))
+		}
+		current, err := q.GetSeriesByPostId(c, post.ID)
+		if err == nil {
+			return connect.NewError(connect.CodeAlreadyExists, fmt.Errorf("post is already in series %d", current.ID))
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("retrieving series of post: %v", err)
+		}
+		_, err = q.AddSeriesPost(c, postgres.AddSeriesPostParams{
+			SeriesID: series.ID,
+			PostID:   post.ID,
+		})
+		return err
+	})
+}
+
+// removeSeriesPost takes a post out of a series. The posts after it move up,
+// so positions stay contiguous.
+//
+//	DELETE /api/series/{id}/posts/{post_id}
+func (s *service) removeSeriesPost(w http.ResponseWriter, r *http.Request) {
+	postID, err := httpapi.PathInt64(r, "post_id")
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	s.modifySeries(w, r, "removing post from series", func(c context.Context, q *postgres.Queries, series *postgres.Series) error {
+		removed, err := q.RemoveSeriesPost(c, postgres.RemoveSeriesPostParams{
+			SeriesID: series.ID,
+			PostID:   postID,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return connect.NewError(connect.CodeNotFound, fmt.Errorf("post is not in the series"))
+		}
+		if err != nil {
+			return err
+		}
+		return q.CloseSeriesGap(c, postgres.CloseSeriesGapParams{
+			SeriesID: series.ID,
+			Position: removed.Position,
+		})
+	})
+}
+
+// reorderSeries puts the posts of a series in a new order. post_ids must list
+// every post of the series exactly once.
+//
+//	PUT /api/series/{id}/posts
+//	{"post_ids": [3, 1, 2]}
+func (s *service) reorderSeries(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		PostIDs []int64 `json:"post_ids"`
+	}
+	if err := httpapi.ReadJSON(r, &body); err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	s.modifySeries(w, r, "reordering series", func(c context.Context, q *postgres.Queries, series *postgres.Series) error {
+		current, err := q.ListSeriesPostIds(c, series.ID)
+		if err != nil {
+			return fmt.Errorf("retrieving series posts: %v", err)
+		}
+		if !samePosts(current, body.PostIDs) {
+			return connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("post_ids must list every post of the series exactly once"))
+		}
+		return q.ReorderSeriesPosts(c, postgres.ReorderSeriesPostsParams{
+			PostIds:  body.PostIDs,
+			SeriesID: series.ID,
+		})
+	})
+}
+
+// samePosts reports whether ids is a permutation of current, which holds no
+// duplicates.
+func samePosts(current, ids []int64) bool {
+	if len(current) != len(ids) {
+		return false
+	}
+	sorted := slices.Sorted(slices.Values(ids))
+	return slices.Equal(slices.Sorted(slices.Values(current)), slices.Compact(sorted))
+}
+
+// modifySeries runs fn on the locked series {id} if the signed-in user owns
+// it, then responds with the updated series.
+func (s *service) modifySeries(w http.ResponseWriter, r *http.Request, doing string, fn func(context.Context, *postgres.Queries, *postgres.Series) error) {
+	uid, err := httpapi.RequireUserID(r)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	id, err := httpapi.PathInt64(r, "id")
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+
+	tx := transaction.New[postgres.Series](
+		s.db,
+		pgx.TxOptions{
+			// Changes to a series are serialised by locking its row, and each
+			// statement must see what the previous holder of the lock wrote.
+			IsoLevel:   pgx.ReadCommitted,
+			AccessMode: pgx.ReadWrite,
+		},
+		s.queries,
+	)
+	series, txErr := tx.Exec(r.Context(), func(c context.Context, q *postgres.Queries) (*postgres.Series, error) {
+		series, err := q.GetSeriesByIdForUpdate(c, id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("series not found"))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("retrieving series: %v", err)
+		}
+		if series.UserID != uid {
+			return nil, connect.NewError(connect.CodePermissionDenied, fmt.Errorf("not the owner of the series"))
+		}
+		if err := fn(c, q, &series); err != nil {
+			return nil, err
+		}
+		series.UpdatedAt = pgtype.Timestamptz{Time: s.timeNow(), Valid: true}
+		err = q.TouchSeries(c, postgres.TouchSeriesParams{
+			UpdatedAt: series.UpdatedAt,
+			ID:        series.ID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("updating series: %v", err)
+		}
+		return &series, nil
+	})
+	if txErr != nil {
+		s.respond.Error(w, r, httpapi.AsConnectError(txErr, doing))
+		return
+	}
+	s.writeSeries(w, r, series)
+}
+
+func (s *service) writeSeries(w http.ResponseWriter, r *http.Request, series *postgres.Series) {
+	posts, err := s.queries.ListSeriesPosts(r.Context(), postgres.ListSeriesPostsParams{
+		SeriesID: series.ID,
+		ViewerID: httpapi.ViewerID(r.Context()),
+	})
+	if err != nil {
+		s.respond.Error(w, r, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving series posts: %v", err)))
+		return
+	}
+	postsJSON, err := s.postsJSON(r.Context(), posts)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	for _, p := range postsJSON {
+		p.Body = ""
+	}
+	s.respond.JSON(w, r, &seriesJSON{
+		ID:          series.ID,
+		UserID:      series.UserID,
+		Title:       series.Title,
+		Description: series.Description,
+		Posts:       postsJSON,
+		CreatedAt:   series.CreatedAt.Time,
+		UpdatedAt:   series.UpdatedAt.Time,
+	})
+}
+
+// seriesNav returns where a post sits in its series, counting only the posts
+// the viewer can read, or nil if the post is not part of a series.
+func (s *service) seriesNav(ctx context.Context, postID int64) (*seriesNavJSON, error) {
+	series, err := s.queries.GetSeriesByPostId(ctx, postID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving series: %v", err))
+	}
+	posts, err := s.queries.ListSeriesPosts(ctx, postgres.ListSeriesPostsParams{
+		SeriesID: series.ID,
+		ViewerID: httpapi.ViewerID(ctx),
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving series posts: %v", err))
+	}
+	i := slices.IndexFunc(posts, func(p postgres.Post) bool { return p.ID == postID })
+	if i < 0 {
+		return nil, nil
+	}
+	nav := &seriesNavJSON{
+		ID:       series.ID,
+		Title:    series.Title,
+		Position: i + 1,
+		Total:    len(posts),
+	}
+	if i > 0 {
+		nav.Prev = newSeriesLinkJSON(&posts[i-1])
+	}
+	if i+1 < len(posts) {
+		nav.Next = newSeriesLinkJSON(&posts[i+1])
+	}
+	return nav, nil
+}
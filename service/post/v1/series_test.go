@@ -0,0 +1,184 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func seriesPath(id int64, suffix string) string {
+	return "/api/series/" + strconv.FormatInt(id, 10) + suffix
+}
+
+func createSeries(t *testing.T, s *service, uid int64, title string) int64 {
+	t.Helper()
+	w := serve(t, s, asUser(uid), http.MethodPost, "/api/series", `{"title":"`+title+`"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var series seriesJSON
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &series))
+	return series.ID
+}
+
+func addToSeries(t *testing.T, s *service, uid, seriesID, postID int64) int {
+	t.Helper()
+	w := serve(t, s, asUser(uid), http.MethodPost, seriesPath(seriesID, "/posts"), `{"post_id":`+strconv.FormatInt(postID, 10)+`}`)
+	return w.Code
+}
+
+// seriesPosts returns the posts of a series in order, checking that their
+// positions run from 1 without gaps.
+func seriesPosts(t *testing.T, s *service, seriesID int64) []int64 {
+	t.Helper()
+	rows, err := s.db.Query(context.Background(),
+		"SELECT post_id, position FROM series_posts WHERE series_id = $1 ORDER BY position", seriesID)
+	require.NoError(t, err)
+	defer rows.Close()
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		var position int
+		require.NoError(t, rows.Scan(&id, &position))
+		ids = append(ids, id)
+		require.Equal(t, len(ids), position)
+	}
+	require.NoError(t, rows.Err())
+	return ids
+}
+
+func TestSeriesAddAndRemove(t *testing.T) {
+	s, _ := newTestService(t)
+	uid := createUser(t, s, "author")
+	seriesID := createSeries(t, s, uid, "Building a blog")
+	var ids []int64
+	for i := range 4 {
+		p := createPublished(t, s, uid, "part "+strconv.Itoa(i+1), "text")
+		require.Equal(t, http.StatusOK, addToSeries(t, s, uid, seriesID, p.ID))
+		ids = append(ids, p.ID)
+	}
+	require.Equal(t, ids, seriesPosts(t, s, seriesID))
+
+	require.Equal(t, http.StatusConflict, addToSeries(t, s, uid, seriesID, ids[0]))
+	other := createSeries(t, s, uid, "Elsewhere")
+	require.Equal(t, http.StatusConflict, addToSeries(t, s, uid, other, ids[0]), "a post belongs to one series")
+	require.Equal(t, http.StatusNotFound, addToSeries(t, s, uid, seriesID, 0))
+
+	w := serve(t, s, asUser(uid), http.MethodDelete, seriesPath(seriesID, "/posts/"+strconv.FormatInt(ids[1], 10)), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var series seriesJSON
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &series))
+	require.Len(t, series.Posts, 3)
+	require.Equal(t, []int64{ids[0], ids[2], ids[3]}, seriesPosts(t, s, seriesID), "the posts after a removed one move up")
+
+	w = serve(t, s, asUser(uid), http.MethodDelete, seriesPath(seriesID, "/posts/"+strconv.FormatInt(ids[1], 10)), "")
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+
+	require.Equal(t, http.StatusOK, addToSeries(t, s, uid, seriesID, ids[1]))
+	require.Equal(t, []int64{ids[0], ids[2], ids[3], ids[1]}, seriesPosts(t, s, seriesID), "a post is added at the end")
+}
+
+func TestReorderSeries(t *testing.T) {
+	s, _ := newTestService(t)
+	uid := createUser(t, s, "author")
+	seriesID := createSeries(t, s, uid, "Building a blog")
+	var ids []int64
+	for i := range 3 {
+		p := createPublished(t, s, uid, "part "+strconv.Itoa(i+1), "text")
+		require.Equal(t, http.StatusOK, addToSeries(t, s, uid, seriesID, p.ID))
+		ids = append(ids, p.ID)
+	}
+	stranger := createPublished(t, s, uid, "stranger", "text")
+
+	reorder := func(postIDs ...int64) int {
+		b, err := json.Marshal(map[string][]int64{"post_ids": postIDs})
+		require.NoError(t, err)
+		return serve(t, s, asUser(uid), http.MethodPut, seriesPath(seriesID, "/posts"), string(b)).Code
+	}
+	require.Equal(t, http.StatusOK, reorder(ids[2], ids[0], ids[1]))
+	require.Equal(t, []int64{ids[2], ids[0], ids[1]}, seriesPosts(t, s, seriesID))
+
+	for name, postIDs := range map[string][]int64{
+		"missing":   {ids[0], ids[1]},
+		"duplicate": {ids[0], ids[1], ids[1]},
+		"foreign":   {ids[0], ids[1], stranger.ID},
+		"extra":     {ids[0], ids[1], ids[2], stranger.ID},
+	} {
+		require.Equal(t, http.StatusBadRequest, reorder(postIDs...), name)
+	}
+	require.Equal(t, []int64{ids[2], ids[0], ids[1]}, seriesPosts(t, s, seriesID), "a rejected order changes nothing")
+}
+
+func TestSeriesOwnerOnly(t *testing.T) {
+	s, _ := newTestService(t)
+	owner := createUser(t, s, "owner")
+	other := createUser(t, s, "other")
+	seriesID := createSeries(t, s, owner, "Building a blog")
+	mine := createPublished(t, s, owner, "mine", "text")
+	theirs := createPublished(t, s, other, "theirs", "text")
+	require.Equal(t, http.StatusOK, addToSeries(t, s, owner, seriesID, mine.ID))
+
+	require.Equal(t, http.StatusForbidden, addToSeries(t, s, owner, seriesID, theirs.ID), "only the author's own posts")
+	require.Equal(t, http.StatusForbidden, addToSeries(t, s, other, seriesID, theirs.ID))
+	w := serve(t, s, asUser(other), http.MethodPut, seriesPath(seriesID, "/posts"), `{"post_ids":[`+strconv.FormatInt(mine.ID, 10)+`]}`)
+	require.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	w = serve(t, s, asUser(other), http.MethodDelete, seriesPath(seriesID, "/posts/"+strconv.FormatInt(mine.ID, 10)), "")
+	require.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	w = serve(t, s, context.Background(), http.MethodPost, seriesPath(seriesID, "/posts"), `{"post_id":`+strconv.FormatInt(mine.ID, 10)+`}`)
+	require.Equal(t, http.StatusUnauthorized, w.Code, w.Body.String())
+	require.Equal(t, http.StatusNotFound, addToSeries(t, s, owner, seriesID+1, mine.ID))
+
+	require.Equal(t, []int64{mine.ID}, seriesPosts(t, s, seriesID))
+}
+
+func TestSeriesNav(t *testing.T) {
+	s, _ := newTestService(t)
+	author := createUser(t, s, "author")
+	reader := createUser(t, s, "reader")
+	seriesID := createSeries(t, s, author, "Building a blog")
+	first := createPublished(t, s, author, "first", "text")
+	draft, err := s.create(context.Background(), author, "draft", "text", nil, statusDraft)
+	require.NoError(t, err)
+	last := createPublished(t, s, author, "last", "text")
+	for _, id := range []int64{first.ID, draft.Post.ID, last.ID} {
+		require.Equal(t, http.StatusOK, addToSeries(t, s, author, seriesID, id))
+	}
+	alone := createPublished(t, s, author, "alone", "text")
+
+	nav := func(uid, postID int64) *seriesNavJSON {
+		t.Helper()
+		w := serve(t, s, asUser(uid), http.MethodGet, "/api/posts/"+strconv.FormatInt(postID, 10), "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var detail postJSON
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+		return detail.Series
+	}
+
+	got := nav(author, draft.Post.ID)
+	require.Equal(t, seriesID, got.ID)
+	require.Equal(t, "Building a blog", got.Title)
+	require.Equal(t, 2, got.Position)
+	require.Equal(t, 3, got.Total)
+	require.Equal(t, first.ID, got.Prev.ID)
+	require.Equal(t, first.Slug, got.Prev.Slug)
+	require.Equal(t, last.ID, got.Next.ID)
+
+	got = nav(author, first.ID)
+	require.Equal(t, 1, got.Position)
+	require.Nil(t, got.Prev)
+	require.Equal(t, draft.Post.ID, got.Next.ID)
+
+	// Readers who cannot see the draft step over it.
+	got = nav(reader, first.ID)
+	require.Equal(t, 1, got.Position)
+	require.Equal(t, 2, got.Total)
+	require.Equal(t, last.ID, got.Next.ID)
+	got = nav(reader, last.ID)
+	require.Equal(t, 2, got.Position)
+	require.Equal(t, first.ID, got.Prev.ID)
+	require.Nil(t, got.Next)
+
+	require.Nil(t, nav(reader, alone.ID))
+}
@@ -0,0 +1,106 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"connectrpc.com/connect"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/httpapi"
+	"github.com/gaesemo/blog-server/pkg/slug"
+	"github.com/gaesemo/blog-server/pkg/transaction"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// nextSlug picks the slug of a post titled title. A post keeps its current
+// slug while the title maps to the same base, and gets a former slug back if
+// the title changes back. postID is 0 for a post about to be created. The
+// second result reports whether the slug is new and must be recorded with
+// AddPostSlug.
+func nextSlug(ctx context.Context, q *postgres.Queries, postID int64, current, title string) (string, bool, error) {
+	base := slug.Make(title)
+	if current != "" && slug.HasBase(current, base) {
+		return current, false, nil
+	}
+	rows, err := q.ListSlugsByBase(ctx, base)
+	if err != nil {
+		return "", false, fmt.Errorf("retrieving slugs: %v", err)
+	}
+	taken := make(map[string]bool, len(rows))
+	for _, r := range rows {
+		if postID != 0 && r.PostID.Int64 == postID && slug.HasBase(r.Slug, base) {
+			return r.Slug, false, nil
+		}
+		taken[r.Slug] = true
+	}
+	return slug.Unique(base, func(s string) bool { return taken[s] }), true, nil
+}
+
+// maxSlugAttempts bounds how often a post is saved again after a concurrent
+// save took the slug picked for it.
+const maxSlugAttempts = 3
+
+// errSlugTaken fails a transaction whose slug was taken by a concurrent one
+// after nextSlug picked it. It is only returned once retries run out.
+var errSlugTaken = connect.NewError(connect.CodeAborted, errors.New("slug was taken concurrently"))
+
+// isSlugConflict reports whether err is a unique violation (SQLSTATE 23505)
+// on a slug.
+func isSlugConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		return false
+	}
+	return pgErr.ConstraintName == "posts_slug_key" || pgErr.ConstraintName == "post_slugs_pkey"
+}
+
+// execRetryingSlug runs fn in tx like tx.Exec, and runs it again in a new
+// transaction while it fails with errSlugTaken, so nextSlug sees the slugs
+// taken in the meantime.
+func execRetryingSlug[R any](ctx context.Context, tx *transaction.Transaction[R], fn func(context.Context, *postgres.Queries) (*R, error)) (*R, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := tx.Exec(ctx, fn)
+		if errors.Is(err, errSlugTaken) && attempt < maxSlugAttempts {
+			continue
+		}
+		return result, err
+	}
+}
+
+// getBySlug resolves a permalink. Old slugs keep resolving after the title
+// changed; moved is then true and slug holds the current one, so clients can
+// redirect permanently.
+//
+//	GET /api/slugs/{slug}
+func (s *service) getBySlug(w http.ResponseWriter, r *http.Request) {
+	requested := r.PathValue("slug")
+	post, err := s.queries.GetPostBySlug(r.Context(), postgres.GetPostBySlugParams{
+		Slug:     requested,
+		ViewerID: httpapi.ViewerID(r.Context()),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		s.respond.Error(w, r, connect.NewError(connect.CodeNotFound, fmt.Errorf("post not found")))
+		return
+	}
+	if err != nil {
+		s.respond.Error(w, r, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving post: %v", err)))
+		return
+	}
+	detail, err := s.detailOf(w, r, &post)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	s.respond.JSON(w, r, struct {
+		Post  *postJSON `json:"post"`
+		Slug  string    `json:"slug"`
+		Moved bool      `json:"moved"`
+	}{
+		Post:  detail,
+		Slug:  post.Slug,
+		Moved: post.Slug != requested,
+	})
+}
@@ -0,0 +1,89 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	postv1 "github.com/gaesemo/blog-api/go/service/post/v1"
+	typesv1 "github.com/gaesemo/blog-api/go/types/v1"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestConcurrentCreatesGetDistinctSlugs(t *testing.T) {
+	s, _ := newTestService(t)
+	uid := createUser(t, s, "author")
+
+	// Each save that loses the race retries, so up to maxSlugAttempts
+	// posts can race for a slug.
+	const n = maxSlugAttempts
+	ids := make([]int64, n)
+	var eg errgroup.Group
+	for i := range n {
+		eg.Go(func() error {
+			resp, err := s.Create(asUser(uid), connect.NewRequest(&postv1.CreateRequest{
+				PostContent: &typesv1.PostContent{Title: "same title", Body: "body"},
+			}))
+			if err != nil {
+				return err
+			}
+			ids[i] = resp.Msg.Post.Id
+			return nil
+		})
+	}
+	require.NoError(t, eg.Wait())
+
+	slugs := map[string]bool{}
+	for _, id := range ids {
+		slugs[getPost(t, s, id, uid).Slug] = true
+	}
+	require.Len(t, slugs, n)
+}
+
+func TestPurgedPostsKeepTheirSlugs(t *testing.T) {
+	s, c := newTestService(t)
+	uid := createUser(t, s, "author")
+	post, _ := createPost(t, s, uid, "gone")
+	slug := getPost(t, s, post.Id, uid).Slug
+
+	_, err := s.Delete(asUser(uid), connect.NewRequest(&postv1.DeleteRequest{Id: post.Id}))
+	require.NoError(t, err)
+	c.now = c.now.Add(s.trashRetention + time.Hour)
+	require.NoError(t, s.PurgeTrash(context.Background()))
+
+	next, _ := createPost(t, s, uid, "gone")
+	require.NotEqual(t, slug, getPost(t, s, next.Id, uid).Slug, "a purged post's slug is not handed out again")
+}
+
+func TestGetBySlugServesDetail(t *testing.T) {
+	s, _ := newTestService(t)
+	uid := createUser(t, s, "author")
+	post := createPublished(t, s, uid, "Old title", "# Heading\n\ntext")
+	oldSlug := post.Slug
+	_, err := s.update(asUser(uid), uid, post.ID, post.Version, "New title", post.Body, nil)
+	require.NoError(t, err)
+
+	byID := serve(t, s, context.Background(), http.MethodGet, "/api/posts/"+strconv.FormatInt(post.ID, 10), "")
+	require.Equal(t, http.StatusOK, byID.Code, byID.Body.String())
+	var detail postJSON
+	require.NoError(t, json.Unmarshal(byID.Body.Bytes(), &detail))
+
+	bySlug := serve(t, s, context.Background(), http.MethodGet, "/api/slugs/"+oldSlug, "")
+	require.Equal(t, http.StatusOK, bySlug.Code, bySlug.Body.String())
+	var resolved struct {
+		Post  postJSON `json:"post"`
+		Slug  string   `json:"slug"`
+		Moved bool     `json:"moved"`
+	}
+	require.NoError(t, json.Unmarshal(bySlug.Body.Bytes(), &resolved))
+	require.True(t, resolved.Moved)
+	require.Equal(t, detail.Slug, resolved.Slug)
+	require.Equal(t, byID.Header().Get("ETag"), bySlug.Header().Get("ETag"))
+	require.NotEmpty(t, resolved.Post.BodyHTML)
+	require.JSONEq(t, string(detail.TOC), string(resolved.Post.TOC))
+}
@@ -0,0 +1,115 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/httpapi"
+	"github.com/gaesemo/blog-server/pkg/transaction"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// PublishDuePosts implements Service.
+func (s *service) PublishDuePosts(ctx context.Context) error {
+	ids, err := s.queries.PublishDuePosts(ctx, pgtype.Timestamptz{Time: s.timeNow(), Valid: true})
+	if err != nil {
+		return fmt.Errorf("publishing scheduled posts: %v", err)
+	}
+	if len(ids) > 0 {
+		s.logger.InfoContext(ctx, "published scheduled posts", slog.Int("posts", len(ids)))
+		s.postsChanged(ids...)
+	}
+	return nil
+}
+
+// setStatus moves a post between draft, scheduled and published. Scheduling
+// needs a publish_at in the future; the post goes public once PublishDuePosts
+// runs after that time.
+//
+//	PUT /api/posts/{id}/status
+//	{"status": "scheduled", "publish_at": "2025-07-01T09:00:00+09:00"}
+func (s *service) setStatus(w http.ResponseWriter, r *http.Request) {
+	uid, err := httpapi.RequireUserID(r)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	id, err := httpapi.PathInt64(r, "id")
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	var body struct {
+		Status    string     `json:"status"`
+		PublishAt *time.Time `json:"publish_at"`
+	}
+	if err := httpapi.ReadJSON(r, &body); err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+
+	now := s.timeNow()
+	var publishedAt pgtype.Timestamptz
+	switch body.Status {
+	case statusDraft:
+	case statusPublished:
+		publishedAt = pgtype.Timestamptz{Time: now, Valid: true}
+	case statusScheduled:
+		if body.PublishAt == nil || !body.PublishAt.After(now) {
+			s.respond.Error(w, r, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("publish_at must be in the future")))
+			return
+		}
+		publishedAt = pgtype.Timestamptz{Time: body.PublishAt.UTC(), Valid: true}
+	default:
+		s.respond.Error(w, r, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("unknown status %q", body.Status)))
+		return
+	}
+
+	tx := transaction.New[postgres.Post](
+		s.db,
+		pgx.TxOptions{
+			IsoLevel:   pgx.RepeatableRead,
+			AccessMode: pgx.ReadWrite,
+		},
+		s.queries,
+	)
+	post, txErr := tx.Exec(r.Context(), func(c context.Context, q *postgres.Queries) (*postgres.Post, error) {
+		post, err := q.GetPostByIdForUpdate(c, id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("post not found"))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("retrieving post: %v", err)
+		}
+		if post.UserID != uid {
+			return nil, connect.NewError(connect.CodePermissionDenied, fmt.Errorf("not the author of the post"))
+		}
+		if post.Status == statusPublished && body.Status == statusPublished {
+			return &post, nil
+		}
+		updated, err := q.SetPostStatus(c, postgres.SetPostStatusParams{
+			Status:      body.Status,
+			PublishedAt: publishedAt,
+			UpdatedAt:   pgtype.Timestamptz{Time: now, Valid: true},
+			ID:          post.ID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("updating post status: %v", err)
+		}
+		return &updated, nil
+	})
+	if txErr != nil {
+		s.respond.Error(w, r, httpapi.AsConnectError(txErr, "updating post status"))
+		return
+	}
+	s.postsChanged(post.ID)
+	w.Header().Set("ETag", etag(post.Version))
+	s.respond.JSON(w, r, newPostJSON(post))
+}
@@ -0,0 +1,44 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	postv1 "github.com/gaesemo/blog-api/go/service/post/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishDuePosts(t *testing.T) {
+	s, c := newTestService(t)
+	uid := createUser(t, s, "author")
+	post, _ := createPost(t, s, uid, "scheduled")
+
+	publishAt := c.now.Add(time.Hour)
+	body := `{"status": "scheduled", "publish_at": "` + publishAt.Format(time.RFC3339) + `"}`
+	r := httptest.NewRequestWithContext(asUser(uid), http.MethodPut, "/api/posts/"+strconv.FormatInt(post.Id, 10)+"/status", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	detail := func() error {
+		_, err := s.Detail(context.Background(), connect.NewRequest(&postv1.DetailRequest{Id: post.Id}))
+		return err
+	}
+
+	require.NoError(t, s.PublishDuePosts(context.Background()))
+	require.Equal(t, connect.CodeNotFound, connect.CodeOf(detail()), "scheduled posts are hidden from readers")
+
+	c.now = publishAt.Add(time.Minute)
+	require.NoError(t, s.PublishDuePosts(context.Background()))
+
+	got := getPost(t, s, post.Id, uid)
+	require.Equal(t, statusPublished, got.Status)
+	require.True(t, got.PublishedAt.Time.Equal(publishAt), "keeps the scheduled time")
+	require.NoError(t, detail(), "published posts are visible to anonymous readers")
+}
@@ -2,8 +2,13 @@ package v1
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"connectrpc.com/authn"
@@ -12,51 +17,150 @@ import (
 	"github.com/gaesemo/blog-api/go/service/post/v1/postv1connect"
 	typesv1 "github.com/gaesemo/blog-api/go/types/v1"
 	"github.com/gaesemo/blog-server/gen/db/postgres"
-	"github.com/gaesemo/blog-server/pkg/cursor"
+	"github.com/gaesemo/blog-server/pkg/httpapi"
 	"github.com/gaesemo/blog-server/pkg/transaction"
+	"github.com/gaesemo/blog-server/pkg/userloader"
+	"github.com/gaesemo/blog-server/pkg/viewcount"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-var _ postv1connect.PostServiceHandler = (*service)(nil)
+var _ Service = (*service)(nil)
+
+// Service serves the PostService RPCs and, over plain HTTP, the post
+// operations the RPC API has no methods for.
+type Service interface {
+	postv1connect.PostServiceHandler
+	http.Handler
+
+	// PurgeTrash hard-deletes posts that stayed in the trash longer than the
+	// retention period.
+	PurgeTrash(ctx context.Context) error
+	// PublishDuePosts publishes scheduled posts whose publish time has come.
+	PublishDuePosts(ctx context.Context) error
+	// FlushViews writes the views counted in memory since the last flush.
+	FlushViews(ctx context.Context) error
+	// RefreshRelatedPosts recomputes the related posts of the posts that
+	// may be affected by the posts changed since the last refresh.
+	RefreshRelatedPosts(ctx context.Context) error
+	// RefreshRankings recomputes the trending and top post rankings.
+	RefreshRankings(ctx context.Context) error
+}
 
 func New(
 	logger *slog.Logger,
-	db *pgx.Conn,
+	db *pgxpool.Pool,
 	timeNow func() time.Time,
-) postv1connect.PostServiceHandler {
-	return &service{
-		logger:  logger,
-		db:      db,
-		queries: postgres.New(db),
-		timeNow: timeNow,
+	opts ...Option,
+) Service {
+	svc := &service{
+		logger:         logger,
+		db:             db,
+		queries:        postgres.New(db),
+		timeNow:        timeNow,
+		respond:        httpapi.NewResponder(logger),
+		trashRetention: defaultTrashRetention,
+		views:          viewcount.NewBuffer(defaultViewWindow),
+		viewers:        viewcount.NewHasher(viewerKeyPeriod),
+		relatedPosts:   defaultRelatedPosts,
+		relatedChanged: map[int64]bool{},
+	}
+	// Nothing is known about the related posts until the first refresh.
+	svc.relatedAll = true
+
+	for _, o := range opts {
+		o(svc)
+	}
+
+	svc.mux = svc.routes()
+	return svc
+}
+
+const (
+	defaultTrashRetention = 30 * 24 * time.Hour
+)
+
+// Post statuses. Only published posts are visible to readers other than the
+// author.
+const (
+	statusDraft     = "draft"
+	statusScheduled = "scheduled"
+	statusPublished = "published"
+)
+
+type Option func(svc *service)
+
+// WithChangeHook registers fn to be called after a post is created, edited,
+// retagged, deleted, restored or changes status, e.g. to drop caches derived
+// from the published posts.
+func WithChangeHook(fn func()) Option {
+	return func(svc *service) {
+		svc.changeHooks = append(svc.changeHooks, fn)
 	}
 }
 
 type service struct {
-	logger  *slog.Logger
-	db      *pgx.Conn
-	queries *postgres.Queries
-	timeNow func() time.Time
+	logger         *slog.Logger
+	db             *pgxpool.Pool
+	queries        *postgres.Queries
+	timeNow        func() time.Time
+	mux            *http.ServeMux
+	respond        *httpapi.Responder
+	trashRetention time.Duration
+	views          *viewcount.Buffer
+	viewers        *viewcount.Hasher
+	changeHooks    []func()
+	relatedPosts   int
+	// relatedMu guards relatedChanged and relatedAll, which tell
+	// RefreshRelatedPosts what to recompute.
+	relatedMu sync.Mutex
+	// relatedChanged holds the posts changed since the last refresh.
+	relatedChanged map[int64]bool
+	// relatedAll is set until the first refresh, which computes every post.
+	relatedAll bool
 }
 
 // Create implements postv1connect.PostServiceHandler.
+//
+// Posts created with Create are published at once, as all posts were before
+// they had a status, since no RPC can publish a draft. POST /api/posts saves
+// drafts instead.
 func (s *service) Create(ctx context.Context, req *connect.Request[postv1.CreateRequest]) (*connect.Response[postv1.CreateResponse], error) {
 	uid := authn.GetInfo(ctx).(*int64)
 	if uid == nil {
 		return connect.NewResponse(&postv1.CreateResponse{}), connect.NewError(connect.CodeUnauthenticated, fmt.Errorf("author not found"))
 	}
 	content := req.Msg.PostContent
-	title := content.Title
-	body := content.Body
+	result, err := s.create(ctx, *uid, content.Title, content.Body, nil, statusPublished)
+	if err != nil {
+		return connect.NewResponse(&postv1.CreateResponse{}), err
+	}
+	resp := connect.NewResponse(&postv1.CreateResponse{
+		Post: pbPost(result.Post, result.User, *uid),
+	})
+	resp.Header().Set("ETag", etag(result.Post.Version))
+	return resp, nil
+}
 
-	type Result struct {
-		User *postgres.User
-		Post *postgres.Post
+// create saves a new post by uid with status, which is either draft or
+// published, tagged with tags. Errors are connect errors.
+func (s *service) create(ctx context.Context, uid int64, title, body string, tags []string, status string) (*postResult, error) {
+	tags, err := validateTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := render(body)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.createTags(ctx, tags); err != nil {
+		return nil, err
 	}
 
-	tx := transaction.New[Result](
+	tx := transaction.New[postResult](
 		s.db,
 		pgx.TxOptions{
 			IsoLevel:   pgx.RepeatableRead,
@@ -64,64 +168,126 @@ func (s *service) Create(ctx context.Context, req *connect.Request[postv1.Create
 		},
 		s.queries,
 	)
-	result, txErr := tx.Exec(ctx, func(c context.Context, q *postgres.Queries) (*Result, error) {
-		user, err := q.GetUserById(c, *uid)
+	result, txErr := execRetryingSlug(ctx, tx, func(c context.Context, q *postgres.Queries) (*postResult, error) {
+		user, err := q.GetUserById(c, uid)
 		if err != nil {
 			return nil, fmt.Errorf("user not found: %v", err)
 		}
+		postSlug, _, err := nextSlug(c, q, 0, "", title)
+		if err != nil {
+			return nil, err
+		}
+		now := s.timeNow()
+		var publishedAt pgtype.Timestamptz
+		if status == statusPublished {
+			publishedAt = pgtype.Timestamptz{Time: now, Valid: true}
+		}
 		post, err := q.CreatePost(c, postgres.CreatePostParams{
-			Likes:     0,
-			Views:     0,
-			Title:     title,
-			Body:      body,
-			UserID:    user.ID,
-			CreatedAt: pgtype.Timestamptz{Time: s.timeNow(), Valid: true},
-			UpdatedAt: pgtype.Timestamptz{Time: s.timeNow(), Valid: true},
+			Likes:          0,
+			Views:          0,
+			Title:          title,
+			Body:           body,
+			UserID:         user.ID,
+			Status:         status,
+			PublishedAt:    publishedAt,
+			Slug:           postSlug,
+			BodyHtml:       rendered.HTML,
+			Toc:            rendered.TOC,
+			Excerpt:        rendered.Stats.Excerpt,
+			WordCount:      int32(rendered.Stats.Words),
+			ReadingMinutes: int32(rendered.Stats.ReadingMinutes),
+			CreatedAt:      pgtype.Timestamptz{Time: now, Valid: true},
+			UpdatedAt:      pgtype.Timestamptz{Time: now, Valid: true},
 		})
+		if isSlugConflict(err) {
+			return nil, errSlugTaken
+		}
 		if err != nil {
 			return nil, fmt.Errorf("insert new post: %v", err)
 		}
-		return &Result{
+		err = q.AddPostSlug(c, postgres.AddPostSlugParams{
+			Slug:      post.Slug,
+			PostID:    pgtype.Int8{Int64: post.ID, Valid: true},
+			CreatedAt: post.CreatedAt,
+		})
+		if isSlugConflict(err) {
+			return nil, errSlugTaken
+		}
+		if err != nil {
+			return nil, fmt.Errorf("saving slug: %v", err)
+		}
+		if len(tags) > 0 {
+			if err := replaceTags(c, q, post.ID, tags); err != nil {
+				return nil, err
+			}
+		}
+		return &postResult{
 			User: &user,
 			Post: &post,
 		}, nil
 	})
 	if txErr != nil {
-		return connect.NewResponse(&postv1.CreateResponse{}), connect.NewError(connect.CodeInternal, fmt.Errorf("creating post: %v", txErr))
-	}
-	return connect.NewResponse(&postv1.CreateResponse{
-		Post: &typesv1.Post{
-			Id:    result.Post.ID,
-			Likes: result.Post.Likes,
-			Views: result.Post.Views,
-			Author: &typesv1.User{
-				Id:               result.User.ID,
-				Username:         result.User.Username,
-				Email:            result.User.Email,
-				AvatarUrl:        result.User.AvatarUrl,
-				AboutMe:          result.User.AboutMe,
-				IdentityProvider: typesv1.IdentityProvider(typesv1.IdentityProvider_value[result.User.IdentityProvider]),
-				CreatedAt:        timestamppb.New(result.User.CreatedAt.Time),
-				UpdatedAt:        timestamppb.New(result.User.UpdatedAt.Time),
-				DeletedAt:        nil,
-			},
-			Content:   content,
-			CreatedAt: timestamppb.New(result.Post.CreatedAt.Time),
-			UpdatedAt: timestamppb.New(result.Post.UpdatedAt.Time),
-		},
-	}), nil
+		return nil, httpapi.AsConnectError(txErr, "creating post")
+	}
+	s.postsChanged(result.Post.ID)
+	return result, nil
 }
 
 // Delete implements postv1connect.PostServiceHandler.
+//
+// Deleted posts are moved to the author's trash, where they can be restored
+// until PurgeTrash removes them for good.
 func (s *service) Delete(ctx context.Context, req *connect.Request[postv1.DeleteRequest]) (*connect.Response[postv1.DeleteResponse], error) {
-	uid := authn.GetInfo(ctx).(*int64)
+	uid, _ := authn.GetInfo(ctx).(*int64)
 	if uid == nil {
 		return nil, connect.NewError(connect.CodeUnauthenticated, fmt.Errorf("author not found"))
 	}
-	return nil, nil
+
+	tx := transaction.New[struct{}](
+		s.db,
+		pgx.TxOptions{
+			IsoLevel:   pgx.RepeatableRead,
+			AccessMode: pgx.ReadWrite,
+		},
+		s.queries,
+	)
+	_, txErr := tx.Exec(ctx, func(c context.Context, q *postgres.Queries) (*struct{}, error) {
+		post, err := q.GetPostByIdForUpdate(c, req.Msg.Id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("post not found"))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("retrieving post: %v", err)
+		}
+		if post.UserID != *uid {
+			return nil, connect.NewError(connect.CodePermissionDenied, fmt.Errorf("not the author of the post"))
+		}
+		err = q.SoftDeletePost(c, postgres.SoftDeletePostParams{
+			DeletedAt: pgtype.Timestamptz{Time: s.timeNow(), Valid: true},
+			ID:        post.ID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("deleting post: %v", err)
+		}
+		return &struct{}{}, nil
+	})
+	if txErr != nil {
+		return nil, httpapi.AsConnectError(txErr, "deleting post")
+	}
+	s.postsChanged(req.Msg.Id)
+	return connect.NewResponse(&postv1.DeleteResponse{}), nil
 }
 
 // Detail implements postv1connect.PostServiceHandler.
+//
+// Reading a published post counts as a view, once per viewer within the view
+// window. Views are buffered and written by FlushViews.
+//
+// The Post message only carries the Markdown source and cannot tell whether
+// the viewer likes the post, where it sits in a series or which posts are
+// related; GET /api/posts/{id} returns the rendered HTML, the table of
+// contents, the liked flag, the previous and next posts of the series and the
+// related posts alongside it.
 func (s *service) Detail(ctx context.Context, req *connect.Request[postv1.DetailRequest]) (*connect.Response[postv1.DetailResponse], error) {
 	type Result struct {
 		User *postgres.User
@@ -137,94 +303,268 @@ func (s *service) Detail(ctx context.Context, req *connect.Request[postv1.Detail
 		s.queries,
 	)
 	result, txErr := tx.Exec(ctx, func(c context.Context, q *postgres.Queries) (*Result, error) {
-		post, err := q.GetPostById(ctx, req.Msg.Id)
-		if err != nil {
-			return nil, fmt.Errorf("post not found: %v", err)
+		post, err := q.GetPostById(ctx, postgres.GetPostByIdParams{
+			ID:       req.Msg.Id,
+			ViewerID: httpapi.ViewerID(ctx),
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("post not found"))
 		}
-		user, err := q.GetUserById(c, post.UserID)
 		if err != nil {
-			return nil, fmt.Errorf("user not found: %v", err)
+			return nil, fmt.Errorf("retrieving post: %v", err)
 		}
+		users, err := userloader.Load(c, q, []int64{post.UserID})
 		if err != nil {
-			return nil, fmt.Errorf("insert new post: %v", err)
+			return nil, fmt.Errorf("retrieving author: %v", err)
 		}
 		return &Result{
-			User: &user,
+			User: users[post.UserID],
 			Post: &post,
 		}, nil
 	})
 	if txErr != nil {
-		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("creating post: %v", txErr))
-	}
-	return connect.NewResponse(&postv1.DetailResponse{
-		Post: &typesv1.Post{
-			Id:    result.Post.ID,
-			Likes: result.Post.Likes,
-			Views: result.Post.Views,
-			Author: &typesv1.User{
-				Id:               result.User.ID,
-				Username:         result.User.Username,
-				Email:            result.User.Email,
-				AvatarUrl:        result.User.AvatarUrl,
-				AboutMe:          result.User.AboutMe,
-				IdentityProvider: typesv1.IdentityProvider(typesv1.IdentityProvider_value[result.User.IdentityProvider]),
-				CreatedAt:        timestamppb.New(result.User.CreatedAt.Time),
-				UpdatedAt:        timestamppb.New(result.User.UpdatedAt.Time),
-				DeletedAt:        nil,
-			},
-			Content: &typesv1.PostContent{
-				Title: result.Post.Title,
-				Body:  result.Post.Body,
-			},
-			CreatedAt: timestamppb.New(result.Post.CreatedAt.Time),
-			UpdatedAt: timestamppb.New(result.Post.UpdatedAt.Time),
-		},
-	}), nil
+		return nil, httpapi.AsConnectError(txErr, "retrieving post")
+	}
+	s.recordView(ctx, result.Post, req.Peer().Addr, req.Header().Get("User-Agent"))
+	resp := connect.NewResponse(&postv1.DetailResponse{
+		Post: pbPost(result.Post, result.User, httpapi.ViewerID(ctx)),
+	})
+	resp.Header().Set("ETag", etag(result.Post.Version))
+	return resp, nil
 }
 
 // List implements postv1connect.PostServiceHandler.
+//
+// Posts carry their excerpt in place of the body; Detail has the full post.
+// Next is left empty on the last page. ListRequest carries no page size or
+// direction, so List always pages forward by defaultListPageSize; GET
+// /api/posts offers both. Nor does it carry an order: posts come newest first
+// unless the Sort header asks for trending, top-week, top-month or top-all.
 func (s *service) List(ctx context.Context, req *connect.Request[postv1.ListRequest]) (*connect.Response[postv1.ListResponse], error) {
-	cur := cursor.MustParseInt64(req.Msg.Cursor)
+	sort, err := parseSort(req.Header().Get("Sort"))
+	if err != nil {
+		return nil, err
+	}
+	var page *listPage
+	if sort == sortRecent {
+		page, err = s.listRecent(ctx, listParams{
+			cursor:   req.Msg.Cursor,
+			pageSize: defaultListPageSize,
+		})
+	} else {
+		page, err = s.listRanked(ctx, sort, req.Msg.Cursor, defaultListPageSize)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	rows, err := s.queries.ListRecentPosts(ctx, postgres.ListRecentPostsParams{
-		Limit:  10,
-		Cursor: cur,
-	})
+	authorIDs := make([]int64, 0, len(page.posts))
+	for _, p := range page.posts {
+		authorIDs = append(authorIDs, p.UserID)
+	}
+	authors, err := userloader.Load(ctx, s.queries, authorIDs)
 	if err != nil {
-		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("retreiving posts: %v", err))
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving authors: %v", err))
 	}
 
-	last := len(rows)
+	viewerID := httpapi.ViewerID(ctx)
 	posts := []*typesv1.Post{}
-	var c int64
-	for i, p := range rows {
-		posts = append(posts, pbPost(&p))
-		if i == last-1 {
-			c = p.ID
-		}
+	for _, p := range page.posts {
+		posts = append(posts, pbPostSummary(&p, authors[p.UserID], viewerID))
 	}
 
 	return connect.NewResponse(&postv1.ListResponse{
 		Posts: posts,
-		Next:  cursor.FromInt64(c),
+		Next:  page.next,
 	}), nil
 }
 
 // Update implements postv1connect.PostServiceHandler.
+//
+// The caller must send the ETag of the post it edited in the If-Match header.
+// If someone else updated the post in the meantime the update is rejected with
+// CodeAborted, and the caller has to reload the post before trying again.
 func (s *service) Update(ctx context.Context, req *connect.Request[postv1.UpdateRequest]) (*connect.Response[postv1.UpdateResponse], error) {
-	uid := authn.GetInfo(ctx).(*int64)
+	uid, _ := authn.GetInfo(ctx).(*int64)
 	if uid == nil {
 		return nil, connect.NewError(connect.CodeUnauthenticated, fmt.Errorf("author not found"))
 	}
-	return nil, nil
+	version, err := parseETag(req.Header().Get("If-Match"))
+	if err != nil {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, err)
+	}
+	content := req.Msg.PostContent
+	if content == nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("post content required"))
+	}
+
+	result, err := s.update(ctx, *uid, req.Msg.Id, version, content.Title, content.Body, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := connect.NewResponse(&postv1.UpdateResponse{
+		Post: pbPost(result.Post, result.User, *uid),
+	})
+	resp.Header().Set("ETag", etag(result.Post.Version))
+	return resp, nil
 }
 
-func pbPost(p *postgres.Post) *typesv1.Post {
+// postResult is a post as written by create or update, with its author.
+type postResult struct {
+	User *postgres.User
+	Post *postgres.Post
+}
+
+// update replaces the title and body of a post owned by uid, provided the post
+// is still at the given version. The content being replaced is kept as a
+// revision. Unless tags is nil, it replaces the tags of the post too. Errors
+// are connect errors.
+func (s *service) update(ctx context.Context, uid, postID, version int64, title, body string, tags []string) (*postResult, error) {
+	if tags != nil {
+		var err error
+		if tags, err = validateTags(tags); err != nil {
+			return nil, err
+		}
+	}
+	rendered, err := render(body)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.createTags(ctx, tags); err != nil {
+		return nil, err
+	}
+	tx := transaction.New[postResult](
+		s.db,
+		pgx.TxOptions{
+			IsoLevel:   pgx.RepeatableRead,
+			AccessMode: pgx.ReadWrite,
+		},
+		s.queries,
+	)
+	result, txErr := execRetryingSlug(ctx, tx, func(c context.Context, q *postgres.Queries) (*postResult, error) {
+		post, err := q.GetPostByIdForUpdate(c, postID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("post not found"))
+		}
+		if isSerializationFailure(err) {
+			return nil, connect.NewError(connect.CodeAborted, fmt.Errorf("post was updated concurrently"))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("retrieving post: %v", err)
+		}
+		if post.UserID != uid {
+			return nil, connect.NewError(connect.CodePermissionDenied, fmt.Errorf("not the author of the post"))
+		}
+		if post.Version != version {
+			return nil, connect.NewError(connect.CodeAborted, fmt.Errorf("post was updated since version %d", version))
+		}
+		now := pgtype.Timestamptz{Time: s.timeNow(), Valid: true}
+		if post.Title != title || post.Body != body {
+			_, err = q.CreatePostRevision(c, postgres.CreatePostRevisionParams{
+				PostID:    post.ID,
+				Title:     post.Title,
+				Body:      post.Body,
+				EditorID:  uid,
+				CreatedAt: now,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("saving revision: %v", err)
+			}
+		}
+		postSlug, isNew, err := nextSlug(c, q, post.ID, post.Slug, title)
+		if err != nil {
+			return nil, err
+		}
+		if isNew {
+			err = q.AddPostSlug(c, postgres.AddPostSlugParams{
+				Slug:      postSlug,
+				PostID:    pgtype.Int8{Int64: post.ID, Valid: true},
+				CreatedAt: now,
+			})
+			if isSlugConflict(err) {
+				return nil, errSlugTaken
+			}
+			if err != nil {
+				return nil, fmt.Errorf("saving slug: %v", err)
+			}
+		}
+		updated, err := q.UpdatePost(c, postgres.UpdatePostParams{
+			Title:          title,
+			Body:           body,
+			Slug:           postSlug,
+			BodyHtml:       rendered.HTML,
+			Toc:            rendered.TOC,
+			Excerpt:        rendered.Stats.Excerpt,
+			WordCount:      int32(rendered.Stats.Words),
+			ReadingMinutes: int32(rendered.Stats.ReadingMinutes),
+			UpdatedAt:      now,
+			ID:             post.ID,
+			Version:        post.Version,
+		})
+		if errors.Is(err, pgx.ErrNoRows) || isSerializationFailure(err) {
+			return nil, connect.NewError(connect.CodeAborted, fmt.Errorf("post was updated concurrently"))
+		}
+		if isSlugConflict(err) {
+			return nil, errSlugTaken
+		}
+		if err != nil {
+			return nil, fmt.Errorf("updating post: %v", err)
+		}
+		if tags != nil {
+			if err := replaceTags(c, q, post.ID, tags); err != nil {
+				return nil, err
+			}
+		}
+		user, err := q.GetUserById(c, updated.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("user not found: %v", err)
+		}
+		return &postResult{
+			User: &user,
+			Post: &updated,
+		}, nil
+	})
+	if txErr != nil {
+		return nil, httpapi.AsConnectError(txErr, "updating post")
+	}
+	s.postsChanged(result.Post.ID)
+	return result, nil
+}
+
+// postsChanged marks the related posts of ids for recomputing and runs the
+// change hooks.
+func (s *service) postsChanged(ids ...int64) {
+	s.relatedMu.Lock()
+	for _, id := range ids {
+		s.relatedChanged[id] = true
+	}
+	s.relatedMu.Unlock()
+	for _, fn := range s.changeHooks {
+		fn()
+	}
+}
+
+// pbPostSummary converts a post for lists, with its excerpt standing in for
+// the body.
+func pbPostSummary(p *postgres.Post, author *postgres.User, viewerID int64) *typesv1.Post {
+	pb := pbPost(p, author, viewerID)
+	pb.Content.Body = excerpt(p)
+	return pb
+}
+
+// pbPost converts a post and its author as viewerID sees them. A missing
+// author, e.g. a deleted account, is left empty.
+func pbPost(p *postgres.Post, author *postgres.User, viewerID int64) *typesv1.Post {
+	pbAuthor := &typesv1.User{}
+	if author != nil {
+		pbAuthor = pbUser(author, viewerID)
+	}
 	return &typesv1.Post{
 		Id:     p.ID,
 		Likes:  p.Likes,
 		Views:  p.Views,
-		Author: &typesv1.User{},
+		Author: pbAuthor,
 		Content: &typesv1.PostContent{
 			Title: p.Title,
 			Body:  p.Body,
@@ -235,15 +575,48 @@ func pbPost(p *postgres.Post) *typesv1.Post {
 	}
 }
 
-func pbUser(u *postgres.User) *typesv1.User {
+// etag formats a post version as a strong HTTP entity tag.
+func etag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+func parseETag(tag string) (int64, error) {
+	if tag == "" {
+		return 0, fmt.Errorf("missing post version")
+	}
+	unquoted, err := strconv.Unquote(strings.TrimPrefix(tag, "W/"))
+	if err != nil {
+		return 0, fmt.Errorf("malformed post version %q", tag)
+	}
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed post version %q", tag)
+	}
+	return version, nil
+}
+
+// isSerializationFailure reports whether a repeatable read transaction lost a
+// race against a concurrent update (SQLSTATE 40001).
+func isSerializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "40001"
+}
+
+// pbUser converts a user as viewerID sees them. The email address is only
+// shown to the user themselves.
+func pbUser(u *postgres.User, viewerID int64) *typesv1.User {
 	var deletedAt *timestamppb.Timestamp
 	if u.DeletedAt.Valid {
 		deletedAt = timestamppb.New(u.DeletedAt.Time)
 	}
+	var email string
+	if u.ID == viewerID {
+		email = u.Email
+	}
 	return &typesv1.User{
 		Id:               u.ID,
 		Username:         u.Username,
-		Email:            u.Email,
+		Email:            email,
 		AvatarUrl:        u.AvatarUrl,
 		AboutMe:          u.AboutMe,
 		IdentityProvider: pbIdentityProvider(u.IdentityProvider),
@@ -0,0 +1,178 @@
+package v1
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/authn"
+	"connectrpc.com/connect"
+	postv1 "github.com/gaesemo/blog-api/go/service/post/v1"
+	typesv1 "github.com/gaesemo/blog-api/go/types/v1"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/pgtest"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+// clock is a timeNow that tests move by hand.
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func newTestService(t *testing.T, opts ...Option) (*service, *clock) {
+	t.Helper()
+	c := &clock{now: time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)}
+	svc := New(slog.New(slog.DiscardHandler), pgtest.New(t), c.Now, opts...)
+	return svc.(*service), c
+}
+
+func createUser(t *testing.T, s *service, name string) int64 {
+	t.Helper()
+	now := pgtype.Timestamptz{Time: s.timeNow(), Valid: true}
+	u, err := s.queries.CreateUser(context.Background(), postgres.CreateUserParams{
+		IdentityProvider: "IDENTITY_PROVIDER_GITHUB",
+		Email:            name + "@example.com",
+		Username:         name,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	})
+	require.NoError(t, err)
+	return u.ID
+}
+
+func asUser(uid int64) context.Context {
+	return authn.SetInfo(context.Background(), &uid)
+}
+
+func createPost(t *testing.T, s *service, uid int64, title string) (*typesv1.Post, string) {
+	t.Helper()
+	resp, err := s.Create(asUser(uid), connect.NewRequest(&postv1.CreateRequest{
+		PostContent: &typesv1.PostContent{Title: title, Body: "# " + title},
+	}))
+	require.NoError(t, err)
+	return resp.Msg.Post, resp.Header().Get("ETag")
+}
+
+// getPost reads the stored post as viewer sees it.
+func getPost(t *testing.T, s *service, id, viewer int64) postgres.Post {
+	t.Helper()
+	p, err := s.queries.GetPostById(context.Background(), postgres.GetPostByIdParams{ID: id, ViewerID: viewer})
+	require.NoError(t, err)
+	return p
+}
+
+// serve sends an HTTP request to s from the user with ctx.
+func serve(t *testing.T, s *service, ctx context.Context, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequestWithContext(ctx, method, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func updateRequest(id int64, ifMatch, title string) *connect.Request[postv1.UpdateRequest] {
+	req := connect.NewRequest(&postv1.UpdateRequest{
+		Id:          id,
+		PostContent: &typesv1.PostContent{Title: title, Body: "# " + title},
+	})
+	if ifMatch != "" {
+		req.Header().Set("If-Match", ifMatch)
+	}
+	return req
+}
+
+func TestUpdateRequiresIfMatch(t *testing.T) {
+	s, _ := newTestService(t)
+	uid := createUser(t, s, "author")
+	post, _ := createPost(t, s, uid, "first")
+
+	_, err := s.Update(asUser(uid), updateRequest(post.Id, "", "second"))
+	require.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+}
+
+func TestUpdateRejectsStaleVersion(t *testing.T) {
+	s, _ := newTestService(t)
+	uid := createUser(t, s, "author")
+	post, tag := createPost(t, s, uid, "first")
+	require.NotEmpty(t, tag)
+
+	resp, err := s.Update(asUser(uid), updateRequest(post.Id, tag, "second"))
+	require.NoError(t, err)
+	require.NotEqual(t, tag, resp.Header().Get("ETag"), "each update moves the version on")
+
+	_, err = s.Update(asUser(uid), updateRequest(post.Id, tag, "third"))
+	require.Equal(t, connect.CodeAborted, connect.CodeOf(err))
+
+	got := getPost(t, s, post.Id, uid)
+	require.Equal(t, "second", got.Title, "the stale edit must not overwrite the newer one")
+}
+
+func TestUpdateByOtherUser(t *testing.T) {
+	s, _ := newTestService(t)
+	author := createUser(t, s, "author")
+	other := createUser(t, s, "other")
+	post, tag := createPost(t, s, author, "first")
+
+	_, err := s.Update(asUser(other), updateRequest(post.Id, tag, "hijacked"))
+	require.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+
+	got := getPost(t, s, post.Id, author)
+	require.Equal(t, "first", got.Title)
+}
+
+func TestAuthorEmailOnlyShownToAuthor(t *testing.T) {
+	s, _ := newTestService(t)
+	author := createUser(t, s, "author")
+	reader := createUser(t, s, "reader")
+	post, _ := createPost(t, s, author, "post")
+	_, err := s.queries.SetPostStatus(context.Background(), postgres.SetPostStatusParams{
+		Status:      statusPublished,
+		PublishedAt: pgtype.Timestamptz{Time: s.timeNow(), Valid: true},
+		UpdatedAt:   pgtype.Timestamptz{Time: s.timeNow(), Valid: true},
+		ID:          post.Id,
+	})
+	require.NoError(t, err)
+
+	for name, ctx := range map[string]context.Context{
+		"anonymous":  context.Background(),
+		"other user": asUser(reader),
+	} {
+		t.Run(name, func(t *testing.T) {
+			detail, err := s.Detail(ctx, connect.NewRequest(&postv1.DetailRequest{Id: post.Id}))
+			require.NoError(t, err)
+			require.Equal(t, "author", detail.Msg.Post.Author.Username)
+			require.Empty(t, detail.Msg.Post.Author.Email)
+
+			list, err := s.List(ctx, connect.NewRequest(&postv1.ListRequest{}))
+			require.NoError(t, err)
+			require.Len(t, list.Msg.Posts, 1)
+			require.Empty(t, list.Msg.Posts[0].Author.Email)
+		})
+	}
+
+	detail, err := s.Detail(asUser(author), connect.NewRequest(&postv1.DetailRequest{Id: post.Id}))
+	require.NoError(t, err)
+	require.Equal(t, "author@example.com", detail.Msg.Post.Author.Email)
+}
+
+func TestCreatePublishesOnlyOverRPC(t *testing.T) {
+	s, _ := newTestService(t)
+	uid := createUser(t, s, "author")
+	detail := func(id int64) error {
+		_, err := s.Detail(context.Background(), connect.NewRequest(&postv1.DetailRequest{Id: id}))
+		return err
+	}
+
+	post, _ := createPost(t, s, uid, "over rpc")
+	require.NoError(t, detail(post.Id), "RPC clients cannot publish drafts, so Create publishes")
+	require.True(t, getPost(t, s, post.Id, uid).PublishedAt.Valid)
+
+	draft, _ := servePost(t, s, uid, http.MethodPost, "/api/posts", "", `{"title": "over http", "body": "text"}`)
+	require.Equal(t, connect.CodeNotFound, connect.CodeOf(detail(draft.ID)))
+	require.Equal(t, statusDraft, getPost(t, s, draft.ID, uid).Status)
+}
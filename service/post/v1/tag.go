@@ -0,0 +1,241 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"connectrpc.com/connect"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/httpapi"
+	"github.com/gaesemo/blog-server/pkg/tag"
+	"github.com/gaesemo/blog-server/pkg/transaction"
+	"github.com/gaesemo/blog-server/pkg/userloader"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	maxTagsPerPost = 10
+	maxTagLength   = 32
+	tagCloudSize   = 100
+)
+
+// setTags replaces the tags of a post. Tags are normalized, so "Go Lang" and
+// "go  lang" end up as the same tag. Like an edit, it moves the version of
+// the post on, which the ETag of the response carries.
+//
+//	PUT /api/posts/{id}/tags
+//	{"tags": ["go", "database"]}
+func (s *service) setTags(w http.ResponseWriter, r *http.Request) {
+	uid, err := httpapi.RequireUserID(r)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	id, err := httpapi.PathInt64(r, "id")
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	var body struct {
+		Tags []string `json:"tags"`
+	}
+	if err := httpapi.ReadJSON(r, &body); err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	tags, err := validateTags(body.Tags)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+
+	if err := s.createTags(r.Context(), tags); err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+
+	tx := transaction.New[postgres.Post](
+		s.db,
+		pgx.TxOptions{
+			IsoLevel:   pgx.RepeatableRead,
+			AccessMode: pgx.ReadWrite,
+		},
+		s.queries,
+	)
+	post, txErr := tx.Exec(r.Context(), func(c context.Context, q *postgres.Queries) (*postgres.Post, error) {
+		post, err := q.GetPostByIdForUpdate(c, id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("post not found"))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("retrieving post: %v", err)
+		}
+		if post.UserID != uid {
+			return nil, connect.NewError(connect.CodePermissionDenied, fmt.Errorf("not the author of the post"))
+		}
+		if err := replaceTags(c, q, post.ID, tags); err != nil {
+			return nil, err
+		}
+		touched, err := q.TouchPost(c, postgres.TouchPostParams{
+			UpdatedAt: pgtype.Timestamptz{Time: s.timeNow(), Valid: true},
+			ID:        post.ID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("updating post: %v", err)
+		}
+		return &touched, nil
+	})
+	if txErr != nil {
+		s.respond.Error(w, r, httpapi.AsConnectError(txErr, "tagging post"))
+		return
+	}
+	s.postsChanged(id)
+	w.Header().Set("ETag", etag(post.Version))
+	s.respond.JSON(w, r, struct {
+		Tags []string `json:"tags"`
+	}{
+		Tags: tags,
+	})
+}
+
+// validateTags normalizes tags and checks they fit the limits of a post.
+// Errors are connect errors.
+func validateTags(raw []string) ([]string, error) {
+	tags := tag.NormalizeAll(raw)
+	if len(tags) > maxTagsPerPost {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("a post can have at most %d tags", maxTagsPerPost))
+	}
+	for _, t := range tags {
+		if utf8.RuneCountInString(t) > maxTagLength {
+			return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("tag %q is longer than %d characters", t, maxTagLength))
+		}
+	}
+	return tags, nil
+}
+
+// createTags creates those of tags, which must have been through
+// validateTags, that do not exist yet. It is called before the transaction
+// tagging a post rather than in it: at RepeatableRead, two transactions
+// creating the same tag fail with a serialization error, whereas tags created
+// beforehand are simply in their snapshot. Errors are connect errors.
+func (s *service) createTags(ctx context.Context, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	err := s.queries.CreateTags(ctx, postgres.CreateTagsParams{
+		Names:     tags,
+		CreatedAt: pgtype.Timestamptz{Time: s.timeNow(), Valid: true},
+	})
+	if err != nil {
+		return connect.NewError(connect.CodeInternal, fmt.Errorf("saving tags: %v", err))
+	}
+	return nil
+}
+
+// replaceTags sets the tags of post postID to tags, which must have been
+// created with createTags.
+func replaceTags(ctx context.Context, q *postgres.Queries, postID int64, tags []string) error {
+	if err := q.DeletePostTags(ctx, postID); err != nil {
+		return fmt.Errorf("clearing tags: %v", err)
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	rows, err := q.ListTagsByName(ctx, tags)
+	if err != nil {
+		return fmt.Errorf("retrieving tags: %v", err)
+	}
+	if len(rows) != len(tags) {
+		return fmt.Errorf("found %d of %d tags", len(rows), len(tags))
+	}
+	tagIDs := make([]int64, 0, len(rows))
+	for _, t := range rows {
+		tagIDs = append(tagIDs, t.ID)
+	}
+	err = q.AddPostTags(ctx, postgres.AddPostTagsParams{
+		PostID: postID,
+		TagIds: tagIDs,
+	})
+	if err != nil {
+		return fmt.Errorf("tagging post: %v", err)
+	}
+	return nil
+}
+
+// listTagCounts returns the most used tags with the number of published posts
+// carrying each, for a tag cloud.
+//
+//	GET /api/tags
+func (s *service) listTagCounts(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.queries.ListTagCounts(r.Context(), tagCloudSize)
+	if err != nil {
+		s.respond.Error(w, r, connect.NewError(connect.CodeInternal, fmt.Errorf("counting tags: %v", err)))
+		return
+	}
+	type tagCount struct {
+		Name  string `json:"name"`
+		Posts int64  `json:"posts"`
+	}
+	tags := []tagCount{}
+	for _, t := range rows {
+		tags = append(tags, tagCount{Name: t.Name, Posts: t.PostCount})
+	}
+	s.respond.JSON(w, r, struct {
+		Tags []tagCount `json:"tags"`
+	}{
+		Tags: tags,
+	})
+}
+
+// postsJSON converts rows for HTTP responses, filling in their tags, authors
+// and whether the viewer likes them with one query each.
+func (s *service) postsJSON(ctx context.Context, rows []postgres.Post) ([]*postJSON, error) {
+	ids := make([]int64, 0, len(rows))
+	authorIDs := make([]int64, 0, len(rows))
+	for _, p := range rows {
+		ids = append(ids, p.ID)
+		authorIDs = append(authorIDs, p.UserID)
+	}
+	tags, err := s.postTags(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	authors, err := userloader.Load(ctx, s.queries, authorIDs)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving authors: %v", err))
+	}
+	liked, err := s.likedPosts(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	posts := make([]*postJSON, 0, len(rows))
+	for _, p := range rows {
+		post := newPostJSON(&p)
+		post.Tags = tags[p.ID]
+		post.Liked = liked[p.ID]
+		if author, ok := authors[p.UserID]; ok {
+			post.Author = newUserJSON(author)
+		}
+		posts = append(posts, post)
+	}
+	return posts, nil
+}
+
+func (s *service) postTags(ctx context.Context, postIDs []int64) (map[int64][]string, error) {
+	tags := map[int64][]string{}
+	if len(postIDs) == 0 {
+		return tags, nil
+	}
+	rows, err := s.queries.ListTagsByPostIds(ctx, postIDs)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving tags: %v", err))
+	}
+	for _, t := range rows {
+		tags[t.PostID] = append(tags[t.PostID], t.Name)
+	}
+	return tags, nil
+}
@@ -0,0 +1,85 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func servePost(t *testing.T, s *service, uid int64, method, path, ifMatch, body string) (*postJSON, string) {
+	t.Helper()
+	r := httptest.NewRequestWithContext(asUser(uid), method, path, strings.NewReader(body))
+	if ifMatch != "" {
+		r.Header.Set("If-Match", ifMatch)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var post postJSON
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &post))
+	return &post, w.Header().Get("ETag")
+}
+
+func TestCreateAndUpdateWithTags(t *testing.T) {
+	s, _ := newTestService(t)
+	uid := createUser(t, s, "author")
+
+	post, tag := servePost(t, s, uid, http.MethodPost, "/api/posts", "",
+		`{"title": "tagged", "body": "text", "tags": ["Go", " go ", "Database"]}`)
+	require.Equal(t, []string{"database", "go"}, post.Tags)
+
+	path := "/api/posts/" + strconv.FormatInt(post.ID, 10)
+	post, tag = servePost(t, s, uid, http.MethodPut, path, tag,
+		`{"title": "tagged", "body": "more text", "tags": ["postgres"]}`)
+	require.Equal(t, []string{"postgres"}, post.Tags)
+
+	post, _ = servePost(t, s, uid, http.MethodPut, path, tag,
+		`{"title": "tagged", "body": "even more text"}`)
+	require.Equal(t, []string{"postgres"}, post.Tags, "tags left out stay as they are")
+}
+
+func TestSetTagsMovesVersion(t *testing.T) {
+	s, c := newTestService(t)
+	uid := createUser(t, s, "author")
+	post, tag := servePost(t, s, uid, http.MethodPost, "/api/posts", "", `{"title": "tagged", "body": "text"}`)
+	path := "/api/posts/" + strconv.FormatInt(post.ID, 10)
+
+	c.now = c.now.Add(time.Hour)
+	w := serve(t, s, asUser(uid), http.MethodPut, path+"/tags", `{"tags": ["go"]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	retagged := w.Header().Get("ETag")
+	require.NotEqual(t, tag, retagged)
+	require.True(t, getPost(t, s, post.ID, uid).UpdatedAt.Time.Equal(c.now))
+
+	r := httptest.NewRequestWithContext(asUser(uid), http.MethodPut, path, strings.NewReader(`{"title": "stale", "body": "text"}`))
+	r.Header.Set("If-Match", tag)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	require.Equal(t, http.StatusConflict, w.Code, "an edit based on the version before retagging must fail")
+
+	servePost(t, s, uid, http.MethodPut, path, retagged, `{"title": "fresh", "body": "text"}`)
+}
+
+func TestConcurrentTaggingWithNewTag(t *testing.T) {
+	s, _ := newTestService(t)
+	uid := createUser(t, s, "author")
+
+	const n = 8
+	errs := make(chan error, n)
+	for range n {
+		go func() {
+			_, err := s.create(context.Background(), uid, "post", "text", []string{"brand-new"}, statusDraft)
+			errs <- err
+		}()
+	}
+	for range n {
+		require.NoError(t, <-errs)
+	}
+}
@@ -0,0 +1,146 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/cursor"
+	"github.com/gaesemo/blog-server/pkg/httpapi"
+	"github.com/gaesemo/blog-server/pkg/transaction"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const trashPageSize = 20
+
+// WithTrashRetention sets how long deleted posts stay restorable. Non-positive
+// durations keep the default of 30 days.
+func WithTrashRetention(d time.Duration) Option {
+	return func(svc *service) {
+		if d > 0 {
+			svc.trashRetention = d
+		}
+	}
+}
+
+// PurgeTrash implements Service.
+func (s *service) PurgeTrash(ctx context.Context) error {
+	deletedBefore := s.timeNow().Add(-s.trashRetention)
+	n, err := s.queries.PurgeDeletedPosts(ctx, pgtype.Timestamptz{Time: deletedBefore, Valid: true})
+	if err != nil {
+		return fmt.Errorf("purging trash: %v", err)
+	}
+	if n > 0 {
+		s.logger.InfoContext(ctx, "purged trash", slog.Int64("posts", n), slog.Time("deleted_before", deletedBefore))
+	}
+	return nil
+}
+
+// listTrash lists the caller's deleted posts, most recently deleted first.
+// One row more than the page size is fetched to learn whether there is a
+// next page.
+//
+//	GET /api/posts/trash?cursor=<opaque>
+func (s *service) listTrash(w http.ResponseWriter, r *http.Request) {
+	uid, err := httpapi.RequireUserID(r)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	k, err := cursor.ParseKeyset(httpapi.QueryCursor(r))
+	if err != nil {
+		s.respond.Error(w, r, connect.NewError(connect.CodeInvalidArgument, err))
+		return
+	}
+	var cursorTime pgtype.Timestamptz
+	if !k.Time.IsZero() {
+		cursorTime = pgtype.Timestamptz{Time: k.Time, Valid: true}
+	}
+
+	rows, err := s.queries.ListDeletedPostsByUser(r.Context(), postgres.ListDeletedPostsByUserParams{
+		UserID:     uid,
+		CursorTime: cursorTime,
+		CursorID:   k.ID,
+		PageSize:   trashPageSize + 1,
+	})
+	if err != nil {
+		s.respond.Error(w, r, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving trash: %v", err)))
+		return
+	}
+
+	var next string
+	if len(rows) > trashPageSize {
+		rows = rows[:trashPageSize]
+		last := &rows[len(rows)-1]
+		next = string(cursor.FromKeyset(cursor.Keyset{Time: last.DeletedAt.Time, ID: last.ID}).Opaque)
+	}
+	posts := []*postJSON{}
+	for _, p := range rows {
+		posts = append(posts, newPostJSON(&p))
+	}
+	s.respond.JSON(w, r, struct {
+		Posts []*postJSON `json:"posts"`
+		Next  string      `json:"next,omitempty"`
+	}{
+		Posts: posts,
+		Next:  next,
+	})
+}
+
+// restore moves one of the caller's posts out of the trash.
+//
+//	POST /api/posts/{id}/restore
+func (s *service) restore(w http.ResponseWriter, r *http.Request) {
+	uid, err := httpapi.RequireUserID(r)
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	id, err := httpapi.PathInt64(r, "id")
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+
+	tx := transaction.New[postgres.Post](
+		s.db,
+		pgx.TxOptions{
+			IsoLevel:   pgx.RepeatableRead,
+			AccessMode: pgx.ReadWrite,
+		},
+		s.queries,
+	)
+	post, txErr := tx.Exec(r.Context(), func(c context.Context, q *postgres.Queries) (*postgres.Post, error) {
+		post, err := q.GetDeletedPostByIdForUpdate(c, id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("post not found in trash"))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("retrieving post: %v", err)
+		}
+		if post.UserID != uid {
+			return nil, connect.NewError(connect.CodePermissionDenied, fmt.Errorf("not the author of the post"))
+		}
+		restored, err := q.RestorePost(c, postgres.RestorePostParams{
+			UpdatedAt: pgtype.Timestamptz{Time: s.timeNow(), Valid: true},
+			ID:        post.ID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("restoring post: %v", err)
+		}
+		return &restored, nil
+	})
+	if txErr != nil {
+		s.respond.Error(w, r, httpapi.AsConnectError(txErr, "restoring post"))
+		return
+	}
+	s.postsChanged(id)
+	w.Header().Set("ETag", etag(post.Version))
+	s.respond.JSON(w, r, newPostJSON(post))
+}
@@ -0,0 +1,144 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	postv1 "github.com/gaesemo/blog-api/go/service/post/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func deletePost(t *testing.T, s *service, uid, id int64) error {
+	t.Helper()
+	_, err := s.Delete(asUser(uid), connect.NewRequest(&postv1.DeleteRequest{Id: id}))
+	return err
+}
+
+// listTrash returns the IDs of a page of uid's trash and the cursor of the
+// next one.
+func listTrash(t *testing.T, s *service, uid int64, cur string) ([]int64, string) {
+	t.Helper()
+	w := serve(t, s, asUser(uid), http.MethodGet, "/api/posts/trash?cursor="+url.QueryEscape(cur), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var page struct {
+		Posts []postJSON `json:"posts"`
+		Next  string     `json:"next"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	ids := []int64{}
+	for _, p := range page.Posts {
+		ids = append(ids, p.ID)
+	}
+	return ids, page.Next
+}
+
+func TestDeleteMovesPostToTrash(t *testing.T) {
+	s, _ := newTestService(t)
+	author := createUser(t, s, "author")
+	other := createUser(t, s, "other")
+	post, _ := createPost(t, s, author, "doomed")
+
+	require.Equal(t, connect.CodePermissionDenied, connect.CodeOf(deletePost(t, s, other, post.Id)))
+	require.NoError(t, deletePost(t, s, author, post.Id))
+	require.Equal(t, connect.CodeNotFound, connect.CodeOf(deletePost(t, s, author, post.Id)), "deleted posts cannot be deleted again")
+
+	_, err := s.Detail(asUser(author), connect.NewRequest(&postv1.DetailRequest{Id: post.Id}))
+	require.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+	ids, _ := listTrash(t, s, author, "")
+	require.Equal(t, []int64{post.Id}, ids)
+	ids, _ = listTrash(t, s, other, "")
+	require.Empty(t, ids, "the trash only holds the caller's posts")
+}
+
+func TestListTrashOrdersByDeletion(t *testing.T) {
+	s, c := newTestService(t)
+	uid := createUser(t, s, "author")
+	a, _ := createPost(t, s, uid, "a")
+	b, _ := createPost(t, s, uid, "b")
+	d, _ := createPost(t, s, uid, "c")
+
+	for _, id := range []int64{d.Id, a.Id, b.Id} {
+		c.now = c.now.Add(time.Minute)
+		require.NoError(t, deletePost(t, s, uid, id))
+	}
+	ids, next := listTrash(t, s, uid, "")
+	require.Equal(t, []int64{b.Id, a.Id, d.Id}, ids, "most recently deleted first")
+	require.Empty(t, next)
+}
+
+func TestListTrashPages(t *testing.T) {
+	s, c := newTestService(t)
+	uid := createUser(t, s, "author")
+	var deleted []int64
+	trashAll := func(n int) {
+		for range n {
+			post, _ := createPost(t, s, uid, "post")
+			c.now = c.now.Add(time.Second)
+			require.NoError(t, deletePost(t, s, uid, post.Id))
+			deleted = append([]int64{post.Id}, deleted...)
+		}
+	}
+
+	trashAll(trashPageSize)
+	ids, next := listTrash(t, s, uid, "")
+	require.Equal(t, deleted, ids)
+	require.Empty(t, next, "an exactly full page is the last one")
+
+	trashAll(1)
+	ids, next = listTrash(t, s, uid, "")
+	require.Equal(t, deleted[:trashPageSize], ids)
+	require.NotEmpty(t, next)
+	ids, next = listTrash(t, s, uid, next)
+	require.Equal(t, deleted[trashPageSize:], ids)
+	require.Empty(t, next)
+}
+
+func TestRestore(t *testing.T) {
+	s, _ := newTestService(t)
+	author := createUser(t, s, "author")
+	other := createUser(t, s, "other")
+	post, _ := createPost(t, s, author, "restored")
+	require.NoError(t, deletePost(t, s, author, post.Id))
+	path := "/api/posts/" + strconv.FormatInt(post.Id, 10) + "/restore"
+
+	w := serve(t, s, asUser(other), http.MethodPost, path, "")
+	require.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+
+	w = serve(t, s, asUser(author), http.MethodPost, path, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NotEmpty(t, w.Header().Get("ETag"))
+	_, err := s.Detail(asUser(author), connect.NewRequest(&postv1.DetailRequest{Id: post.Id}))
+	require.NoError(t, err)
+	ids, _ := listTrash(t, s, author, "")
+	require.Empty(t, ids)
+
+	w = serve(t, s, asUser(author), http.MethodPost, path, "")
+	require.Equal(t, http.StatusNotFound, w.Code, "only posts in the trash can be restored")
+}
+
+func TestPurgeTrashAfterRetention(t *testing.T) {
+	s, c := newTestService(t, WithTrashRetention(7*24*time.Hour))
+	uid := createUser(t, s, "author")
+	post, _ := createPost(t, s, uid, "purged")
+	require.NoError(t, deletePost(t, s, uid, post.Id))
+	exists := func() bool {
+		var n int
+		require.NoError(t, s.db.QueryRow(context.Background(), "SELECT count(*) FROM posts WHERE id = $1", post.Id).Scan(&n))
+		return n == 1
+	}
+
+	c.now = c.now.Add(7*24*time.Hour - time.Minute)
+	require.NoError(t, s.PurgeTrash(context.Background()))
+	require.True(t, exists(), "posts stay restorable for the retention period")
+
+	c.now = c.now.Add(2 * time.Minute)
+	require.NoError(t, s.PurgeTrash(context.Background()))
+	require.False(t, exists())
+}
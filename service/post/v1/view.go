@@ -0,0 +1,101 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/httpapi"
+	"github.com/gaesemo/blog-server/pkg/transaction"
+	"github.com/gaesemo/blog-server/pkg/viewcount"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	defaultViewWindow = time.Hour
+	// viewerKeyPeriod is how long anonymous viewer IDs stay linkable.
+	viewerKeyPeriod = 24 * time.Hour
+)
+
+// WithViewWindow sets how long repeated views of a post by the same viewer
+// count only once. Non-positive durations keep the default of an hour.
+func WithViewWindow(d time.Duration) Option {
+	return func(svc *service) {
+		if d > 0 {
+			svc.views = viewcount.NewBuffer(d)
+		}
+	}
+}
+
+// FlushViews implements Service.
+func (s *service) FlushViews(ctx context.Context) error {
+	counts := s.views.Drain(s.timeNow())
+	if len(counts) == 0 {
+		return nil
+	}
+	// Sorted, so concurrent flushes lock rows in the same order.
+	postIDs := slices.Sorted(maps.Keys(counts))
+	views := make([]int64, 0, len(postIDs))
+	for _, id := range postIDs {
+		views = append(views, counts[id])
+	}
+	tx := transaction.New[struct{}](
+		s.db,
+		pgx.TxOptions{
+			IsoLevel:   pgx.ReadCommitted,
+			AccessMode: pgx.ReadWrite,
+		},
+		s.queries,
+	)
+	_, txErr := tx.Exec(ctx, func(c context.Context, q *postgres.Queries) (*struct{}, error) {
+		err := q.AddPostViews(c, postgres.AddPostViewsParams{
+			PostIds: postIDs,
+			Views:   views,
+		})
+		if err != nil {
+			return nil, err
+		}
+		// Daily tallies feed the rankings; see RefreshRankings.
+		err = q.AddDailyPostViews(c, postgres.AddDailyPostViewsParams{
+			Day:     pgtype.Date{Time: s.timeNow().UTC(), Valid: true},
+			PostIds: postIDs,
+			Views:   views,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &struct{}{}, nil
+	})
+	if txErr != nil {
+		s.views.Add(counts)
+		return fmt.Errorf("flushing views: %v", txErr)
+	}
+	return nil
+}
+
+// recordView counts a view of a published post by anyone but its author.
+// Anonymous viewers are told apart by remote address and user agent; the
+// address is the client's behind trusted proxies, see middleware.RealIP.
+func (s *service) recordView(ctx context.Context, p *postgres.Post, remoteAddr, userAgent string) {
+	if p.Status != statusPublished {
+		return
+	}
+	uid := httpapi.ViewerID(ctx)
+	if uid == p.UserID {
+		return
+	}
+	viewer := viewcount.UserViewer(uid)
+	if uid == 0 {
+		host, _, err := net.SplitHostPort(remoteAddr)
+		if err != nil {
+			host = remoteAddr
+		}
+		viewer = s.viewers.AnonymousViewer(host, userAgent, s.timeNow())
+	}
+	s.views.Record(p.ID, viewer, s.timeNow())
+}
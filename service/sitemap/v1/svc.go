@@ -0,0 +1,241 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/gaesemo/blog-server/gen/db/postgres"
+	"github.com/gaesemo/blog-server/pkg/httpapi"
+	"github.com/gaesemo/blog-server/pkg/sitemap"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ Service = (*service)(nil)
+
+// Service serves robots.txt and sitemaps of the published posts and their
+// authors.
+type Service interface {
+	http.Handler
+
+	// Invalidate drops the cached sitemaps, so the next request rebuilds
+	// them. It is called whenever posts change.
+	Invalidate()
+}
+
+func New(
+	logger *slog.Logger,
+	db *pgxpool.Pool,
+	timeNow func() time.Time,
+	site httpapi.Site,
+	opts ...Option,
+) Service {
+	svc := &service{
+		logger:  logger,
+		queries: postgres.New(db),
+		timeNow: timeNow,
+		respond: httpapi.NewResponder(logger),
+		siteURL: site.URL,
+		robots:  defaultRobots,
+	}
+
+	for _, o := range opts {
+		o(svc)
+	}
+
+	svc.mux = svc.routes()
+	return svc
+}
+
+// defaultRobots lets crawlers in everywhere but the API.
+const defaultRobots = `User-agent: *
+Disallow: /api/
+`
+
+type Option func(svc *service)
+
+// WithRobots replaces the rules served as robots.txt. A Sitemap line pointing
+// at the sitemap index is added unless the rules have one.
+func WithRobots(rules string) Option {
+	return func(svc *service) {
+		if strings.TrimSpace(rules) != "" {
+			svc.robots = rules
+		}
+	}
+}
+
+type service struct {
+	logger  *slog.Logger
+	queries *postgres.Queries
+	timeNow func() time.Time
+	mux     *http.ServeMux
+	respond *httpapi.Responder
+	siteURL string
+	robots  string
+
+	// gen counts invalidations. A snapshot built before the latest one is
+	// stale.
+	gen    atomic.Uint64
+	mu     sync.Mutex // guards cached and serialises rebuilds
+	cached *snapshot
+}
+
+// snapshot holds the encoded sitemaps, by file name.
+type snapshot struct {
+	gen   uint64
+	built time.Time
+	files map[string][]byte
+}
+
+const indexFile = "sitemap.xml"
+
+func (s *service) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /robots.txt", s.serveRobots)
+	mux.HandleFunc("GET /"+indexFile, s.serveSitemap)
+	mux.HandleFunc("GET /sitemaps/{file}", s.serveSitemap)
+	return mux
+}
+
+// ServeHTTP implements http.Handler.
+func (s *service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Invalidate implements Service.
+func (s *service) Invalidate() {
+	s.gen.Add(1)
+}
+
+// serveRobots serves the configured rules.
+//
+//	GET /robots.txt
+func (s *service) serveRobots(w http.ResponseWriter, r *http.Request) {
+	rules := s.robots
+	if !strings.Contains(strings.ToLower(rules), "sitemap:") {
+		rules = strings.TrimRight(rules, "\n") + "\n\nSitemap: " + s.siteURL + "/" + indexFile + "\n"
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(rules))
+}
+
+// serveSitemap serves the sitemap index, or one of the sitemaps it lists:
+// posts-{n}.xml and authors-{n}.xml, each with up to sitemap.MaxURLs URLs.
+//
+//	GET /sitemap.xml
+//	GET /sitemaps/{file}
+func (s *service) serveSitemap(w http.ResponseWriter, r *http.Request) {
+	file := r.PathValue("file")
+	if file == "" {
+		file = indexFile
+	}
+	snap, err := s.snapshot(r.Context())
+	if err != nil {
+		s.respond.Error(w, r, err)
+		return
+	}
+	body, ok := snap.files[file]
+	if !ok {
+		s.respond.Error(w, r, connect.NewError(connect.CodeNotFound, fmt.Errorf("sitemap %q not found", file)))
+		return
+	}
+	w.Header().Set("Content-Type", sitemap.ContentType)
+	http.ServeContent(w, r, "", snap.built, bytes.NewReader(body))
+}
+
+// snapshot returns the cached sitemaps, rebuilding them if posts changed
+// since they were built.
+func (s *service) snapshot(ctx context.Context) (*snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	gen := s.gen.Load()
+	if s.cached != nil && s.cached.gen == gen {
+		return s.cached, nil
+	}
+	snap, err := s.build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Invalidations during the build bump gen past this one, so the next
+	// request builds again.
+	snap.gen = gen
+	s.cached = snap
+	return snap, nil
+}
+
+func (s *service) build(ctx context.Context) (*snapshot, error) {
+	site := s.siteURL
+	posts, err := s.queries.ListSitemapPosts(ctx)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving posts: %v", err))
+	}
+	postURLs := make([]sitemap.URL, 0, len(posts))
+	for _, p := range posts {
+		postURLs = append(postURLs, sitemap.URL{
+			Loc:     site + "/posts/" + p.Slug,
+			LastMod: p.UpdatedAt.Time,
+		})
+	}
+	authors, err := s.queries.ListSitemapAuthors(ctx)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("retrieving authors: %v", err))
+	}
+	authorURLs := make([]sitemap.URL, 0, len(authors))
+	for _, a := range authors {
+		authorURLs = append(authorURLs, sitemap.URL{
+			Loc:     site + "/authors/" + strconv.FormatInt(a.UserID, 10),
+			LastMod: a.UpdatedAt.Time,
+		})
+	}
+
+	snap := &snapshot{
+		built: s.timeNow(),
+		files: map[string][]byte{},
+	}
+	var index []sitemap.URL
+	for _, set := range []struct {
+		name string
+		urls []sitemap.URL
+	}{
+		{name: "posts", urls: postURLs},
+		{name: "authors", urls: authorURLs},
+	} {
+		for page := 0; page*sitemap.MaxURLs < len(set.urls); page++ {
+			urls := set.urls[page*sitemap.MaxURLs : min((page+1)*sitemap.MaxURLs, len(set.urls))]
+			file := set.name + "-" + strconv.Itoa(page+1) + ".xml"
+			body, err := sitemap.URLSet(urls)
+			if err != nil {
+				return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("encoding sitemap: %v", err))
+			}
+			snap.files[file] = body
+			index = append(index, sitemap.URL{
+				Loc:     site + "/sitemaps/" + file,
+				LastMod: lastMod(urls),
+			})
+		}
+	}
+	body, err := sitemap.Index(index)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("encoding sitemap index: %v", err))
+	}
+	snap.files[indexFile] = body
+	return snap, nil
+}
+
+func lastMod(urls []sitemap.URL) time.Time {
+	var latest time.Time
+	for _, u := range urls {
+		if u.LastMod.After(latest) {
+			latest = u.LastMod
+		}
+	}
+	return latest
+}